@@ -0,0 +1,36 @@
+// backupcrypt encrypts or decrypts a redis snapshot from stdin to stdout, it is used by the
+// redis-tools image. The base64 encoded AES-256 key is read from the REDIS_BACKUP_KEY environment variable.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/backupcrypt"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: backupcrypt encrypt|decrypt < in > out")
+		os.Exit(2)
+	}
+	key, err := backupcrypt.ParseKey(os.Getenv(v1alpha1.BackupKeyENV))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid %s: %v\n", v1alpha1.BackupKeyENV, err)
+		os.Exit(1)
+	}
+	switch os.Args[1] {
+	case "encrypt":
+		err = backupcrypt.Encrypt(os.Stdout, os.Stdin, key)
+	case "decrypt":
+		err = backupcrypt.Decrypt(os.Stdout, os.Stdin, key)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -32,6 +32,8 @@ import (
 	"github.com/ucloud/redis-cluster-operator/pkg/controller"
 	"github.com/ucloud/redis-cluster-operator/pkg/controller/distributedrediscluster"
 	"github.com/ucloud/redis-cluster-operator/pkg/controller/redisclusterbackup"
+	"github.com/ucloud/redis-cluster-operator/pkg/controller/redisclusterbackupschedule"
+	"github.com/ucloud/redis-cluster-operator/pkg/controller/redisclusterrestore"
 	"github.com/ucloud/redis-cluster-operator/pkg/utils"
 	"github.com/ucloud/redis-cluster-operator/version"
 )
@@ -58,6 +60,8 @@ func main() {
 
 	pflag.CommandLine.AddFlagSet(distributedrediscluster.FlagSet())
 	pflag.CommandLine.AddFlagSet(redisclusterbackup.FlagSet())
+	pflag.CommandLine.AddFlagSet(redisclusterbackupschedule.FlagSet())
+	pflag.CommandLine.AddFlagSet(redisclusterrestore.FlagSet())
 
 	// Add flags registered by imported packages (e.g. glog and
 	// controller-runtime)
@@ -164,6 +168,10 @@ func main() {
 			log.Error(err, "unable to create webHook", "webHook", "DistributedRedisCluster")
 			os.Exit(1)
 		}
+		if err = (&redisv1alpha1.RedisClusterBackup{}).SetupWebhookWithManager(mgr); err != nil {
+			log.Error(err, "unable to create webHook", "webHook", "RedisClusterBackup")
+			os.Exit(1)
+		}
 	}
 
 	log.Info("Starting the Cmd.")
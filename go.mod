@@ -13,6 +13,7 @@ require (
 	github.com/onsi/gomega v1.5.0
 	github.com/operator-framework/operator-sdk v0.13.0
 	github.com/pkg/errors v0.8.1
+	github.com/robfig/cron v1.1.0
 	github.com/spf13/pflag v1.0.5
 	gomodules.xyz/stow v0.2.3
 	k8s.io/api v0.0.0
@@ -33,11 +33,15 @@ build() {
     mv rclone-"${OSM_VER}"-linux-amd64/rclone osm
   fi
 
+  # the snapshots are encrypted and decrypted by backupcrypt
+  GO111MODULE=on CGO_ENABLED=0 GOOS=linux GOARCH=amd64 go build -o backupcrypt "$REPO_ROOT/cmd/backupcrypt"
+
   local cmd="docker build --pull -t $DOCKER_REGISTRY/$IMG:$TAG ."
   echo $cmd; $cmd
 
   rm -rf rclone-"${OSM_VER}"-linux-amd64*
   rm osm
+  rm backupcrypt
   popd
 }
 
@@ -16,6 +16,48 @@ show_help() {
   echo "    --bucket=BUCKET                name of bucket"
   echo "    --folder=FOLDER                name of folder in bucket"
   echo "    --snapshot=SNAPSHOT            name of snapshot"
+  echo "    --master-id=ID                 id of the master of the shard if host is a slave"
+  echo "    --compression=CODEC            codec of the dump file, one of none, gzip, zstd, lz4 (default: none)"
+  echo "    --interval=SECONDS             delay between two shipments of the AOF (default: 10)"
+  echo "    --timestamp=UNIXTIME           time the AOF is restored to"
+}
+
+# compressor prints the command compressing stdin to stdout with the codec $1
+compressor() {
+  case "$1" in
+    none) echo "cat" ;;
+    gzip) echo "gzip -c" ;;
+    zstd) echo "zstd -q -c" ;;
+    lz4) echo "lz4 -q -c" ;;
+    *) return 1 ;;
+  esac
+}
+
+# compressed_ext prints the extension of a file compressed with the codec $1
+compressed_ext() {
+  case "$1" in
+    gzip) echo ".gz" ;;
+    zstd) echo ".zst" ;;
+    lz4) echo ".lz4" ;;
+    *) echo "" ;;
+  esac
+}
+
+# decompressor prints the command decompressing stdin to stdout for the dump file $1
+decompressor() {
+  case "$1" in
+    *.gz) echo "gzip -d -c" ;;
+    *.zst) echo "zstd -q -d -c" ;;
+    *.lz4) echo "lz4 -q -d -c" ;;
+    *) echo "cat" ;;
+  esac
+}
+
+# fail writes $1 to the termination message of the container and exits
+fail() {
+  echo "$1"
+  echo "$1" > /dev/termination-log || true
+  exit 1
 }
 
 RETVAL=0
@@ -27,7 +69,12 @@ REDIS_PASSWORD=${REDIS_PASSWORD:-}
 REDIS_BUCKET=${REDIS_BUCKET:-}
 REDIS_FOLDER=${REDIS_FOLDER:-}
 REDIS_SNAPSHOT=${REDIS_SNAPSHOT:-}
+REDIS_MASTER_ID=${REDIS_MASTER_ID:-}
+REDIS_BACKUP_KEY=${REDIS_BACKUP_KEY:-}
+REDIS_COMPRESSION=${REDIS_COMPRESSION:-none}
 REDIS_DATA_DIR=${REDIS_DATA_DIR:-/data}
+REDIS_INTERVAL=${REDIS_INTERVAL:-10}
+REDIS_TIMESTAMP=${REDIS_TIMESTAMP:-}
 REDIS_RESTORE_SUCCEEDED=${REDIS_RESTORE_SUCCEEDED:-0}
 OSM_CONFIG_FILE=/etc/osm/config
 
@@ -64,6 +111,22 @@ while test $# -gt 0; do
       export REDIS_SNAPSHOT=$(echo $1 | sed -e 's/^[^=]*=//g')
       shift
       ;;
+    --master-id*)
+      export REDIS_MASTER_ID=$(echo $1 | sed -e 's/^[^=]*=//g')
+      shift
+      ;;
+    --compression*)
+      export REDIS_COMPRESSION=$(echo $1 | sed -e 's/^[^=]*=//g')
+      shift
+      ;;
+    --interval*)
+      export REDIS_INTERVAL=$(echo $1 | sed -e 's/^[^=]*=//g')
+      shift
+      ;;
+    --timestamp*)
+      export REDIS_TIMESTAMP=$(echo $1 | sed -e 's/^[^=]*=//g')
+      shift
+      ;;
     --)
       shift
       break
@@ -102,14 +165,56 @@ case "$op" in
     # cleanup data dump dir
     rm -rf *
 
-    redis-cli --rdb dump.rdb -h "${REDIS_HOST}" -a "${REDIS_PASSWORD}"
-    redis-cli -h "${REDIS_HOST}" -a "${REDIS_PASSWORD}" CLUSTER NODES | grep myself > nodes.conf
+    redis-cli ${REDIS_CLI_ARGS} --rdb dump.rdb -h "${REDIS_HOST}" -a "${REDIS_PASSWORD}"
+    if [ -n "${REDIS_MASTER_ID}" ]; then
+      # dumped from a slave, the snapshot is restored as its master
+      redis-cli ${REDIS_CLI_ARGS} -h "${REDIS_HOST}" -a "${REDIS_PASSWORD}" CLUSTER NODES \
+        | awk -v id="${REDIS_MASTER_ID}" '$1 == id {$3 = "myself," $3; print}' > nodes.conf
+      if [ ! -s nodes.conf ]; then
+        echo "master ${REDIS_MASTER_ID} not found in the cluster nodes of ${REDIS_HOST}"
+        exit 1
+      fi
+    else
+      redis-cli ${REDIS_CLI_ARGS} -h "${REDIS_HOST}" -a "${REDIS_PASSWORD}" CLUSTER NODES | grep myself > nodes.conf
+    fi
+
+    echo "Writing manifest......"
+    node_id=$(awk '{print $1}' nodes.conf)
+    slots=$(awk '{for (i = 9; i <= NF; i++) printf "%s%s", (i > 9 ? " " : ""), $i}' nodes.conf)
+    size=$(stat -c %s dump.rdb)
+    sha256=$(sha256sum dump.rdb | awk '{print $1}')
+    version=$(redis-cli ${REDIS_CLI_ARGS} -h "${REDIS_HOST}" -a "${REDIS_PASSWORD}" INFO server | awk -F: '/^redis_version/ {print $2}' | tr -d '\r')
+    compress=$(compressor "${REDIS_COMPRESSION}") || fail "unknown compression ${REDIS_COMPRESSION}"
+    encrypted=false
+    if [ -n "${REDIS_BACKUP_KEY}" ]; then
+      encrypted=true
+    fi
+    # the checksum of the manifest is the one of the plain dump file, it is compressed before it is encrypted
+    dump_file=dump.rdb$(compressed_ext "${REDIS_COMPRESSION}")
+    if [ "${dump_file}" != "dump.rdb" ]; then
+      echo "Compressing dump file with ${REDIS_COMPRESSION}......"
+    fi
+    if [ "${encrypted}" == "true" ]; then
+      echo "Encrypting dump file......"
+      ${compress} < dump.rdb | backupcrypt encrypt > "${dump_file}.enc"
+      rm -f dump.rdb
+    elif [ "${dump_file}" != "dump.rdb" ]; then
+      ${compress} < dump.rdb > "${dump_file}"
+      rm -f dump.rdb
+    fi
+    uploaded=$(stat -c %s dump.rdb*)
+    printf '{"snapshot":"%s","nodeID":"%s","slots":"%s","size":%s,"sha256":"%s","redisVersion":"%s","encrypted":%s,"compression":"%s","uploadedSize":%s}' \
+      "${REDIS_SNAPSHOT}" "${node_id}" "${slots}" "${size}" "${sha256}" "${version}" "${encrypted}" "${REDIS_COMPRESSION}" "${uploaded}" > manifest.json
+    cat manifest.json
+    echo ""
     pwd
     ls -lh "$SOURCE_DIR"
     echo "Uploading dump file to the backend......."
     echo "From $SOURCE_DIR"
     osm --config "$OSM_CONFIG_FILE" copy "$SOURCE_DIR" ceph:"$REDIS_BUCKET"/"$REDIS_FOLDER/$REDIS_SNAPSHOT" -v
 
+    # the operator reads the manifest from the termination message of the container
+    cp manifest.json /dev/termination-log || true
     echo "Backup successful"
     ;;
   restore)
@@ -122,8 +227,126 @@ case "$op" in
     REDIS_SNAPSHOT=${REDIS_SNAPSHOT}-${index}
     SOURCE_SNAPSHOT="$REDIS_BUCKET"/"$REDIS_FOLDER/$REDIS_SNAPSHOT"
     echo "From $SOURCE_SNAPSHOT"
+    rm -f manifest.json dump.rdb.*
     osm --config "$OSM_CONFIG_FILE" sync ceph:"$SOURCE_SNAPSHOT" "$REDIS_DATA_DIR" -v
 
+    dump_file=$(ls dump.rdb.* 2> /dev/null | head -n 1 || true)
+    if [[ "${dump_file}" == *.enc ]]; then
+      if [ -z "${REDIS_BACKUP_KEY}" ]; then
+        fail "snapshot ${REDIS_SNAPSHOT} is encrypted but no encryption key is configured"
+      fi
+      echo "Decrypting dump file......"
+      if ! backupcrypt decrypt < "${dump_file}" > "${dump_file%.enc}" 2> decrypt.log; then
+        msg="unable to decrypt snapshot ${REDIS_SNAPSHOT}: $(cat decrypt.log)"
+        rm -f "${dump_file%.enc}" decrypt.log
+        fail "${msg}"
+      fi
+      rm -f "${dump_file}" decrypt.log
+      dump_file=${dump_file%.enc}
+    fi
+    if [ -n "${dump_file}" ] && [ "${dump_file}" != "dump.rdb" ]; then
+      # the codec is picked from the extension of the dump file
+      echo "Decompressing ${dump_file}......"
+      if ! $(decompressor "${dump_file}") < "${dump_file}" > dump.rdb.tmp; then
+        rm -f dump.rdb.tmp
+        fail "unable to decompress snapshot ${REDIS_SNAPSHOT} from ${dump_file}"
+      fi
+      mv dump.rdb.tmp dump.rdb
+      rm -f "${dump_file}"
+    fi
+
+    if [ -f manifest.json ]; then
+      expected=$(sed -n 's/.*"sha256":"\([0-9a-f]*\)".*/\1/p' manifest.json)
+      actual=$(sha256sum dump.rdb | awk '{print $1}')
+      if [ "${expected}" != "${actual}" ]; then
+        rm -f dump.rdb
+        fail "checksum mismatch for snapshot ${REDIS_SNAPSHOT}: manifest sha256 ${expected}, dump.rdb sha256 ${actual}"
+      fi
+      echo "Checksum verified"
+    else
+      echo "No manifest found in snapshot ${REDIS_SNAPSHOT}, skip checksum verification"
+    fi
+
+    echo "Recovery successful"
+    ;;
+  ship-aof)
+    # ships the AOF of the redis node of the pod while it is a master, the files of a shard are stored as
+    #   <folder>/<shard>/<node id>/appendonlydir/*        the AOF files, never removed from the bucket
+    #   <folder>/<shard>/<node id>/manifests/<unix time>  the AOF manifest in use since that time
+    #   <folder>/<shard>/<node id>/nodes.conf             the cluster config of the node
+    #   <folder>/<shard>/watermark                       "<unix time> <node id>", the writes before that time are shipped
+    index=$(echo "${POD_NAME}" | awk -F- '{print $(NF-1)}')
+    SHARD_DIR="$REDIS_BUCKET"/"$REDIS_FOLDER/$index"
+    AOF_DIR="$REDIS_DATA_DIR"/appendonlydir
+    WORK_DIR=$(mktemp -d)
+    echo "Shipping AOF of shard ${index} to ${SHARD_DIR} every ${REDIS_INTERVAL}s"
+    while true; do
+      sleep "${REDIS_INTERVAL}"
+      role=$(redis-cli ${REDIS_CLI_ARGS} -h localhost -a "${REDIS_PASSWORD}" ROLE 2> /dev/null | head -n 1 || true)
+      if [ "${role}" != "master" ] || [ ! -f "${AOF_DIR}/appendonly.aof.manifest" ]; then
+        continue
+      fi
+      node_id=$(redis-cli ${REDIS_CLI_ARGS} -h localhost -a "${REDIS_PASSWORD}" CLUSTER MYID 2> /dev/null || true)
+      if [ -z "${node_id}" ]; then
+        continue
+      fi
+      # the writes before now are in the files referenced by the manifest copied before the AOF files
+      now=$(date +%s)
+      cp "${AOF_DIR}/appendonly.aof.manifest" "${WORK_DIR}/manifest" || continue
+      redis-cli ${REDIS_CLI_ARGS} -h localhost -a "${REDIS_PASSWORD}" CLUSTER NODES 2> /dev/null | grep myself > "${WORK_DIR}/nodes.conf" || true
+      target=ceph:"${SHARD_DIR}/${node_id}"
+      if ! osm --config "$OSM_CONFIG_FILE" copy "${AOF_DIR}" "${target}/appendonlydir"; then
+        echo "Unable to ship the AOF files, retrying"
+        continue
+      fi
+      if ! cmp -s "${WORK_DIR}/manifest" "${WORK_DIR}/shipped.manifest"; then
+        if ! osm --config "$OSM_CONFIG_FILE" copyto "${WORK_DIR}/manifest" "${target}/manifests/${now}"; then
+          echo "Unable to ship the AOF manifest, retrying"
+          continue
+        fi
+        cp "${WORK_DIR}/manifest" "${WORK_DIR}/shipped.manifest"
+      fi
+      if [ -s "${WORK_DIR}/nodes.conf" ]; then
+        osm --config "$OSM_CONFIG_FILE" copyto "${WORK_DIR}/nodes.conf" "${target}/nodes.conf" || true
+      fi
+      echo "${now} ${node_id}" | osm --config "$OSM_CONFIG_FILE" rcat ceph:"${SHARD_DIR}/watermark" || true
+    done
+    ;;
+  restore-aof)
+    echo "Pulling AOF from the backend"
+    if [ "${REDIS_RESTORE_SUCCEEDED}" == "1" ];then
+      echo "Has been restored successfully"
+      exit 0
+    fi
+    if [ -z "${REDIS_TIMESTAMP}" ]; then
+      fail "no timestamp to restore the AOF to"
+    fi
+    index=$(echo "${POD_NAME}" | awk -F- '{print $(NF-1)}')
+    SHARD_DIR="$REDIS_BUCKET"/"$REDIS_FOLDER/$index"
+    RESTORE_DIR="$REDIS_DATA_DIR"/aof-restore
+    echo "From $SHARD_DIR"
+    # the manifest in use at the timestamp is the last one shipped before it, by any master of the shard
+    files=$(osm --config "$OSM_CONFIG_FILE" lsf -R --files-only ceph:"${SHARD_DIR}") \
+      || fail "unable to list the AOF of shard ${index}"
+    manifest=$(echo "${files}" \
+      | awk -F/ -v ts="${REDIS_TIMESTAMP}" '$2 == "manifests" && $3 <= ts {print $3, $0}' \
+      | sort -n | tail -n 1 | awk '{print $2}')
+    if [ -z "${manifest}" ]; then
+      fail "no AOF of shard ${index} was shipped before ${REDIS_TIMESTAMP}"
+    fi
+    node_id=$(echo "${manifest}" | cut -d/ -f1)
+    echo "Restoring AOF of node ${node_id} with manifest ${manifest}"
+    rm -rf "${RESTORE_DIR}" dump.rdb appendonlydir
+    mkdir -p "${RESTORE_DIR}/appendonlydir"
+    osm --config "$OSM_CONFIG_FILE" copyto ceph:"${SHARD_DIR}/${manifest}" "${RESTORE_DIR}/appendonlydir/appendonly.aof.manifest" -v \
+      || fail "unable to pull manifest ${manifest}"
+    for file in $(awk '{for (i = 1; i < NF; i++) if ($i == "file") print $(i + 1)}' "${RESTORE_DIR}/appendonlydir/appendonly.aof.manifest"); do
+      osm --config "$OSM_CONFIG_FILE" copyto ceph:"${SHARD_DIR}/${node_id}/appendonlydir/${file}" "${RESTORE_DIR}/appendonlydir/${file}" -v \
+        || fail "unable to pull ${file} of node ${node_id}"
+    done
+    osm --config "$OSM_CONFIG_FILE" copyto ceph:"${SHARD_DIR}/${node_id}/nodes.conf" nodes.conf -v \
+      || fail "unable to pull nodes.conf of node ${node_id}"
+    # the AOF is replayed up to the timestamp by the replay-aof init container
     echo "Recovery successful"
     ;;
   *)
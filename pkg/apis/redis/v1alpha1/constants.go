@@ -13,6 +13,16 @@ const (
 	LabelNameKey      = "distributed-redis-cluster"
 	StatefulSetLabel  = "statefulSet"
 	PasswordENV       = "REDIS_PASSWORD"
+	BackupKeyENV      = "REDIS_BACKUP_KEY"
+	// RedisContainerName is the name of the redis server container of the pods of a DistributedRedisCluster.
+	RedisContainerName = "redis"
+	// TLSIssuerKind and TLSClusterIssuerKind are the kinds of the cert-manager issuers of TLSIssuerReference.
+	TLSIssuerKind        = "Issuer"
+	TLSClusterIssuerKind = "ClusterIssuer"
+	// OperatorACLUser is the ACL user of the operator on the clusters with Spec.Users.
+	OperatorACLUser = "drc-operator"
+	// TLSCAKey is the key of the CA certificate in the TLS Secret, next to tls.crt and tls.key.
+	TLSCAKey = "ca.crt"
 )
 
 // RedisRole RedisCluster Node Role type
@@ -68,8 +78,44 @@ const (
 
 	BackupKey         = ResourceSingularBackup + "." + GenericKey
 	LabelBackupStatus = BackupKey + "/status"
+	// LabelBackupName is set on the pods of a backup job to the name of the RedisClusterBackup
+	LabelBackupName = BackupKey + "/name"
+	// LabelBackupSchedule is set on the backups created by a RedisClusterBackupSchedule
+	LabelBackupSchedule = BackupKey + "/schedule"
 
 	AnnotationJobType = GenericKey + "/job-type"
+	// AnnotationRestore is set on a DistributedRedisCluster to the name of the RedisClusterRestore
+	// that is restoring it, the cluster is not reconciled until it is removed.
+	AnnotationRestore = GenericKey + "/restore"
+	// AnnotationKnownGoodTemplate is set on the StatefulSets of a DistributedRedisCluster to the JSON of
+	// their last pod template the cluster was OK with, it is restored by an automatic rollback.
+	AnnotationKnownGoodTemplate = GenericKey + "/known-good-template"
+	// AnnotationConfigHash is set on the pods of a DistributedRedisCluster to the hash of the
+	// restart-required parameters of its config.
+	AnnotationConfigHash = GenericKey + "/config-hash"
+	// AnnotationPodTemplateHash is set on the pods of a DistributedRedisCluster to the hash of the pod
+	// template of its spec.
+	AnnotationPodTemplateHash = GenericKey + "/pod-template-hash"
+	// AnnotationPasswordRotationTime is set on the Secret of the password the nodes accept to the time,
+	// in RFC 3339, they started to accept the new password. It is removed with the previous password.
+	AnnotationPasswordRotationTime = GenericKey + "/password-rotation-time"
+	// AnnotationAnnounceAddress is set on the pods of a DistributedRedisCluster with ExternalAccess to the
+	// address their redis node announces, ip:port@busport. The node announces it again when it restarts.
+	AnnotationAnnounceAddress = GenericKey + "/announce-address"
+	// LabelExternalAccess is set on the Services exposing the redis nodes with ExternalAccess.
+	LabelExternalAccess = GenericKey + "/external-access"
+	// AnnotationCanary is set on a DistributedRedisCluster to CanaryPromote or CanaryAbort to end
+	// its canary upgrade, the operator removes it once done.
+	AnnotationCanary = GenericKey + "/canary"
+	// CanaryPromote upgrades all the shards to the image of the canary.
+	CanaryPromote = "promote"
+	// CanaryAbort rolls the canary shards back to the stable image.
+	CanaryAbort = "abort"
+	// AnnotationProtected set to "true" on a DistributedRedisCluster makes the webhook reject its deletion.
+	AnnotationProtected = GenericKey + "/protected"
+	// AnnotationAcknowledgeNoReplicas must be set to "true" on a DistributedRedisCluster to reduce its
+	// ClusterReplicas to 0, the shards lose their data if their master fails.
+	AnnotationAcknowledgeNoReplicas = GenericKey + "/acknowledge-no-replicas"
 
 	JobTypeBackup  = "backup"
 	JobTypeRestore = "restore"
@@ -79,4 +125,13 @@ const (
 
 	BackupDumpDir  = "/data"
 	UtilVolumeName = "util-volume"
+
+	// DefaultMaxSlaveLag is the default maximum replication lag in bytes of a slave to be backed up from.
+	DefaultMaxSlaveLag int64 = 1 << 20
+	// DefaultContinuousBackupInterval is the default delay in seconds between two shipments of the AOF.
+	DefaultContinuousBackupInterval int32 = 10
+	// DefaultRollbackDeadline is the default time in seconds for the cluster to be OK after an update.
+	DefaultRollbackDeadline int32 = 600
+	// DefaultPasswordGracePeriod is the default time in seconds the previous password is accepted after a rotation.
+	DefaultPasswordGracePeriod int32 = 600
 )
@@ -3,8 +3,11 @@ package v1alpha1
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/robfig/cron"
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -24,7 +27,9 @@ func (in *DistributedRedisCluster) DefaultSpec(log logr.Logger) bool {
 		update = true
 	}
 
-	if in.Spec.Image == "" {
+	// a cluster restored from a backup runs the image the backup was taken with, the controller sets it
+	// from the backup once it is found.
+	if in.Spec.Image == "" && !(in.IsRestoreFromBackup() && !in.IsRestored()) {
 		in.Spec.Image = defaultRedisImage
 		update = true
 	}
@@ -73,6 +78,275 @@ func (in *DistributedRedisCluster) IsRestored() bool {
 	return in.Status.Restore.RestoreSucceeded > 0
 }
 
+// Validate checks the object storage of the continuous backup.
+func (in *ContinuousBackupSpec) Validate() error {
+	if in.Image == "" {
+		return fmt.Errorf("continuous backup [Image] is missing")
+	}
+	// the shipping sidecar only supports ceph s3, see osm.NewCephSecret.
+	if in.S3 == nil {
+		return fmt.Errorf("continuous backup [S3] is missing, only s3 is supported")
+	}
+	if in.IntervalSeconds < 0 {
+		return fmt.Errorf("continuous backup [IntervalSeconds] must not be negative")
+	}
+	return nil
+}
+
+// Interval returns the delay in seconds between two shipments of the AOF.
+func (in *ContinuousBackupSpec) Interval() int32 {
+	if in.IntervalSeconds == 0 {
+		return DefaultContinuousBackupInterval
+	}
+	return in.IntervalSeconds
+}
+
+// RedisConfig returns the config of the redis nodes. The continuous backup enables the AOF at runtime,
+// a node started with appendonly yes but without AOF does not load the dump file restored from a backup.
+func (in *DistributedRedisCluster) RedisConfig() map[string]string {
+	if in.Spec.ContinuousBackup == nil {
+		return in.Spec.Config
+	}
+	config := map[string]string{}
+	for key, value := range in.Spec.Config {
+		config[key] = value
+	}
+	config["appendonly"] = "yes"
+	// the timestamp annotations of the AOF let a restore replay it up to a point in time.
+	config["aof-timestamp-enabled"] = "yes"
+	return config
+}
+
+// Validate checks that the canary shards are distinct shards of the cluster.
+func (in *CanarySpec) Validate(masterSize int32) error {
+	if len(in.Shards) == 0 {
+		return fmt.Errorf("canary [Shards] is empty")
+	}
+	seen := map[int]bool{}
+	for _, shard := range in.Shards {
+		if shard < 0 || shard >= int(masterSize) {
+			return fmt.Errorf("canary shard %d is out of range [0, %d)", shard, masterSize)
+		}
+		if seen[shard] {
+			return fmt.Errorf("canary shard %d is duplicated", shard)
+		}
+		seen[shard] = true
+	}
+	return nil
+}
+
+// Includes returns true if the shard is part of the canary.
+func (in *CanarySpec) Includes(shard int) bool {
+	for _, s := range in.Shards {
+		if s == shard {
+			return true
+		}
+	}
+	return false
+}
+
+// ShardImage returns the redis image of the shard, the shards out of a canary keep the stable image.
+func (in *DistributedRedisCluster) ShardImage(shard int) string {
+	if in.Spec.Canary == nil || in.Status.Canary == nil || in.Spec.Canary.Includes(shard) {
+		return in.Spec.Image
+	}
+	return in.Status.Canary.StableImage
+}
+
+// ShardOverride returns the override of the shard, or nil if it has none.
+func (in *DistributedRedisCluster) ShardOverride(shard int) *ShardOverride {
+	for i := range in.Spec.ShardOverrides {
+		if in.Spec.ShardOverrides[i].Shard == shard {
+			return &in.Spec.ShardOverrides[i]
+		}
+	}
+	return nil
+}
+
+// ShardResources returns the resources of the redis nodes of the shard.
+func (in *DistributedRedisCluster) ShardResources(shard int) *v1.ResourceRequirements {
+	if override := in.ShardOverride(shard); override != nil && override.Resources != nil {
+		return override.Resources
+	}
+	return in.Spec.Resources
+}
+
+// validateShards checks that the overrides are for distinct shards.
+func (in *DistributedRedisClusterSpec) validateShards() error {
+	seen := map[int]bool{}
+	for _, override := range in.ShardOverrides {
+		if override.Shard < 0 {
+			return fmt.Errorf("shardOverrides [Shard] %d must not be negative", override.Shard)
+		}
+		if seen[override.Shard] {
+			return fmt.Errorf("shardOverrides [Shard] %d is duplicated", override.Shard)
+		}
+		seen[override.Shard] = true
+	}
+	return nil
+}
+
+// validatePodTemplate rejects the fields of the pod template that have their own field in the spec, the
+// StatefulSets would otherwise be updated at every reconcile.
+func (in *DistributedRedisClusterSpec) validatePodTemplate() error {
+	template := in.PodTemplate
+	if template == nil {
+		return nil
+	}
+	podSpec := template.Spec
+	switch {
+	case podSpec.NodeSelector != nil:
+		return fmt.Errorf("podTemplate [NodeSelector] is not supported, use the nodeSelector of the spec")
+	case podSpec.Tolerations != nil:
+		return fmt.Errorf("podTemplate [Tolerations] is not supported, use the toleRations of the spec")
+	case podSpec.Affinity != nil:
+		return fmt.Errorf("podTemplate [Affinity] is not supported, use the affinity of the spec")
+	}
+	for _, container := range append(podSpec.InitContainers, podSpec.Containers...) {
+		if container.Name == "" {
+			return fmt.Errorf("podTemplate container [Name] is missing, the containers are merged by name")
+		}
+		if container.Name != RedisContainerName {
+			continue
+		}
+		if container.Image != "" {
+			return fmt.Errorf("podTemplate [Image] of the %s container is not supported, use the image of the spec", RedisContainerName)
+		}
+		if container.Resources.Limits != nil || container.Resources.Requests != nil {
+			return fmt.Errorf("podTemplate [Resources] of the %s container is not supported, use the resources of the spec", RedisContainerName)
+		}
+	}
+	return nil
+}
+
+// Validate checks the deadline of the automatic rollback.
+func (in *AutoRollbackSpec) Validate() error {
+	if in.DeadlineSeconds < 0 {
+		return fmt.Errorf("autoRollback [DeadlineSeconds] must not be negative")
+	}
+	return nil
+}
+
+// Deadline returns the time for the cluster to be OK after an update before it is rolled back.
+func (in *AutoRollbackSpec) Deadline() time.Duration {
+	if in.DeadlineSeconds == 0 {
+		return time.Duration(DefaultRollbackDeadline) * time.Second
+	}
+	return time.Duration(in.DeadlineSeconds) * time.Second
+}
+
+// Validate checks the grace period of the password rotation.
+func (in *PasswordRotationSpec) Validate() error {
+	if in.GracePeriodSeconds < 0 {
+		return fmt.Errorf("passwordRotation [GracePeriodSeconds] must not be negative")
+	}
+	return nil
+}
+
+// PasswordGracePeriod returns the time the previous password is accepted after a rotation.
+func (in *DistributedRedisCluster) PasswordGracePeriod() time.Duration {
+	if in.Spec.PasswordRotation == nil || in.Spec.PasswordRotation.GracePeriodSeconds == 0 {
+		return time.Duration(DefaultPasswordGracePeriod) * time.Second
+	}
+	return time.Duration(in.Spec.PasswordRotation.GracePeriodSeconds) * time.Second
+}
+
+// IsRolledBack returns true if the current spec has been rolled back, its StatefulSets are not updated
+// until the spec changes.
+func (in *DistributedRedisCluster) IsRolledBack() bool {
+	return in.Status.Rollback != nil && in.Status.Rollback.Generation == in.Generation
+}
+
+// ContinuousBackupLocation returns the folder of the AOF shipped by the continuous backup.
+func (in *DistributedRedisCluster) ContinuousBackupLocation() string {
+	spec := in.Spec.ContinuousBackup
+	if spec.Folder != "" {
+		return spec.Folder
+	}
+	return filepath.Join(spec.S3.Prefix, DatabaseNamePrefix, in.Namespace, in.Name, "aof")
+}
+
+// ContinuousBackupSecretName returns the name of the osm config secret of the shipping sidecar.
+func (in *DistributedRedisCluster) ContinuousBackupSecretName() string {
+	return fmt.Sprintf("osmconfig-aof-%v", in.Name)
+}
+
+// Validate checks that the certificate comes either from a Secret or from a cert-manager issuer.
+func (in *TLSSpec) Validate() error {
+	if (in.SecretName == "") == (in.IssuerRef == nil) {
+		return fmt.Errorf("tls needs either [SecretName] or [IssuerRef]")
+	}
+	if in.IssuerRef != nil {
+		if in.IssuerRef.Name == "" {
+			return fmt.Errorf("tls [IssuerRef] name is missing")
+		}
+		if kind := in.IssuerRef.Kind; kind != "" && kind != TLSIssuerKind && kind != TLSClusterIssuerKind {
+			return fmt.Errorf("tls [IssuerRef] kind %q is neither %s nor %s", kind, TLSIssuerKind, TLSClusterIssuerKind)
+		}
+	}
+	return nil
+}
+
+// Validate checks the type of the Services exposing the redis nodes.
+func (in *ExternalAccessSpec) Validate() error {
+	if in.Type != v1.ServiceTypeLoadBalancer && in.Type != v1.ServiceTypeNodePort {
+		return fmt.Errorf("externalAccess [Type] %q is neither %s nor %s", in.Type,
+			v1.ServiceTypeLoadBalancer, v1.ServiceTypeNodePort)
+	}
+	return nil
+}
+
+// TLSSecretName returns the name of the Secret holding the certificate of the redis nodes.
+func (in *DistributedRedisCluster) TLSSecretName() string {
+	if in.Spec.TLS.SecretName != "" {
+		return in.Spec.TLS.SecretName
+	}
+	return fmt.Sprintf("drc-%s-tls", in.Name)
+}
+
+// validateUserRules checks the names and the rules of the ACL users, the rules are single words of the ACL syntax.
+func (in *DistributedRedisClusterSpec) validateUserRules() error {
+	names := map[string]bool{}
+	for _, user := range in.Users {
+		if user.Name == "" || strings.ContainsAny(user.Name, " \t\n") {
+			return fmt.Errorf("invalid user name %q", user.Name)
+		}
+		if user.Name == "default" || user.Name == OperatorACLUser {
+			return fmt.Errorf("user %s is managed by the operator", user.Name)
+		}
+		if names[user.Name] {
+			return fmt.Errorf("duplicate user %s", user.Name)
+		}
+		names[user.Name] = true
+		if user.PasswordSecret.Name == "" {
+			return fmt.Errorf("user %s [PasswordSecret] is missing", user.Name)
+		}
+		for _, cmd := range user.Commands {
+			if len(cmd) < 2 || cmd[0] != '+' && cmd[0] != '-' || strings.ContainsAny(cmd, " \t\n") {
+				return fmt.Errorf("user %s: invalid command rule %q, must be +<command>, -<command>, +@<category> or -@<category>", user.Name, cmd)
+			}
+		}
+		for _, key := range user.Keys {
+			if key == "" || strings.HasPrefix(key, "~") || strings.ContainsAny(key, " \t\n") {
+				return fmt.Errorf("user %s: invalid key pattern %q, the ~ prefix is added by the operator", user.Name, key)
+			}
+		}
+	}
+	return nil
+}
+
+// CurrentPasswordSecretName returns the name of the Secret holding the password the redis nodes accept, the
+// pods read it from there. The operator copies the password of the PasswordSecret into it once the nodes
+// accept the new password.
+func (in *DistributedRedisCluster) CurrentPasswordSecretName() string {
+	return fmt.Sprintf("drc-%s-password", in.Name)
+}
+
+// OperatorSecretName returns the name of the Secret holding the password of the ACL user of the operator.
+func (in *DistributedRedisCluster) OperatorSecretName() string {
+	return fmt.Sprintf("drc-%s-operator", in.Name)
+}
+
 func defaultResource() *v1.ResourceRequirements {
 	return &v1.ResourceRequirements{
 		Requests: v1.ResourceList{
@@ -111,10 +385,93 @@ func (in *RedisClusterBackup) Validate() error {
 			return fmt.Errorf("bakcup [SecretName] is missing")
 		}
 	}
+	if in.Spec.MaxSlaveLag < 0 {
+		return fmt.Errorf("backup [MaxSlaveLag] must not be negative")
+	}
+	switch in.Spec.Compression {
+	case "", BackupCompressionNone, BackupCompressionGzip, BackupCompressionZstd, BackupCompressionLz4:
+	default:
+		return fmt.Errorf("backup [Compression] %q is not supported, must be one of none, gzip, zstd or lz4", in.Spec.Compression)
+	}
+	if in.Spec.Encryption != nil {
+		if in.Spec.Encryption.KeySecret.Name == "" || in.Spec.Encryption.KeySecret.Key == "" {
+			return fmt.Errorf("backup [Encryption.KeySecret] name and key are required")
+		}
+	}
+	return nil
+}
+
+// MaxSlaveLag returns the maximum replication lag of a slave to be backed up from.
+func (in *RedisClusterBackup) MaxSlaveLag() int64 {
+	if in.Spec.MaxSlaveLag == 0 {
+		return DefaultMaxSlaveLag
+	}
+	return in.Spec.MaxSlaveLag
+}
+
+// Compression returns the codec the snapshots are compressed with.
+func (in *RedisClusterBackup) Compression() BackupCompression {
+	if in.Spec.Compression == "" {
+		return BackupCompressionNone
+	}
+	return in.Spec.Compression
+}
+
+func (in *RedisClusterBackupSchedule) Validate() error {
+	if in.Spec.RedisClusterName == "" {
+		return fmt.Errorf("backup schedule [RedisClusterName] is missing")
+	}
+	if _, err := cron.ParseStandard(in.Spec.Schedule); err != nil {
+		return fmt.Errorf("unparseable schedule %q: %v", in.Spec.Schedule, err)
+	}
+	if policy := in.Spec.Retention; policy != nil {
+		if policy.KeepLast < 0 || policy.KeepDaily < 0 || policy.KeepWeekly < 0 || policy.KeepMonthly < 0 {
+			return fmt.Errorf("backup schedule retention [keep*] must not be negative")
+		}
+		if policy.MaxAge != nil && policy.MaxAge.Duration <= 0 {
+			return fmt.Errorf("backup schedule retention [MaxAge] must be positive")
+		}
+	}
+	return nil
+}
+
+func (in *RedisClusterRestore) Validate() error {
+	if in.Spec.RedisClusterName == "" {
+		return fmt.Errorf("restore [RedisClusterName] is missing")
+	}
+	// the AOF is only replayed by the init containers of a new cluster.
+	if in.Spec.BackupSource.RestoreTimestamp != nil {
+		return fmt.Errorf("restore [BackupSource.RestoreTimestamp] is only supported by the init of a DistributedRedisCluster")
+	}
+	return in.Spec.BackupSource.Validate()
+}
+
+// Validate checks that the source points to a RedisClusterBackup or to a bucket location.
+func (in *BackupSourceSpec) Validate() error {
+	if in.Bucket == nil {
+		if in.Name == "" {
+			return fmt.Errorf("restore [BackupSource.Name] is missing")
+		}
+		if in.RestoreTimestamp != nil {
+			return fmt.Errorf("restore [BackupSource.RestoreTimestamp] requires the [BackupSource.Bucket] of a continuous backup")
+		}
+		return nil
+	}
+	if in.Bucket.Folder == "" {
+		return fmt.Errorf("restore [BackupSource.Bucket.Folder] is missing")
+	}
+	// the restore pods only support ceph s3, see osm.NewCephSecret.
+	if in.Bucket.S3 == nil {
+		return fmt.Errorf("restore [BackupSource.Bucket.S3] is missing, only s3 is supported")
+	}
 	return nil
 }
 
+// Location returns the folder of the backup in the bucket.
 func (in *RedisClusterBackup) Location() (string, error) {
+	if in.Status.Location != "" {
+		return in.Status.Location, nil
+	}
 	spec := in.Spec.Backend
 	timePrefix := in.Status.StartTime.Format("20060102150405")
 	if spec.S3 != nil {
@@ -135,6 +492,20 @@ func (in *RedisClusterBackup) OSMSecretName() string {
 	return fmt.Sprintf("osmconfig-%v", in.Name)
 }
 
+// EncryptionKeyEnv returns the environment variable holding the encryption key of the snapshots,
+// nil if the backup is not encrypted.
+func (in *RedisClusterBackup) EncryptionKeyEnv() *v1.EnvVar {
+	if in.Spec.Encryption == nil {
+		return nil
+	}
+	return &v1.EnvVar{
+		Name: BackupKeyENV,
+		ValueFrom: &v1.EnvVarSource{
+			SecretKeyRef: in.Spec.Encryption.KeySecret.DeepCopy(),
+		},
+	}
+}
+
 func (in *RedisClusterBackup) JobName() string {
 	return fmt.Sprintf("redisbackup-%v", in.Name)
 }
@@ -4,6 +4,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	store "kmodules.xyz/objectstore-api/api/v1"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -31,6 +32,143 @@ type DistributedRedisClusterSpec struct {
 	PasswordSecret  *corev1.LocalObjectReference `json:"passwordSecret,omitempty"`
 	Monitor         *AgentSpec                   `json:"monitor,omitempty"`
 	Init            *InitSpec                    `json:"init,omitempty"`
+	// ContinuousBackup ships the AOF of the masters to the object storage, the cluster can then be
+	// restored to any point in time covered by the shipped AOF. It requires Redis 7 or later.
+	// +optional
+	ContinuousBackup *ContinuousBackupSpec `json:"continuousBackup,omitempty"`
+	// Canary limits the upgrade of the image to some shards, the other shards keep their image until
+	// the canary is promoted or aborted with the redis.kun/canary annotation.
+	// +optional
+	Canary *CanarySpec `json:"canary,omitempty"`
+	// AutoRollback rolls the StatefulSets back to their last known-good template when the cluster is
+	// not OK within a deadline after an update of its spec.
+	// +optional
+	AutoRollback *AutoRollbackSpec `json:"autoRollback,omitempty"`
+	// ShardOverrides replace the resources and the scheduling of the spec for the pods of some shards.
+	// +optional
+	ShardOverrides []ShardOverride `json:"shardOverrides,omitempty"`
+	// RoleHints steer the masters and the replicas of the shards to different nodes.
+	// +optional
+	RoleHints *RoleHints `json:"roleHints,omitempty"`
+	// PodTemplate is merged into the pod template generated for the StatefulSets with a strategic merge
+	// patch, e.g. to set a priority class, add sidecars and volumes or change a field of the redis container.
+	// The containers, the volumes and the other lists are merged by name, the fields of the template that
+	// are not set keep their generated value.
+	// +optional
+	PodTemplate *corev1.PodTemplateSpec `json:"podTemplate,omitempty"`
+	// TLS serves the clients, the replication and the cluster bus over TLS only. It requires Redis 6
+	// or later and cannot be enabled or disabled on an existing cluster.
+	// +optional
+	TLS *TLSSpec `json:"tls,omitempty"`
+	// Users are the ACL users of the redis nodes, the applications then authenticate with their own user rather
+	// than with the password of the default user. The operator manages them with its own drc-operator user.
+	// They require Redis 6 or later and the PasswordSecret.
+	// +optional
+	Users []RedisUser `json:"users,omitempty"`
+	// PasswordRotation configures the rotation of the password when the PasswordSecret or its password
+	// change, the nodes accept both passwords during a grace period and are not restarted.
+	// +optional
+	PasswordRotation *PasswordRotationSpec `json:"passwordRotation,omitempty"`
+	// ExternalAccess exposes each redis node with its own Service and makes the nodes announce the address
+	// of their Service, the clients outside the Kubernetes cluster can then follow the MOVED redirections.
+	// It cannot be enabled or disabled on an existing cluster.
+	// +optional
+	ExternalAccess *ExternalAccessSpec `json:"externalAccess,omitempty"`
+	// AnnounceHostnames makes the redis nodes announce the DNS name of their pod behind the headless service
+	// of their shard and redirect the clients with it, the operator then identifies the nodes by hostname.
+	// It requires Redis 7 and cannot be changed on an existing cluster.
+	// +optional
+	AnnounceHostnames bool `json:"announceHostnames,omitempty"`
+}
+
+// ExternalAccessSpec defines the Services exposing the redis nodes outside the Kubernetes cluster.
+type ExternalAccessSpec struct {
+	// Type is LoadBalancer or NodePort. A node behind a LoadBalancer announces the IP of its load balancer,
+	// a node behind a NodePort announces the IP of the Kubernetes node running its pod.
+	Type corev1.ServiceType `json:"type"`
+	// Annotations are added to the Services, e.g. to configure the load balancers.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// PasswordRotationSpec defines how long the previous password is accepted after a rotation.
+type PasswordRotationSpec struct {
+	// GracePeriodSeconds is the time the nodes accept the previous password after they accept the new one,
+	// for the clients to switch to the new password. It requires Redis 6 or later, Redis 5 nodes only
+	// accept the new password. Defaults to 600.
+	// +optional
+	GracePeriodSeconds int32 `json:"gracePeriodSeconds,omitempty"`
+}
+
+// RedisUser is an ACL user of the redis nodes.
+type RedisUser struct {
+	Name string `json:"name"`
+	// PasswordSecret holds the password of the user in its password key.
+	PasswordSecret corev1.LocalObjectReference `json:"passwordSecret"`
+	// Commands are the ACL command rules of the user, e.g. +@read, -@dangerous or +get. A user without
+	// commands cannot run any command.
+	// +optional
+	Commands []string `json:"commands,omitempty"`
+	// Keys are the key patterns the user can access, e.g. cache:*.
+	// +optional
+	Keys []string `json:"keys,omitempty"`
+}
+
+// TLSSpec is the certificate of the redis nodes, either from an existing Secret or issued by cert-manager.
+// The Secret holds the tls.crt, tls.key and ca.crt keys, a change of the Secret is reloaded by the
+// redis nodes without restarting them.
+type TLSSpec struct {
+	// SecretName is the Secret holding the certificate of the redis nodes.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+	// IssuerRef is the cert-manager issuer of the certificate, the operator then owns a Certificate
+	// writing the Secret drc-<name>-tls.
+	// +optional
+	IssuerRef *TLSIssuerReference `json:"issuerRef,omitempty"`
+}
+
+// TLSIssuerReference is a cert-manager Issuer or ClusterIssuer.
+type TLSIssuerReference struct {
+	Name string `json:"name"`
+	// Kind is Issuer or ClusterIssuer, defaults to Issuer.
+	// +optional
+	Kind string `json:"kind,omitempty"`
+}
+
+// ShardOverride overrides the pods of the StatefulSet drc-<name>-<shard>, the fields that are not set
+// are taken from the spec of the cluster.
+type ShardOverride struct {
+	Shard        int                          `json:"shard"`
+	Resources    *corev1.ResourceRequirements `json:"resources,omitempty"`
+	NodeSelector map[string]string            `json:"nodeSelector,omitempty"`
+	Tolerations  []corev1.Toleration          `json:"tolerations,omitempty"`
+	Affinity     *corev1.Affinity             `json:"affinity,omitempty"`
+}
+
+// RoleHints select the nodes preferred for the masters and for the replicas. The master and the replicas of
+// a shard share the pod template of its StatefulSet, the hints choose which pod of the shard is elected master
+// when the cluster is created, a master is lost or a master is upgraded. They need the operator to read the nodes.
+type RoleHints struct {
+	// MasterNodeSelector prefers the pods on the nodes matching it as masters.
+	// +optional
+	MasterNodeSelector map[string]string `json:"masterNodeSelector,omitempty"`
+	// ReplicaNodeSelector prefers the pods on the nodes matching it as replicas.
+	// +optional
+	ReplicaNodeSelector map[string]string `json:"replicaNodeSelector,omitempty"`
+}
+
+// AutoRollbackSpec defines when an update of the cluster is rolled back.
+type AutoRollbackSpec struct {
+	// DeadlineSeconds is the time for the cluster to be OK again after an update of its StatefulSets.
+	// Defaults to 600.
+	// +optional
+	DeadlineSeconds int32 `json:"deadlineSeconds,omitempty"`
+}
+
+// CanarySpec selects the shards running the image of the spec during a canary upgrade.
+type CanarySpec struct {
+	// Shards are the indices of the StatefulSets drc-<name>-<index> upgraded to the image.
+	Shards []int `json:"shards"`
 }
 
 type AgentSpec struct {
@@ -88,6 +226,42 @@ type BackupSourceSpec struct {
 	Name      string `json:"name"`
 	// Arguments to the restore job
 	Args []string `json:"args,omitempty"`
+	// RestoreTimestamp replays the AOF shipped by a continuous backup up to this time, the Bucket
+	// folder must be the location of the continuous backup.
+	// +optional
+	RestoreTimestamp *metav1.Time `json:"restoreTimestamp,omitempty"`
+	// Bucket restores the backup straight from its location in the object storage instead of
+	// the RedisClusterBackup Name, e.g. into another namespace or Kubernetes cluster.
+	// +optional
+	Bucket *BucketSourceSpec `json:"bucket,omitempty"`
+}
+
+// BucketSourceSpec is the location of a backup in the object storage.
+type BucketSourceSpec struct {
+	// Backend is the object storage holding the backup, its storage secret is read from the
+	// namespace of the restored cluster.
+	store.Backend `json:",inline"`
+	// Folder is the folder of the backup in the bucket, as reported in the status.location of the RedisClusterBackup.
+	Folder string `json:"folder"`
+}
+
+// ContinuousBackupSpec defines how the AOF of the masters is shipped to the object storage.
+type ContinuousBackupSpec struct {
+	// Image is the redis-tools image of the sidecar shipping the AOF.
+	Image string `json:"image"`
+	// Backend is the object storage the AOF is shipped to, only s3 is supported.
+	store.Backend `json:",inline"`
+	// Folder is the folder of the AOF in the bucket.
+	// Defaults to <prefix>/redis/<namespace>/<cluster>/aof.
+	// +optional
+	Folder string `json:"folder,omitempty"`
+	// IntervalSeconds is the delay between two shipments of the AOF, it bounds the recovery point objective.
+	// Defaults to 10.
+	// +optional
+	IntervalSeconds int32 `json:"intervalSeconds,omitempty"`
+	// Compute Resources required by the shipping sidecar.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
 }
 
 // RedisStorage defines the structure used to store the Redis Data
@@ -113,6 +287,140 @@ type DistributedRedisClusterStatus struct {
 	Nodes                []RedisClusterNode `json:"nodes"`
 	// +optional
 	Restore Restore `json:"restore"`
+	// ContinuousBackup reports the progress of the AOF shipping.
+	// +optional
+	ContinuousBackup *ContinuousBackupStatus `json:"continuousBackup,omitempty"`
+	// Upgrade reports the progress of the rolling upgrade of the redis nodes, it is removed once
+	// all the nodes run the update revision of their StatefulSet.
+	// +optional
+	Upgrade *UpgradeStatus `json:"upgrade,omitempty"`
+	// Canary is set while a canary upgrade waits to be promoted or aborted.
+	// +optional
+	Canary *CanaryStatus `json:"canary,omitempty"`
+	// Shards are the images of the shards, by shard index.
+	// +optional
+	Shards []ShardStatus `json:"shards,omitempty"`
+	// UpdateStartTime is the time of the first update of the StatefulSets since the cluster was
+	// last OK, it is removed once the cluster is OK again.
+	// +optional
+	UpdateStartTime *metav1.Time `json:"updateStartTime,omitempty"`
+	// Rollback reports the last automatic rollback of the cluster.
+	// +optional
+	Rollback *RollbackStatus `json:"rollback,omitempty"`
+	// Config reports the parameters of Spec.Config applied to the redis nodes.
+	// +optional
+	Config *ConfigStatus `json:"config,omitempty"`
+	// Memory reports the memory of the redis nodes, the webhook rejects the scale-downs the remaining
+	// masters cannot hold.
+	// +optional
+	Memory *MemoryStatus `json:"memory,omitempty"`
+	// TLS reports the certificate served by the redis nodes.
+	// +optional
+	TLS *TLSStatus `json:"tls,omitempty"`
+	// Users are the names of the ACL users set on the redis nodes, the users removed from the spec are
+	// deleted from the nodes.
+	// +optional
+	Users []string `json:"users,omitempty"`
+	// PasswordRotationTime is when the nodes started to accept the new password, it is removed once
+	// they no longer accept the previous password.
+	// +optional
+	PasswordRotationTime *metav1.Time `json:"passwordRotationTime,omitempty"`
+}
+
+// ConfigStatus is the state of the parameters of the config of the cluster.
+type ConfigStatus struct {
+	// Applied are the parameters all the redis nodes run with.
+	// +optional
+	Applied map[string]string `json:"applied,omitempty"`
+	// Pending are the restart-required parameters waiting for the restart of some redis nodes.
+	// +optional
+	Pending map[string]string `json:"pending,omitempty"`
+}
+
+// MemoryStatus is the memory of the redis nodes reported by INFO memory.
+type MemoryStatus struct {
+	// UsedMemory is the sum of the used_memory of the masters in bytes.
+	UsedMemory int64 `json:"usedMemory"`
+	// MaxMemory is the maxmemory of a redis node in bytes, 0 if unlimited.
+	MaxMemory int64 `json:"maxMemory"`
+}
+
+// TLSStatus is the state of the rollout of the certificate of the TLS Secret.
+type TLSStatus struct {
+	// SerialNumber is the serial number of the certificate of the Secret.
+	SerialNumber string `json:"serialNumber"`
+	// NotAfter is the expiry of the certificate of the Secret.
+	NotAfter metav1.Time `json:"notAfter"`
+	// OutdatedNodes are the addresses of the redis nodes still serving another certificate.
+	// +optional
+	OutdatedNodes []string `json:"outdatedNodes,omitempty"`
+}
+
+// RollbackStatus is an automatic rollback of the StatefulSets to their last known-good template.
+type RollbackStatus struct {
+	// Generation is the generation of the rolled back spec, it is not applied again until the spec changes.
+	Generation int64 `json:"generation"`
+	// Reason is why the cluster was rolled back.
+	Reason string      `json:"reason"`
+	Time   metav1.Time `json:"time"`
+}
+
+// CanaryStatus is the state of a canary upgrade.
+type CanaryStatus struct {
+	// StableImage is the image of the shards out of the canary, the cluster is rolled back to it on abort.
+	StableImage string `json:"stableImage"`
+}
+
+// ShardStatus is the image of a shard.
+type ShardStatus struct {
+	Index int `json:"index"`
+	// Image is the redis image of the StatefulSet of the shard.
+	Image string `json:"image"`
+	// Upgraded is true once all the pods of the shard run the image.
+	Upgraded bool `json:"upgraded"`
+}
+
+// UpgradePhase is the step of the upgrade of a shard.
+type UpgradePhase string
+
+const (
+	// UpgradePhaseReplicas restarts the outdated slaves of the shard, one at a time.
+	UpgradePhaseReplicas UpgradePhase = "UpgradingReplicas"
+	// UpgradePhaseFailover promotes an upgraded slave in sync with the outdated master.
+	UpgradePhaseFailover UpgradePhase = "FailingOver"
+	// UpgradePhaseMaster restarts the outdated master, once it is a slave or if the shard has no slave.
+	UpgradePhaseMaster UpgradePhase = "UpgradingMaster"
+)
+
+// UpgradeStatus is the progress of the rolling upgrade of the cluster, the shards are upgraded one at a time.
+type UpgradeStatus struct {
+	// Shard is the index of the StatefulSet being upgraded.
+	Shard int          `json:"shard"`
+	Phase UpgradePhase `json:"phase"`
+	// Pod is the pod being restarted, or the slave being promoted.
+	Pod string `json:"pod,omitempty"`
+	// UpgradedShards is the number of shards whose nodes all run the update revision.
+	UpgradedShards int32 `json:"upgradedShards"`
+	// LastTransitionTime is the time of the last change of Phase or Pod.
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+	Message            string       `json:"message,omitempty"`
+}
+
+// ContinuousBackupStatus defines the observed state of the continuous backup.
+type ContinuousBackupStatus struct {
+	// Location is the folder of the AOF in the bucket, it is the folder to restore from.
+	Location string `json:"location,omitempty"`
+	// Shards are the watermarks of the shards, by shard index.
+	Shards []ContinuousBackupShard `json:"shards,omitempty"`
+}
+
+// ContinuousBackupShard is the progress of the AOF shipping of a shard.
+type ContinuousBackupShard struct {
+	Index int `json:"index"`
+	// NodeID is the ID of the master the last AOF was shipped from.
+	NodeID string `json:"nodeID,omitempty"`
+	// Watermark is the time up to which the writes of the shard are in the object storage.
+	Watermark *metav1.Time `json:"watermark,omitempty"`
 }
 
 type Restore struct {
@@ -133,6 +441,12 @@ type RedisClusterNode struct {
 	PodName     string    `json:"podName"`
 	NodeName    string    `json:"nodeName"`
 	StatefulSet string    `json:"statefulSet"`
+	// ExternalAddress is the address the node announces with ExternalAccess, ip:port.
+	// +optional
+	ExternalAddress string `json:"externalAddress,omitempty"`
+	// Hostname is the hostname the node announces with AnnounceHostnames.
+	// +optional
+	Hostname string `json:"hostname,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -14,27 +14,27 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
+	"github.com/ucloud/redis-cluster-operator/pkg/redisconfig"
 	"github.com/ucloud/redis-cluster-operator/pkg/utils"
 )
 
 var log = logf.Log.WithName("drc-resource")
 
+var _ webhook.Defaulter = &DistributedRedisCluster{}
+
+// Default applies the defaults of DefaultSpec at admission time, the object is stored with them.
+func (in *DistributedRedisCluster) Default() {
+	log := log.WithValues("namespace", in.Namespace, "name", in.Name)
+	log.Info("Default")
+	in.DefaultSpec(log)
+}
+
 var _ webhook.Validator = &DistributedRedisCluster{}
 
 func (in *DistributedRedisCluster) ValidateCreate() error {
 	log := log.WithValues("namespace", in.Namespace, "name", in.Name)
 	log.Info("ValidateCreate")
-	if errs := utilvalidation.IsDNS1035Label(in.Spec.ServiceName); len(in.Spec.ServiceName) > 0 && len(errs) > 0 {
-		return fmt.Errorf("the custom service is invalid: invalid value: %s, %s", in.Spec.ServiceName, strings.Join(errs, ","))
-	}
-
-	if in.Spec.Resources != nil {
-		if errs := validation.ValidateResourceRequirements(in.Spec.Resources, field.NewPath("resources")); len(errs) > 0 {
-			return errs.ToAggregate()
-		}
-	}
-
-	return nil
+	return in.Spec.Validate()
 }
 
 func (in *DistributedRedisCluster) ValidateUpdate(old runtime.Object) error {
@@ -48,19 +48,48 @@ func (in *DistributedRedisCluster) ValidateUpdate(old runtime.Object) error {
 		return err
 	}
 
-	if errs := utilvalidation.IsDNS1035Label(in.Spec.ServiceName); len(in.Spec.ServiceName) > 0 && len(errs) > 0 {
-		return fmt.Errorf("the custom service is invalid: invalid value: %s, %s", in.Spec.ServiceName, strings.Join(errs, ","))
+	if err := in.Spec.Validate(); err != nil {
+		return err
+	}
+
+	if action, ok := in.Annotations[AnnotationCanary]; ok && action != CanaryPromote && action != CanaryAbort {
+		return fmt.Errorf("invalid %s annotation %q, must be %s or %s", AnnotationCanary, action, CanaryPromote, CanaryAbort)
 	}
 
-	if in.Spec.Resources != nil {
-		if errs := validation.ValidateResourceRequirements(in.Spec.Resources, field.NewPath("resources")); len(errs) > 0 {
-			return errs.ToAggregate()
-		}
+	if (in.Spec.TLS == nil) != (oldObj.Spec.TLS == nil) {
+		return fmt.Errorf("tls cannot be enabled or disabled on an existing cluster, " +
+			"the nodes with and without tls cannot talk to each other during the rolling update")
+	}
+
+	if (in.Spec.ExternalAccess == nil) != (oldObj.Spec.ExternalAccess == nil) ||
+		in.Spec.ExternalAccess != nil && in.Spec.ExternalAccess.Type != oldObj.Spec.ExternalAccess.Type {
+		return fmt.Errorf("externalAccess cannot be enabled, disabled or change type on an existing cluster, " +
+			"the clients and the nodes would lose the addresses the nodes announce")
+	}
+
+	if in.Spec.AnnounceHostnames != oldObj.Spec.AnnounceHostnames {
+		return fmt.Errorf("announceHostnames cannot be changed on an existing cluster, " +
+			"the nodes are started with the hostname they announce")
+	}
+
+	if err := validateScaleDown(in, oldObj); err != nil {
+		return err
+	}
+	// the operator starts the masters of a cluster restored from a backup without replicas until the restore succeeds.
+	restoring := in.IsRestoreFromBackup() && !in.IsRestored()
+	if !restoring && in.Spec.ClusterReplicas == 0 && oldObj.Spec.ClusterReplicas > 0 &&
+		in.Annotations[AnnotationAcknowledgeNoReplicas] != "true" {
+		return fmt.Errorf("reducing clusterReplicas to 0 loses the data of a shard whose master fails, "+
+			"set the %s annotation to true to acknowledge it", AnnotationAcknowledgeNoReplicas)
 	}
 
 	if oldObj.Status.Status == "" {
 		return nil
 	}
+	// aborting a canary rolls its shards back to the stable image whatever the status of the cluster.
+	if isCanaryAbort(in, oldObj) {
+		return nil
+	}
 	if compareObj(in, oldObj, log) && oldObj.Status.Status != ClusterStatusOK {
 		return fmt.Errorf("redis cluster status: [%s], wait for the status to become %s before operating", oldObj.Status.Status, ClusterStatusOK)
 	}
@@ -68,6 +97,181 @@ func (in *DistributedRedisCluster) ValidateUpdate(old runtime.Object) error {
 	return nil
 }
 
+func isCanaryAbort(new, old *DistributedRedisCluster) bool {
+	return old.Spec.Canary != nil && new.Spec.Canary == nil && old.Status.Canary != nil &&
+		new.Spec.Image == old.Status.Canary.StableImage && new.Spec.MasterSize == old.Spec.MasterSize
+}
+
+// Validate checks the spec of a cluster, it is called by the validating webhook and by the controller
+// for the clusters admitted while the webhook is disabled.
+func (in *DistributedRedisClusterSpec) Validate() error {
+	if errs := utilvalidation.IsDNS1035Label(in.ServiceName); len(in.ServiceName) > 0 && len(errs) > 0 {
+		return fmt.Errorf("the custom service is invalid: invalid value: %s, %s", in.ServiceName, strings.Join(errs, ","))
+	}
+
+	if in.Resources != nil {
+		if errs := validation.ValidateResourceRequirements(in.Resources, field.NewPath("resources")); len(errs) > 0 {
+			return errs.ToAggregate()
+		}
+	}
+
+	if err := in.validateContinuousBackup(); err != nil {
+		return err
+	}
+
+	if in.Canary != nil {
+		if err := in.Canary.Validate(in.MasterSize); err != nil {
+			return err
+		}
+	}
+
+	if in.AutoRollback != nil {
+		if err := in.AutoRollback.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if err := in.validatePasswordRotation(); err != nil {
+		return err
+	}
+
+	if err := redisconfig.Validate(in.Config, redisconfig.MajorVersion(in.Image)); err != nil {
+		return err
+	}
+
+	if err := in.validateShardOverrides(); err != nil {
+		return err
+	}
+
+	if err := in.validatePodTemplate(); err != nil {
+		return err
+	}
+
+	if err := in.validateTLS(); err != nil {
+		return err
+	}
+
+	if err := in.validateUsers(); err != nil {
+		return err
+	}
+
+	if in.ExternalAccess != nil {
+		if err := in.ExternalAccess.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return in.validateAnnounceHostnames()
+}
+
+// validateShardOverrides checks the shards and the resources of the shard overrides.
+func (in *DistributedRedisClusterSpec) validateShardOverrides() error {
+	if err := in.validateShards(); err != nil {
+		return err
+	}
+	for i, override := range in.ShardOverrides {
+		if override.Resources == nil {
+			continue
+		}
+		path := field.NewPath("shardOverrides").Index(i).Child("resources")
+		if errs := validation.ValidateResourceRequirements(override.Resources, path); len(errs) > 0 {
+			return errs.ToAggregate()
+		}
+	}
+	return nil
+}
+
+// validateTLS checks the certificate source and the redis version, Redis supports TLS since 6.0.
+func (in *DistributedRedisClusterSpec) validateTLS() error {
+	if in.TLS == nil {
+		return nil
+	}
+	if err := in.TLS.Validate(); err != nil {
+		return err
+	}
+	if major := redisconfig.MajorVersion(in.Image); major != 0 && major < 6 {
+		return fmt.Errorf("tls requires Redis 6 or later, the image %s is Redis %d", in.Image, major)
+	}
+	return nil
+}
+
+// validateAnnounceHostnames checks the redis version, Redis announces hostnames since 7.0. The hostnames resolve
+// inside the Kubernetes cluster only, the nodes exposed with ExternalAccess announce their external address.
+func (in *DistributedRedisClusterSpec) validateAnnounceHostnames() error {
+	if !in.AnnounceHostnames {
+		return nil
+	}
+	if in.ExternalAccess != nil {
+		return fmt.Errorf("announceHostnames cannot be used with externalAccess, the nodes announce their external address")
+	}
+	if major := redisconfig.MajorVersion(in.Image); major != 0 && major < 7 {
+		return fmt.Errorf("announceHostnames requires Redis 7 or later, the image %s is Redis %d", in.Image, major)
+	}
+	return nil
+}
+
+// validateContinuousBackup checks the object storage and the redis version, the AOF is shipped with its
+// timestamp annotations, aof-timestamp-enabled is supported since Redis 7.0.
+func (in *DistributedRedisClusterSpec) validateContinuousBackup() error {
+	if in.ContinuousBackup == nil {
+		return nil
+	}
+	if err := in.ContinuousBackup.Validate(); err != nil {
+		return err
+	}
+	if major := redisconfig.MajorVersion(in.Image); major != 0 && major < 7 {
+		return fmt.Errorf("continuousBackup requires Redis 7 or later, the image %s is Redis %d", in.Image, major)
+	}
+	return nil
+}
+
+// validatePasswordRotation checks the grace period and the redis version, Redis accepts several passwords
+// since 6.0, the nodes of Redis 5 only accept the new password once it is rotated.
+func (in *DistributedRedisClusterSpec) validatePasswordRotation() error {
+	if in.PasswordRotation == nil {
+		return nil
+	}
+	if err := in.PasswordRotation.Validate(); err != nil {
+		return err
+	}
+	if major := redisconfig.MajorVersion(in.Image); major != 0 && major < 6 {
+		return fmt.Errorf("passwordRotation requires Redis 6 or later, the image %s is Redis %d", in.Image, major)
+	}
+	return nil
+}
+
+// validateUsers checks the ACL users and the redis version, Redis supports ACL since 6.0. The users need the
+// password of the default user, which could otherwise be used without password.
+func (in *DistributedRedisClusterSpec) validateUsers() error {
+	if len(in.Users) == 0 {
+		return nil
+	}
+	if err := in.validateUserRules(); err != nil {
+		return err
+	}
+	if in.PasswordSecret == nil {
+		return fmt.Errorf("users require [PasswordSecret], the default user has no password otherwise")
+	}
+	if major := redisconfig.MajorVersion(in.Image); major != 0 && major < 6 {
+		return fmt.Errorf("users require Redis 6 or later, the image %s is Redis %d", in.Image, major)
+	}
+	return nil
+}
+
+// validateScaleDown rejects a scale-down whose remaining masters cannot hold the dataset of the cluster,
+// judged from the memory reported in its status.
+func validateScaleDown(new, old *DistributedRedisCluster) error {
+	memory := old.Status.Memory
+	if new.Spec.MasterSize >= old.Spec.MasterSize || memory == nil || memory.MaxMemory == 0 {
+		return nil
+	}
+	if capacity := int64(new.Spec.MasterSize) * memory.MaxMemory; memory.UsedMemory > capacity {
+		return fmt.Errorf("scaling down to %d masters is unsafe, the dataset uses %d bytes and the masters can hold %d bytes",
+			new.Spec.MasterSize, memory.UsedMemory, capacity)
+	}
+	return nil
+}
+
 func compareObj(new, old *DistributedRedisCluster, log logr.Logger) bool {
 	if utils.CompareInt32("MasterSize", new.Spec.MasterSize, old.Spec.MasterSize, log) {
 		return true
@@ -93,6 +297,9 @@ func compareObj(new, old *DistributedRedisCluster, log logr.Logger) bool {
 func (in *DistributedRedisCluster) ValidateDelete() error {
 	log := log.WithValues("namespace", in.Namespace, "name", in.Name)
 	log.Info("ValidateDelete")
+	if in.Annotations[AnnotationProtected] == "true" {
+		return fmt.Errorf("the cluster is protected, remove the %s annotation before deleting it", AnnotationProtected)
+	}
 	return nil
 }
 
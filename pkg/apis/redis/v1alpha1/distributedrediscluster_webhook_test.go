@@ -7,6 +7,7 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	store "kmodules.xyz/objectstore-api/api/v1"
 )
 
 func TestDistributedRedisCluster_ValidateCreate(t *testing.T) {
@@ -89,6 +90,257 @@ func TestDistributedRedisCluster_ValidateCreate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "canary shard out of range",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					MasterSize: 3,
+					Canary:     &CanarySpec{Shards: []int{1, 3}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative rollback deadline",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					AutoRollback: &AutoRollbackSpec{DeadlineSeconds: -1},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative password grace period",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					PasswordRotation: &PasswordRotationSpec{GracePeriodSeconds: -1},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "continuous backup on redis 5",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					Image: "redis:5.0.4-alpine",
+					ContinuousBackup: &ContinuousBackupSpec{
+						Image:   "redis-tools",
+						Backend: store.Backend{S3: &store.S3Spec{Bucket: "bucket"}},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "continuous backup on redis 7",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					Image: "redis:7.0",
+					ContinuousBackup: &ContinuousBackupSpec{
+						Image:   "redis-tools",
+						Backend: store.Backend{S3: &store.S3Spec{Bucket: "bucket"}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "password rotation on redis 5",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					Image:            "redis:5.0.4-alpine",
+					PasswordRotation: &PasswordRotationSpec{GracePeriodSeconds: 1800},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "password rotation on redis 6",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					Image:            "redis:6.2",
+					PasswordRotation: &PasswordRotationSpec{GracePeriodSeconds: 1800},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "forbidden config",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					Image:  "redis:5.0.4-alpine",
+					Config: map[string]string{"cluster-enabled": "no"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "config unknown to the redis version",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					Image:  "redis:5.0.4-alpine",
+					Config: map[string]string{"io-threads": "4"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "pod template overriding the image",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					PodTemplate: &corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: RedisContainerName, Image: "redis:6"}},
+						},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "pod template",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					PodTemplate: &corev1.PodTemplateSpec{
+						Spec: corev1.PodSpec{
+							PriorityClassName: "high",
+							Containers:        []corev1.Container{{Name: "sidecar", Image: "sidecar"}},
+						},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "canary",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					MasterSize: 3,
+					Canary:     &CanarySpec{Shards: []int{0, 2}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "tls with both a secret and an issuer",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					Image: "redis:6.2",
+					TLS:   &TLSSpec{SecretName: "redis-tls", IssuerRef: &TLSIssuerReference{Name: "ca"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "tls on redis 5",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					Image: "redis:5.0.4-alpine",
+					TLS:   &TLSSpec{SecretName: "redis-tls"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "users without password",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					Image: "redis:6.2",
+					Users: []RedisUser{{Name: "app", PasswordSecret: corev1.LocalObjectReference{Name: "app"}}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid user command rule",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					Image:          "redis:6.2",
+					PasswordSecret: &corev1.LocalObjectReference{Name: "redis"},
+					Users: []RedisUser{{
+						Name:           "app",
+						PasswordSecret: corev1.LocalObjectReference{Name: "app"},
+						Commands:       []string{"@read"},
+					}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "users",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					Image:          "redis:6.2",
+					PasswordSecret: &corev1.LocalObjectReference{Name: "redis"},
+					Users: []RedisUser{{
+						Name:           "app",
+						PasswordSecret: corev1.LocalObjectReference{Name: "app"},
+						Commands:       []string{"+@read", "+@write", "-@dangerous"},
+						Keys:           []string{"app:*"},
+					}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "tls",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					Image: "redis:6.2",
+					TLS:   &TLSSpec{IssuerRef: &TLSIssuerReference{Name: "ca", Kind: TLSClusterIssuerKind}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "external access through a ClusterIP service",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					ExternalAccess: &ExternalAccessSpec{Type: corev1.ServiceTypeClusterIP},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "external access",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					ExternalAccess: &ExternalAccessSpec{Type: corev1.ServiceTypeLoadBalancer},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "announce hostnames on redis 6",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					Image:             "redis:6.2",
+					AnnounceHostnames: true,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "announce hostnames with external access",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					Image:             "redis:7.0",
+					AnnounceHostnames: true,
+					ExternalAccess:    &ExternalAccessSpec{Type: corev1.ServiceTypeLoadBalancer},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "announce hostnames",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					Image:             "redis:7.0",
+					AnnounceHostnames: true,
+				},
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -458,6 +710,127 @@ func TestDistributedRedisCluster_ValidateUpdate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "unsafe scale down",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{MasterSize: 3, ClusterReplicas: 1},
+			},
+			args: args{
+				old: &DistributedRedisCluster{
+					Spec: DistributedRedisClusterSpec{MasterSize: 4, ClusterReplicas: 1},
+					Status: DistributedRedisClusterStatus{
+						Memory: &MemoryStatus{UsedMemory: 7 << 30, MaxMemory: 2 << 30},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "scale down",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{MasterSize: 3, ClusterReplicas: 1},
+			},
+			args: args{
+				old: &DistributedRedisCluster{
+					Spec: DistributedRedisClusterSpec{MasterSize: 4, ClusterReplicas: 1},
+					Status: DistributedRedisClusterStatus{
+						Memory: &MemoryStatus{UsedMemory: 5 << 30, MaxMemory: 2 << 30},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "remove replicas without acknowledgement",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{MasterSize: 3, ClusterReplicas: 0},
+			},
+			args: args{
+				old: &DistributedRedisCluster{
+					Spec: DistributedRedisClusterSpec{MasterSize: 3, ClusterReplicas: 1},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "restore from backup without replicas",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					MasterSize:      3,
+					ClusterReplicas: 0,
+					Init:            &InitSpec{BackupSource: &BackupSourceSpec{Name: "backup", Namespace: "default"}},
+				},
+			},
+			args: args{
+				old: &DistributedRedisCluster{
+					Spec: DistributedRedisClusterSpec{
+						MasterSize:      3,
+						ClusterReplicas: 1,
+						Init:            &InitSpec{BackupSource: &BackupSourceSpec{Name: "backup", Namespace: "default"}},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "remove replicas",
+			fields: fields{
+				ObjectMeta: v1.ObjectMeta{Annotations: map[string]string{AnnotationAcknowledgeNoReplicas: "true"}},
+				Spec:       DistributedRedisClusterSpec{MasterSize: 3, ClusterReplicas: 0},
+			},
+			args: args{
+				old: &DistributedRedisCluster{
+					Spec: DistributedRedisClusterSpec{MasterSize: 3, ClusterReplicas: 1},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "enable tls",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					Image: "redis:6.2",
+					TLS:   &TLSSpec{SecretName: "redis-tls"},
+				},
+			},
+			args: args{
+				old: &DistributedRedisCluster{
+					Spec: DistributedRedisClusterSpec{Image: "redis:6.2"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "change external access type",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					ExternalAccess: &ExternalAccessSpec{Type: corev1.ServiceTypeNodePort},
+				},
+			},
+			args: args{
+				old: &DistributedRedisCluster{
+					Spec: DistributedRedisClusterSpec{
+						ExternalAccess: &ExternalAccessSpec{Type: corev1.ServiceTypeLoadBalancer},
+					},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "announce hostnames on an existing cluster",
+			fields: fields{
+				Spec: DistributedRedisClusterSpec{
+					Image:             "redis:7.0",
+					AnnounceHostnames: true,
+				},
+			},
+			args: args{
+				old: &DistributedRedisCluster{
+					Spec: DistributedRedisClusterSpec{Image: "redis:7.0"},
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -473,3 +846,84 @@ func TestDistributedRedisCluster_ValidateUpdate(t *testing.T) {
 		})
 	}
 }
+
+func TestDistributedRedisCluster_Default(t *testing.T) {
+	in := &DistributedRedisCluster{
+		ObjectMeta: v1.ObjectMeta{Name: "test"},
+		Spec: DistributedRedisClusterSpec{
+			MasterSize: 1,
+			Monitor:    &AgentSpec{},
+		},
+	}
+	in.Default()
+	if in.Spec.MasterSize != minMasterSize {
+		t.Errorf("Default() MasterSize = %d, want %d", in.Spec.MasterSize, minMasterSize)
+	}
+	if in.Spec.Image != defaultRedisImage {
+		t.Errorf("Default() Image = %s, want %s", in.Spec.Image, defaultRedisImage)
+	}
+	if in.Spec.ServiceName != "test" {
+		t.Errorf("Default() ServiceName = %s, want test", in.Spec.ServiceName)
+	}
+	if in.Spec.Resources == nil {
+		t.Errorf("Default() Resources is nil")
+	}
+	if in.Spec.Annotations["prometheus.io/scrape"] != "true" {
+		t.Errorf("Default() Annotations = %v, want the prometheus annotations", in.Spec.Annotations)
+	}
+	if in.DefaultSpec(log) {
+		t.Errorf("DefaultSpec() updated a defaulted cluster")
+	}
+}
+
+func TestDistributedRedisCluster_Default_Restore(t *testing.T) {
+	in := &DistributedRedisCluster{
+		ObjectMeta: v1.ObjectMeta{Name: "test"},
+		Spec: DistributedRedisClusterSpec{
+			Init: &InitSpec{BackupSource: &BackupSourceSpec{Name: "backup", Namespace: "default"}},
+		},
+	}
+	in.Default()
+	if in.Spec.Image != "" {
+		t.Errorf("Default() Image = %s, want the image of the backup set by the controller", in.Spec.Image)
+	}
+
+	in.Status.Restore.RestoreSucceeded = 1
+	in.Default()
+	if in.Spec.Image != defaultRedisImage {
+		t.Errorf("Default() Image of a restored cluster = %s, want %s", in.Spec.Image, defaultRedisImage)
+	}
+}
+
+func TestDistributedRedisCluster_ValidateDelete(t *testing.T) {
+	in := &DistributedRedisCluster{}
+	if err := in.ValidateDelete(); err != nil {
+		t.Errorf("ValidateDelete() error = %v", err)
+	}
+	in.Annotations = map[string]string{AnnotationProtected: "true"}
+	if err := in.ValidateDelete(); err == nil {
+		t.Errorf("ValidateDelete() deleted a protected cluster")
+	}
+}
+
+// TestDistributedRedisClusterSpec_Validate checks that the update of a cluster runs the checks of the spec of
+// its creation.
+func TestDistributedRedisClusterSpec_Validate(t *testing.T) {
+	specs := map[string]DistributedRedisClusterSpec{
+		"forbidden config":              {Image: "redis:5.0.4-alpine", Config: map[string]string{"cluster-enabled": "no"}},
+		"announce hostnames on redis 6": {Image: "redis:6.2", AnnounceHostnames: true},
+		"negative rollback deadline":    {AutoRollback: &AutoRollbackSpec{DeadlineSeconds: -1}},
+		"users without password":        {Image: "redis:6.2", Users: []RedisUser{{Name: "app"}}},
+	}
+	for name, spec := range specs {
+		t.Run(name, func(t *testing.T) {
+			in := &DistributedRedisCluster{Spec: spec}
+			if err := in.ValidateCreate(); err == nil {
+				t.Errorf("ValidateCreate() error = nil, want an error")
+			}
+			if err := in.ValidateUpdate(in.DeepCopy()); err == nil {
+				t.Errorf("ValidateUpdate() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -21,6 +21,40 @@ type RedisClusterBackupSpec struct {
 	Storage          *RedisStorage `json:"storage,omitempty"`
 	store.Backend    `json:",inline"`
 	PodSpec          *PodSpec `json:"podSpec,omitempty"`
+	// PreferSlave dumps each shard from a healthy, in-sync slave instead of its master,
+	// the master is used if the shard has no such slave.
+	// +optional
+	PreferSlave bool `json:"preferSlave,omitempty"`
+	// MaxSlaveLag is the maximum replication offset lag in bytes of a slave to be backed up from, defaults to 1048576.
+	// +optional
+	MaxSlaveLag int64 `json:"maxSlaveLag,omitempty"`
+	// Encryption encrypts the snapshots before they are uploaded, they are decrypted by the restore init container.
+	// +optional
+	Encryption *BackupEncryption `json:"encryption,omitempty"`
+	// Compression is the codec the snapshots are compressed with before they are uploaded, one of
+	// none, gzip, zstd or lz4, defaults to none.
+	// +optional
+	Compression BackupCompression `json:"compression,omitempty"`
+}
+
+// BackupCompression is the codec the snapshots are compressed with.
+type BackupCompression string
+
+const (
+	BackupCompressionNone BackupCompression = "none"
+	BackupCompressionGzip BackupCompression = "gzip"
+	BackupCompressionZstd BackupCompression = "zstd"
+	BackupCompressionLz4  BackupCompression = "lz4"
+)
+
+// BackupEncryption configures the encryption of the snapshots with AES-256-GCM.
+// +k8s:openapi-gen=true
+type BackupEncryption struct {
+	// KeySecret selects the key of a Secret holding the base64 encoded 32 bytes encryption key,
+	// e.g. generated by `openssl rand -base64 32`. The Secret must exist in the namespace of the
+	// backup and of the cluster the backup is restored to, the key must not be changed while
+	// backups encrypted with it are kept.
+	KeySecret corev1.SecretKeySelector `json:"keySecret"`
 }
 
 type PodSpec struct {
@@ -158,6 +192,73 @@ type RedisClusterBackupStatus struct {
 	MasterSize      int32        `json:"masterSize,omitempty"`
 	ClusterReplicas int32        `json:"clusterReplicas,omitempty"`
 	ClusterImage    string       `json:"clusterImage,omitempty"`
+	// Location is the folder of the backup in the bucket.
+	Location string `json:"location,omitempty"`
+	// Compression is the codec the snapshots are compressed with.
+	Compression BackupCompression `json:"compression,omitempty"`
+	// Nodes are the redis nodes the shards are dumped from, ordered by shard.
+	Nodes []BackupNode `json:"nodes,omitempty"`
+	// Manifests describes the RDB file uploaded for each master, it is also stored next to the RDB file.
+	Manifests []BackupManifest `json:"manifests,omitempty"`
+	// Shards reports the progress of the backup of each shard, ordered by shard.
+	Shards []BackupShardStatus `json:"shards,omitempty"`
+}
+
+type BackupShardPhase string
+
+const (
+	BackupShardPhasePending   BackupShardPhase = "Pending"
+	BackupShardPhaseRunning   BackupShardPhase = "Running"
+	BackupShardPhaseSucceeded BackupShardPhase = "Succeeded"
+	BackupShardPhaseFailed    BackupShardPhase = "Failed"
+)
+
+// BackupShardStatus is the status of the backup container of a shard.
+// +k8s:openapi-gen=true
+type BackupShardStatus struct {
+	Index int `json:"index"`
+	// MasterPod is the pod of the master of the shard.
+	MasterPod string `json:"masterPod,omitempty"`
+	// Pod is the pod the shard is dumped from, it is MasterPod unless the shard is dumped from a slave.
+	Pod    string           `json:"pod,omitempty"`
+	NodeID string           `json:"nodeID"`
+	Phase  BackupShardPhase `json:"phase"`
+	// BytesUploaded is the size of the RDB file uploaded, after compression and encryption.
+	BytesUploaded int64        `json:"bytesUploaded,omitempty"`
+	StartTime     *metav1.Time `json:"startTime,omitempty"`
+	FinishTime    *metav1.Time `json:"finishTime,omitempty"`
+	// ExitCode, Reason and Message are the exit state of the backup container.
+	ExitCode int32  `json:"exitCode,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// BackupNode is the redis node a shard is dumped from.
+// +k8s:openapi-gen=true
+type BackupNode struct {
+	MasterID string    `json:"masterID"`
+	ID       string    `json:"id"`
+	IP       string    `json:"ip"`
+	Port     string    `json:"port"`
+	Role     RedisRole `json:"role"`
+}
+
+// BackupManifest describes the snapshot of a master, the checksum is verified before the snapshot is restored.
+// +k8s:openapi-gen=true
+type BackupManifest struct {
+	// Snapshot is the name of the folder of the snapshot in the backup location.
+	Snapshot     string `json:"snapshot"`
+	NodeID       string `json:"nodeID"`
+	Slots        string `json:"slots,omitempty"`
+	Size         int64  `json:"size"`
+	SHA256       string `json:"sha256"`
+	RedisVersion string `json:"redisVersion,omitempty"`
+	// Encrypted is true if the RDB file is stored encrypted, the checksum is the one of the decrypted file.
+	Encrypted bool `json:"encrypted,omitempty"`
+	// Compression is the codec the RDB file is stored with.
+	Compression BackupCompression `json:"compression,omitempty"`
+	// UploadedSize is the size of the RDB file uploaded, after compression and encryption.
+	UploadedSize int64 `json:"uploadedSize,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -0,0 +1,62 @@
+package v1alpha1
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+var backupLog = logf.Log.WithName("backup-resource")
+
+var _ webhook.Defaulter = &RedisClusterBackup{}
+
+// Default sets the default compression and maximum slave lag of a new backup, the backups already
+// started by the controller are left as they ran.
+func (in *RedisClusterBackup) Default() {
+	log := backupLog.WithValues("namespace", in.Namespace, "name", in.Name)
+	log.Info("Default")
+	if in.Status.StartTime != nil || in.Status.Phase != "" {
+		return
+	}
+	if in.Spec.Compression == "" {
+		in.Spec.Compression = BackupCompressionNone
+	}
+	if in.Spec.MaxSlaveLag == 0 {
+		in.Spec.MaxSlaveLag = DefaultMaxSlaveLag
+	}
+}
+
+var _ webhook.Validator = &RedisClusterBackup{}
+
+func (in *RedisClusterBackup) ValidateCreate() error {
+	log := backupLog.WithValues("namespace", in.Namespace, "name", in.Name)
+	log.Info("ValidateCreate")
+	return in.Validate()
+}
+
+func (in *RedisClusterBackup) ValidateUpdate(old runtime.Object) error {
+	log := backupLog.WithValues("namespace", in.Namespace, "name", in.Name)
+	log.Info("ValidateUpdate")
+
+	if _, ok := old.(*RedisClusterBackup); !ok {
+		err := fmt.Errorf("invalid obj type")
+		log.Error(err, "can not reflect type")
+		return err
+	}
+	return in.Validate()
+}
+
+func (in *RedisClusterBackup) ValidateDelete() error {
+	log := backupLog.WithValues("namespace", in.Namespace, "name", in.Name)
+	log.Info("ValidateDelete")
+	return nil
+}
+
+func (in *RedisClusterBackup) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(in).
+		Complete()
+}
@@ -0,0 +1,41 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRedisClusterBackup_Default(t *testing.T) {
+	tests := []struct {
+		name            string
+		phase           BackupPhase
+		wantCompression BackupCompression
+		wantMaxSlaveLag int64
+	}{
+		{
+			name:            "created",
+			wantCompression: BackupCompressionNone,
+			wantMaxSlaveLag: DefaultMaxSlaveLag,
+		},
+		{
+			name:  "updated",
+			phase: BackupPhaseSucceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := &RedisClusterBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "default"},
+				Status:     RedisClusterBackupStatus{Phase: tt.phase},
+			}
+			in.Default()
+			if in.Spec.Compression != tt.wantCompression {
+				t.Errorf("Default() compression = %q, want %q", in.Spec.Compression, tt.wantCompression)
+			}
+			if in.Spec.MaxSlaveLag != tt.wantMaxSlaveLag {
+				t.Errorf("Default() maxSlaveLag = %d, want %d", in.Spec.MaxSlaveLag, tt.wantMaxSlaveLag)
+			}
+		})
+	}
+}
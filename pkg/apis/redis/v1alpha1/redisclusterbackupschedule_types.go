@@ -0,0 +1,89 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RedisClusterBackupScheduleSpec defines the desired state of RedisClusterBackupSchedule
+// +k8s:openapi-gen=true
+type RedisClusterBackupScheduleSpec struct {
+	// The schedule in Cron format, see https://en.wikipedia.org/wiki/Cron.
+	Schedule string `json:"schedule"`
+	// RedisClusterName is the name of the DistributedRedisCluster to back up.
+	// It overrides the redisClusterName of the BackupTemplate.
+	RedisClusterName string `json:"redisClusterName"`
+	// This flag tells the controller to suspend subsequent backups, it does
+	// not apply to already started backups. Defaults to false.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+	// BackupTemplate is the spec of the RedisClusterBackup that will be
+	// created when executing a scheduled backup.
+	BackupTemplate RedisClusterBackupSpec `json:"backupTemplate"`
+	// Retention is the policy used to garbage collect the completed backups
+	// created by this schedule. Backups are kept forever if it is not set.
+	// +optional
+	Retention *BackupRetentionPolicy `json:"retention,omitempty"`
+}
+
+// BackupRetentionPolicy defines which completed backups of a schedule are kept.
+// A succeeded backup is kept if it matches any of the keep rules, the newest backup
+// of a day/week/month is used for the daily/weekly/monthly rules.
+// Backups older than MaxAge are always removed.
+// +k8s:openapi-gen=true
+type BackupRetentionPolicy struct {
+	// Keep the last n succeeded backups.
+	// +optional
+	KeepLast int32 `json:"keepLast,omitempty"`
+	// Keep the last n daily backups.
+	// +optional
+	KeepDaily int32 `json:"keepDaily,omitempty"`
+	// Keep the last n weekly backups.
+	// +optional
+	KeepWeekly int32 `json:"keepWeekly,omitempty"`
+	// Keep the last n monthly backups.
+	// +optional
+	KeepMonthly int32 `json:"keepMonthly,omitempty"`
+	// Remove all backups older than MaxAge, failed backups are only removed by this rule.
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+	// DryRun only reports the backups that would be removed without deleting them.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// RedisClusterBackupScheduleStatus defines the observed state of RedisClusterBackupSchedule
+// +k8s:openapi-gen=true
+type RedisClusterBackupScheduleStatus struct {
+	// Information when was the last time the backup was successfully scheduled.
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// The name of the last RedisClusterBackup created by this schedule.
+	LastBackup string `json:"lastBackup,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RedisClusterBackupSchedule is the Schema for the redisclusterbackupschedules API
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=redisclusterbackupschedules,scope=Namespaced
+type RedisClusterBackupSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RedisClusterBackupScheduleSpec   `json:"spec,omitempty"`
+	Status RedisClusterBackupScheduleStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RedisClusterBackupScheduleList contains a list of RedisClusterBackupSchedule
+type RedisClusterBackupScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RedisClusterBackupSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RedisClusterBackupSchedule{}, &RedisClusterBackupScheduleList{})
+}
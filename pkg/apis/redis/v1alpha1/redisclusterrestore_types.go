@@ -0,0 +1,78 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type RestorePhase string
+
+const (
+	// RestorePhaseDraining used for Restore that is removing the cluster from its client Service
+	RestorePhaseDraining RestorePhase = "Draining"
+	// RestorePhaseStopping used for Restore that is stopping the redis nodes
+	RestorePhaseStopping RestorePhase = "Stopping"
+	// RestorePhaseRestoring used for Restore that is pulling the backup into the masters
+	RestorePhaseRestoring RestorePhase = "Restoring"
+	// RestorePhaseLoading used for Restore that is loading the backup into standalone redis servers before resharding it
+	RestorePhaseLoading RestorePhase = "Loading"
+	// RestorePhaseResharding used for Restore that is moving the keys of the backup into the masters serving their slots
+	RestorePhaseResharding RestorePhase = "Resharding"
+	// RestorePhaseStarting used for Restore that is waiting for the cluster to be healthy again
+	RestorePhaseStarting RestorePhase = "Starting"
+	// RestorePhaseSucceeded used for Restore that are completed successfully
+	RestorePhaseSucceeded RestorePhase = "Succeeded"
+	// RestorePhaseFailed used for Restore that are failed
+	RestorePhaseFailed RestorePhase = "Failed"
+)
+
+// RedisClusterRestoreSpec defines the desired state of RedisClusterRestore
+// +k8s:openapi-gen=true
+type RedisClusterRestoreSpec struct {
+	// RedisClusterName is the name of the running DistributedRedisCluster to roll back.
+	RedisClusterName string `json:"redisClusterName"`
+	// BackupSource is the RedisClusterBackup to restore.
+	BackupSource BackupSourceSpec `json:"backupSource"`
+	// Reshard restores the backup by moving each key into the master serving its slot, instead of
+	// restoring the RDB file of each master. It allows to restore a backup taken with a different number
+	// of masters than the cluster has, the cluster keeps running but its data is flushed first.
+	// +optional
+	Reshard bool `json:"reshard,omitempty"`
+}
+
+// RedisClusterRestoreStatus defines the observed state of RedisClusterRestore
+// +k8s:openapi-gen=true
+type RedisClusterRestoreStatus struct {
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	Phase          RestorePhase `json:"phase,omitempty"`
+	Reason         string       `json:"reason,omitempty"`
+	// RemainingKeys is the number of keys of the backup left to reshard into the cluster.
+	RemainingKeys int64 `json:"remainingKeys,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RedisClusterRestore is the Schema for the redisclusterrestores API
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=redisclusterrestores,scope=Namespaced
+type RedisClusterRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RedisClusterRestoreSpec   `json:"spec,omitempty"`
+	Status RedisClusterRestoreStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RedisClusterRestoreList contains a list of RedisClusterRestore
+type RedisClusterRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []RedisClusterRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&RedisClusterRestore{}, &RedisClusterRestoreList{})
+}
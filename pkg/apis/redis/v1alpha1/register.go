@@ -11,8 +11,10 @@ import (
 )
 
 const (
-	DistributedRedisClusterKind = "DistributedRedisCluster"
-	RedisClusterBackupKind      = "RedisClusterBackup"
+	DistributedRedisClusterKind    = "DistributedRedisCluster"
+	RedisClusterBackupKind         = "RedisClusterBackup"
+	RedisClusterBackupScheduleKind = "RedisClusterBackupSchedule"
+	RedisClusterRestoreKind        = "RedisClusterRestore"
 )
 
 var (
@@ -11,12 +11,18 @@ import (
 
 func GetOpenAPIDefinitions(ref common.ReferenceCallback) map[string]common.OpenAPIDefinition {
 	return map[string]common.OpenAPIDefinition{
-		"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.DistributedRedisCluster":       schema_pkg_apis_redis_v1alpha1_DistributedRedisCluster(ref),
-		"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.DistributedRedisClusterSpec":   schema_pkg_apis_redis_v1alpha1_DistributedRedisClusterSpec(ref),
-		"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.DistributedRedisClusterStatus": schema_pkg_apis_redis_v1alpha1_DistributedRedisClusterStatus(ref),
-		"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterBackup":            schema_pkg_apis_redis_v1alpha1_RedisClusterBackup(ref),
-		"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterBackupSpec":        schema_pkg_apis_redis_v1alpha1_RedisClusterBackupSpec(ref),
-		"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterBackupStatus":      schema_pkg_apis_redis_v1alpha1_RedisClusterBackupStatus(ref),
+		"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.DistributedRedisCluster":          schema_pkg_apis_redis_v1alpha1_DistributedRedisCluster(ref),
+		"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.DistributedRedisClusterSpec":      schema_pkg_apis_redis_v1alpha1_DistributedRedisClusterSpec(ref),
+		"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.DistributedRedisClusterStatus":    schema_pkg_apis_redis_v1alpha1_DistributedRedisClusterStatus(ref),
+		"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterBackup":               schema_pkg_apis_redis_v1alpha1_RedisClusterBackup(ref),
+		"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterBackupSchedule":       schema_pkg_apis_redis_v1alpha1_RedisClusterBackupSchedule(ref),
+		"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterBackupScheduleSpec":   schema_pkg_apis_redis_v1alpha1_RedisClusterBackupScheduleSpec(ref),
+		"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterBackupScheduleStatus": schema_pkg_apis_redis_v1alpha1_RedisClusterBackupScheduleStatus(ref),
+		"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterBackupSpec":           schema_pkg_apis_redis_v1alpha1_RedisClusterBackupSpec(ref),
+		"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterBackupStatus":         schema_pkg_apis_redis_v1alpha1_RedisClusterBackupStatus(ref),
+		"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterRestore":              schema_pkg_apis_redis_v1alpha1_RedisClusterRestore(ref),
+		"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterRestoreSpec":          schema_pkg_apis_redis_v1alpha1_RedisClusterRestoreSpec(ref),
+		"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterRestoreStatus":        schema_pkg_apis_redis_v1alpha1_RedisClusterRestoreStatus(ref),
 	}
 }
 
@@ -287,6 +293,72 @@ func schema_pkg_apis_redis_v1alpha1_RedisClusterBackup(ref common.ReferenceCallb
 	}
 }
 
+func schema_pkg_apis_redis_v1alpha1_RedisClusterBackupSchedule(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "RedisClusterBackupSchedule is the Schema for the redisclusterbackupschedules API",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+						},
+					},
+					"spec": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterBackupScheduleSpec"),
+						},
+					},
+					"status": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterBackupScheduleStatus"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterBackupScheduleSpec", "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterBackupScheduleStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
+	}
+}
+
+func schema_pkg_apis_redis_v1alpha1_RedisClusterBackupScheduleSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "RedisClusterBackupScheduleSpec defines the desired state of RedisClusterBackupSchedule",
+				Type:        []string{"object"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_redis_v1alpha1_RedisClusterBackupScheduleStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "RedisClusterBackupScheduleStatus defines the observed state of RedisClusterBackupSchedule",
+				Type:        []string{"object"},
+			},
+		},
+	}
+}
+
 func schema_pkg_apis_redis_v1alpha1_RedisClusterBackupSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
 	return common.OpenAPIDefinition{
 		Schema: spec.Schema{
@@ -308,3 +380,69 @@ func schema_pkg_apis_redis_v1alpha1_RedisClusterBackupStatus(ref common.Referenc
 		},
 	}
 }
+
+func schema_pkg_apis_redis_v1alpha1_RedisClusterRestore(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "RedisClusterRestore is the Schema for the redisclusterrestores API",
+				Type:        []string{"object"},
+				Properties: map[string]spec.Schema{
+					"kind": {
+						SchemaProps: spec.SchemaProps{
+							Description: "Kind is a string value representing the REST resource this object represents. Servers may infer this from the endpoint the client submits requests to. Cannot be updated. In CamelCase. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#types-kinds",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"apiVersion": {
+						SchemaProps: spec.SchemaProps{
+							Description: "APIVersion defines the versioned schema of this representation of an object. Servers should convert recognized schemas to the latest internal value, and may reject unrecognized values. More info: https://git.k8s.io/community/contributors/devel/api-conventions.md#resources",
+							Type:        []string{"string"},
+							Format:      "",
+						},
+					},
+					"metadata": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"),
+						},
+					},
+					"spec": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterRestoreSpec"),
+						},
+					},
+					"status": {
+						SchemaProps: spec.SchemaProps{
+							Ref: ref("github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterRestoreStatus"),
+						},
+					},
+				},
+			},
+		},
+		Dependencies: []string{
+			"github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterRestoreSpec", "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1.RedisClusterRestoreStatus", "k8s.io/apimachinery/pkg/apis/meta/v1.ObjectMeta"},
+	}
+}
+
+func schema_pkg_apis_redis_v1alpha1_RedisClusterRestoreSpec(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "RedisClusterRestoreSpec defines the desired state of RedisClusterRestore",
+				Type:        []string{"object"},
+			},
+		},
+	}
+}
+
+func schema_pkg_apis_redis_v1alpha1_RedisClusterRestoreStatus(ref common.ReferenceCallback) common.OpenAPIDefinition {
+	return common.OpenAPIDefinition{
+		Schema: spec.Schema{
+			SchemaProps: spec.SchemaProps{
+				Description: "RedisClusterRestoreStatus defines the observed state of RedisClusterRestore",
+				Type:        []string{"object"},
+			},
+		},
+	}
+}
@@ -0,0 +1,164 @@
+// Package backupcrypt encrypts backup streams with AES-256-GCM.
+//
+// The stream is split in chunks sealed with a nonce made of a random prefix, the index of the chunk
+// and a flag marking the last chunk, so that chunks cannot be reordered, dropped or truncated.
+//
+//	header: magic (8 bytes) | nonce prefix (7 bytes)
+//	chunk:  length of the sealed chunk (4 bytes, big endian) | sealed chunk
+package backupcrypt
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// KeySize is the size of the AES-256 key.
+	KeySize = 32
+
+	magic       = "RCOENC01"
+	prefixSize  = 7
+	chunkSize   = 64 * 1024
+	lastChunk   = 1
+	nonceSize   = prefixSize + 4 + 1
+	maxChunkLen = chunkSize + 16
+)
+
+// ErrDecrypt is returned when the stream cannot be authenticated, the key is wrong or the data corrupted.
+var ErrDecrypt = errors.New("backupcrypt: decryption failed, wrong key or corrupted data")
+
+// ParseKey decodes a base64 encoded AES-256 key.
+func ParseKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("backupcrypt: key is not base64 encoded: %v", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("backupcrypt: key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCMWithNonceSize(block, nonceSize)
+}
+
+func chunkNonce(prefix []byte, index uint32, last bool) []byte {
+	nonce := make([]byte, nonceSize)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[prefixSize:], index)
+	if last {
+		nonce[nonceSize-1] = lastChunk
+	}
+	return nonce
+}
+
+// Encrypt reads src until EOF and writes it encrypted with key to dst.
+func Encrypt(dst io.Writer, src io.Reader, key []byte) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	prefix := make([]byte, prefixSize)
+	if _, err := io.ReadFull(rand.Reader, prefix); err != nil {
+		return err
+	}
+	w := bufio.NewWriter(dst)
+	if _, err := w.WriteString(magic); err != nil {
+		return err
+	}
+	if _, err := w.Write(prefix); err != nil {
+		return err
+	}
+
+	r := bufio.NewReaderSize(src, chunkSize)
+	buf := make([]byte, chunkSize)
+	for index := uint32(0); ; index++ {
+		if index == ^uint32(0) {
+			return errors.New("backupcrypt: stream too large")
+		}
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		// the chunk is the last one if nothing is left to read.
+		_, peekErr := r.Peek(1)
+		last := peekErr == io.EOF
+		if peekErr != nil && peekErr != io.EOF {
+			return peekErr
+		}
+		sealed := gcm.Seal(nil, chunkNonce(prefix, index, last), buf[:n], nil)
+		var length [4]byte
+		binary.BigEndian.PutUint32(length[:], uint32(len(sealed)))
+		if _, err := w.Write(length[:]); err != nil {
+			return err
+		}
+		if _, err := w.Write(sealed); err != nil {
+			return err
+		}
+		if last {
+			return w.Flush()
+		}
+	}
+}
+
+// Decrypt reads a stream encrypted by Encrypt from src and writes the plaintext to dst.
+func Decrypt(dst io.Writer, src io.Reader, key []byte) error {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	r := bufio.NewReader(src)
+	header := make([]byte, len(magic)+prefixSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("backupcrypt: invalid header: %v", err)
+	}
+	if string(header[:len(magic)]) != magic {
+		return errors.New("backupcrypt: not an encrypted backup")
+	}
+	prefix := header[len(magic):]
+
+	w := bufio.NewWriter(dst)
+	for index := uint32(0); ; index++ {
+		var length [4]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			// the last chunk has not been read, the stream is truncated.
+			return ErrDecrypt
+		}
+		n := binary.BigEndian.Uint32(length[:])
+		if n > maxChunkLen {
+			return ErrDecrypt
+		}
+		sealed := make([]byte, n)
+		if _, err := io.ReadFull(r, sealed); err != nil {
+			return ErrDecrypt
+		}
+		_, peekErr := r.Peek(1)
+		last := peekErr == io.EOF
+		if peekErr != nil && peekErr != io.EOF {
+			return peekErr
+		}
+		plain, err := gcm.Open(nil, chunkNonce(prefix, index, last), sealed, nil)
+		if err != nil {
+			return ErrDecrypt
+		}
+		if _, err := w.Write(plain); err != nil {
+			return err
+		}
+		if last {
+			return w.Flush()
+		}
+	}
+}
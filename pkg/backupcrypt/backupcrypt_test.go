@@ -0,0 +1,92 @@
+package backupcrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func newKey(t *testing.T) []byte {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func encrypt(t *testing.T, plain, key []byte) []byte {
+	var out bytes.Buffer
+	if err := Encrypt(&out, bytes.NewReader(plain), key); err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	return out.Bytes()
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := newKey(t)
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3*chunkSize + 42} {
+		plain := make([]byte, size)
+		if _, err := io.ReadFull(rand.Reader, plain); err != nil {
+			t.Fatal(err)
+		}
+		sealed := encrypt(t, plain, key)
+		if size > 0 && bytes.Contains(sealed, plain) {
+			t.Errorf("size %d: encrypted stream contains the plaintext", size)
+		}
+		var out bytes.Buffer
+		if err := Decrypt(&out, bytes.NewReader(sealed), key); err != nil {
+			t.Fatalf("size %d: Decrypt() error = %v", size, err)
+		}
+		if !bytes.Equal(out.Bytes(), plain) {
+			t.Errorf("size %d: Decrypt() returned a different plaintext", size)
+		}
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	key := newKey(t)
+	plain := bytes.Repeat([]byte("redis"), chunkSize)
+	sealed := encrypt(t, plain, key)
+	header := len(magic) + prefixSize
+	firstChunk := header + 4 + chunkSize + 16
+
+	tests := []struct {
+		name   string
+		key    []byte
+		sealed []byte
+	}{
+		{"wrong key", newKey(t), sealed},
+		{"flipped bit", key, func() []byte {
+			b := append([]byte{}, sealed...)
+			b[header+10] ^= 1
+			return b
+		}()},
+		{"truncated after a chunk", key, sealed[:firstChunk]},
+		{"truncated in a chunk", key, sealed[:len(sealed)-1]},
+		{"trailing data", key, append(append([]byte{}, sealed...), 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Decrypt(ioutil.Discard, bytes.NewReader(tt.sealed), tt.key); err != ErrDecrypt {
+				t.Errorf("Decrypt() error = %v, want %v", err, ErrDecrypt)
+			}
+		})
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	key := newKey(t)
+	got, err := ParseKey(base64.StdEncoding.EncodeToString(key) + "\n")
+	if err != nil || !bytes.Equal(got, key) {
+		t.Errorf("ParseKey() = %v, %v, want %v", got, err, key)
+	}
+	if _, err := ParseKey(base64.StdEncoding.EncodeToString(key[:16])); err == nil {
+		t.Errorf("ParseKey() of a 16 bytes key should fail")
+	}
+	if _, err := ParseKey("not base64!"); err == nil {
+		t.Errorf("ParseKey() of an invalid key should fail")
+	}
+}
@@ -2,6 +2,7 @@ package clustering
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/go-logr/logr"
 
@@ -20,6 +21,8 @@ type Ctx struct {
 	newMastersBySts   map[string]*redisutil.Node
 	slavesByMaster    map[string]redisutil.Nodes
 	bestEffort        bool
+	// preferredMasterNodes are the names of the k8s nodes preferred for the masters.
+	preferredMasterNodes map[string]bool
 }
 
 func NewCtx(cluster *redisutil.Cluster, nodes redisutil.Nodes, masterNum int32, clusterName string, log logr.Logger) *Ctx {
@@ -35,6 +38,11 @@ func NewCtx(cluster *redisutil.Cluster, nodes redisutil.Nodes, masterNum int32,
 	return ctx
 }
 
+// SetPreferredMasterNodes makes PlaceMasters prefer the redis nodes running on the given k8s nodes.
+func (c *Ctx) SetPreferredMasterNodes(nodeNames map[string]bool) {
+	c.preferredMasterNodes = nodeNames
+}
+
 func (c *Ctx) sortRedisNodeByStatefulSet(nodes redisutil.Nodes) map[string]redisutil.Nodes {
 	nodesByStatefulSet := make(map[string]redisutil.Nodes)
 
@@ -88,7 +96,7 @@ func (c *Ctx) PlaceMasters(ssName string) *redisutil.Node {
 	for _, master := range c.newMastersBySts {
 		allMasters = append(allMasters, master)
 	}
-	nodes := c.nodes[ssName]
+	nodes := c.sortByMasterPreference(c.nodes[ssName])
 	for _, cNode := range nodes {
 		_, err := allMasters.GetNodesByFunc(func(node *redisutil.Node) bool {
 			if node.NodeName == cNode.NodeName {
@@ -105,6 +113,16 @@ func (c *Ctx) PlaceMasters(ssName string) *redisutil.Node {
 	return nodes[0]
 }
 
+// sortByMasterPreference returns the nodes with the ones running on the preferred k8s nodes first.
+func (c *Ctx) sortByMasterPreference(nodes redisutil.Nodes) redisutil.Nodes {
+	sorted := make(redisutil.Nodes, len(nodes))
+	copy(sorted, nodes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return c.preferredMasterNodes[sorted[i].NodeName] && !c.preferredMasterNodes[sorted[j].NodeName]
+	})
+	return sorted
+}
+
 func (c *Ctx) PlaceSlaves() error {
 	c.bestEffort = true
 	for ssName, nodes := range c.nodes {
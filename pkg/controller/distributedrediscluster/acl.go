@@ -0,0 +1,95 @@
+package distributedrediscluster
+
+import (
+	"crypto/tls"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/config"
+	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+)
+
+// operatorCommands are the commands of the ACL user of the operator, the ones it sends to manage the cluster.
+var operatorCommands = []string{
+	"-@all", "+@connection", "+cluster", "+config", "+info", "+client", "+acl", "+dbsize", "+flushall",
+	"+migrate", "+scan",
+}
+
+// operatorACLUser returns the ACL user of the operator. MIGRATE and SCAN need all the keys.
+func operatorACLUser(password string) redisutil.ACLUser {
+	return redisutil.ACLUser{
+		Name:     redisv1alpha1.OperatorACLUser,
+		Password: password,
+		Commands: operatorCommands,
+		Keys:     []string{"*"},
+	}
+}
+
+// newClusterAdmin returns the admin of the redis nodes. A cluster with ACL users is managed as the ACL user of
+// the operator, it is first set on the nodes with the password of the default user, which the nodes of a new
+// pod only know.
+func (r *ReconcileDistributedRedisCluster) newClusterAdmin(cluster *redisv1alpha1.DistributedRedisCluster,
+	pods []*corev1.Pod, password string, tlsConfig *tls.Config, reqLogger logr.Logger) (redisutil.IAdmin, error) {
+	operatorPassword, err := k8sutil.GetClusterOperatorPassword(r.client, cluster)
+	if err != nil {
+		return nil, Kubernetes.Wrap(err, "GetClusterOperatorPassword")
+	}
+	if operatorPassword == "" {
+		admin, err := newRedisAdmin(pods, "", password, tlsConfig, config.RedisConf(), reqLogger)
+		if err != nil {
+			return nil, Redis.Wrap(err, "newRedisAdmin")
+		}
+		return admin, nil
+	}
+
+	bootstrap, err := newRedisAdmin(pods, "", password, tlsConfig, config.RedisConf(), reqLogger)
+	if err != nil {
+		return nil, Redis.Wrap(err, "newRedisAdmin")
+	}
+	err = bootstrap.SetACLUsersIfNeed([]redisutil.ACLUser{operatorACLUser(operatorPassword)}, nil)
+	bootstrap.Close()
+	if err != nil {
+		return nil, Redis.Wrap(err, "SetACLUsersIfNeed operator")
+	}
+	admin, err := newRedisAdmin(pods, redisv1alpha1.OperatorACLUser, operatorPassword, tlsConfig, config.RedisConf(), reqLogger)
+	if err != nil {
+		return nil, Redis.Wrap(err, "newRedisAdmin")
+	}
+	return admin, nil
+}
+
+// syncUsers sets the ACL users of the spec on the nodes and deletes the users removed from the spec since the
+// last reconcile, with the ACL user of the operator once no user is left. It returns the names of the users to record in the status.
+func (r *ReconcileDistributedRedisCluster) syncUsers(cluster *redisv1alpha1.DistributedRedisCluster,
+	admin redisutil.IAdmin) ([]string, error) {
+	users, err := k8sutil.GetClusterACLUsers(r.client, cluster)
+	if err != nil {
+		return nil, Kubernetes.Wrap(err, "GetClusterACLUsers")
+	}
+	var names []string
+	inSpec := map[string]bool{}
+	for _, user := range users {
+		names = append(names, user.Name)
+		inSpec[user.Name] = true
+	}
+	var removed []string
+	for _, name := range cluster.Status.Users {
+		if !inSpec[name] {
+			removed = append(removed, name)
+		}
+	}
+	if len(users) == 0 && len(removed) == 0 {
+		return nil, nil
+	}
+	if len(users) == 0 {
+		// the admin is connected as the default user once the last user is removed.
+		removed = append(removed, redisv1alpha1.OperatorACLUser)
+	}
+	if err := admin.SetACLUsersIfNeed(users, removed); err != nil {
+		return nil, Redis.Wrap(err, "SetACLUsersIfNeed")
+	}
+	return names, nil
+}
@@ -2,12 +2,14 @@ package distributedrediscluster
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/spf13/pflag"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
@@ -19,11 +21,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
-	"github.com/ucloud/redis-cluster-operator/pkg/config"
 	"github.com/ucloud/redis-cluster-operator/pkg/controller/heal"
 	clustermanger "github.com/ucloud/redis-cluster-operator/pkg/controller/manager"
 	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisconfig"
 	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
 	"github.com/ucloud/redis-cluster-operator/pkg/utils"
 )
 
@@ -60,14 +63,16 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	reconiler := &ReconcileDistributedRedisCluster{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	reconiler := &ReconcileDistributedRedisCluster{client: mgr.GetClient(), apiReader: mgr.GetAPIReader(), scheme: mgr.GetScheme()}
 	reconiler.statefulSetController = k8sutil.NewStatefulSetController(reconiler.client)
 	reconiler.serviceController = k8sutil.NewServiceController(reconiler.client)
 	reconiler.pdbController = k8sutil.NewPodDisruptionBudgetController(reconiler.client)
 	reconiler.pvcController = k8sutil.NewPvcController(reconiler.client)
+	reconiler.podController = k8sutil.NewPodController(reconiler.client)
 	reconiler.crController = k8sutil.NewCRControl(reconiler.client)
 	reconiler.ensurer = clustermanger.NewEnsureResource(reconiler.client, log)
 	reconiler.checker = clustermanger.NewCheck(reconiler.client)
+	reconiler.recorder = mgr.GetEventRecorderFor("redis-cluster-operator")
 	return reconiler
 }
 
@@ -132,7 +137,9 @@ var _ reconcile.Reconciler = &ReconcileDistributedRedisCluster{}
 type ReconcileDistributedRedisCluster struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
-	client                client.Client
+	client client.Client
+	// apiReader reads the nodes without caching them, the operator may not be allowed to watch them.
+	apiReader             client.Reader
 	scheme                *runtime.Scheme
 	ensurer               clustermanger.IEnsureResource
 	checker               clustermanger.ICheck
@@ -140,7 +147,9 @@ type ReconcileDistributedRedisCluster struct {
 	serviceController     k8sutil.IServiceControl
 	pdbController         k8sutil.IPodDisruptionBudgetControl
 	pvcController         k8sutil.IPvcControl
+	podController         k8sutil.IPodControl
 	crController          k8sutil.ICustomResource
+	recorder              record.EventRecorder
 }
 
 // Reconcile reads that state of the cluster for a DistributedRedisCluster object and makes changes based on the state read
@@ -162,10 +171,20 @@ func (r *ReconcileDistributedRedisCluster) Reconcile(request reconcile.Request)
 		return reconcile.Result{}, err
 	}
 
+	// The RedisClusterRestore controller owns the cluster resources while it is restored.
+	if restore, ok := instance.Annotations[redisv1alpha1.AnnotationRestore]; ok {
+		reqLogger.Info("cluster is being restored, skip reconcile", "restore", restore)
+		return reconcile.Result{RequeueAfter: requeueAfter}, nil
+	}
+
 	ctx := &syncContext{
 		cluster:   instance,
 		reqLogger: reqLogger,
 	}
+	// the role hints are only hints, the masters are placed without them if the nodes cannot be read.
+	if ctx.preferredMasterNodes, err = r.preferredMasterNodes(instance); err != nil {
+		reqLogger.Error(err, "preferredMasterNodes")
+	}
 
 	err = r.ensureCluster(ctx)
 	if err != nil {
@@ -187,6 +206,18 @@ func (r *ReconcileDistributedRedisCluster) Reconcile(request reconcile.Request)
 		return reconcile.Result{}, Kubernetes.Wrap(err, "GetStatefulSetPods")
 	}
 
+	if instance.IsRestoreFromBackup() && !instance.IsRestored() {
+		for i := range redisClusterPods.Items {
+			if err := statefulsets.RestoreContainerError(&redisClusterPods.Items[i]); err != nil {
+				reqLogger.Error(err, "restore from backup failed")
+				newStatus := instance.Status.DeepCopy()
+				SetClusterFailed(newStatus, err.Error())
+				r.updateClusterIfNeed(instance, newStatus, reqLogger)
+				return reconcile.Result{RequeueAfter: requeueAfter}, nil
+			}
+		}
+	}
+
 	ctx.pods = clusterPods(redisClusterPods.Items)
 	reqLogger.V(6).Info("debug cluster pods", "", ctx.pods)
 	ctx.healer = clustermanger.NewHealer(&heal.CheckAndHeal{
@@ -203,22 +234,49 @@ func (r *ReconcileDistributedRedisCluster) Reconcile(request reconcile.Request)
 		}
 		reqLogger.WithValues("err", err).Info("waitPodReady")
 		newStatus := instance.Status.DeepCopy()
-		SetClusterScaling(newStatus, err.Error())
+		if newStatus.Upgrade != nil {
+			SetClusterUpdating(newStatus, err.Error())
+		} else {
+			SetClusterScaling(newStatus, err.Error())
+		}
 		r.updateClusterIfNeed(instance, newStatus, reqLogger)
 		return reconcile.Result{RequeueAfter: requeueAfter}, nil
 	}
 
-	password, err := getClusterPassword(r.client, instance)
+	tlsConfig, err := k8sutil.GetClusterTLSConfig(r.client, instance)
+	if err != nil {
+		return reconcile.Result{}, Kubernetes.Wrap(err, "GetClusterTLSConfig")
+	}
+
+	password, rotationTime, err := r.rotatePassword(instance, ctx.pods, tlsConfig, reqLogger)
 	if err != nil {
-		return reconcile.Result{}, Kubernetes.Wrap(err, "getClusterPassword")
+		switch GetType(err) {
+		case Requeue:
+			reqLogger.WithValues("err", err).Info("requeue")
+			return reconcile.Result{RequeueAfter: requeueAfter}, nil
+		}
+		return reconcile.Result{}, err
+	}
+
+	if err := r.annotateExternalAddrs(instance, ctx.pods, reqLogger); err != nil {
+		switch GetType(err) {
+		case Requeue:
+			reqLogger.WithValues("err", err).Info("requeue")
+			return reconcile.Result{RequeueAfter: requeueAfter}, nil
+		}
+		return reconcile.Result{}, err
 	}
 
-	admin, err := newRedisAdmin(ctx.pods, password, config.RedisConf(), reqLogger)
+	admin, err := r.newClusterAdmin(instance, ctx.pods, password, tlsConfig, reqLogger)
 	if err != nil {
-		return reconcile.Result{}, Redis.Wrap(err, "newRedisAdmin")
+		return reconcile.Result{}, err
 	}
 	defer admin.Close()
 
+	if err := announceExternalAddrs(instance, ctx.pods, admin); err != nil {
+		return reconcile.Result{}, err
+	}
+
 	clusterInfos, err := admin.GetClusterInfos()
 	if err != nil {
 		if clusterInfos.Status == redisutil.ClusterInfosPartial {
@@ -265,9 +323,15 @@ func (r *ReconcileDistributedRedisCluster) Reconcile(request reconcile.Request)
 		return reconcile.Result{}, nil
 	}
 
-	if err := admin.SetConfigIfNeed(instance.Spec.Config); err != nil {
+	// the restart-required parameters are rendered in the configmap, the pods are restarted by upgradeCluster.
+	hotConfig, _ := redisconfig.Split(instance.RedisConfig())
+	if err := admin.SetConfigIfNeed(hotConfig); err != nil {
 		return reconcile.Result{}, Redis.Wrap(err, "SetConfigIfNeed")
 	}
+	users, err := r.syncUsers(instance, admin)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
 
 	status := buildClusterStatus(clusterInfos, ctx.pods, instance, reqLogger)
 	if is := r.isScalingDown(instance, reqLogger); is {
@@ -295,8 +359,44 @@ func (r *ReconcileDistributedRedisCluster) Reconcile(request reconcile.Request)
 		}
 	}
 	newStatus := buildClusterStatus(newClusterInfos, ctx.pods, instance, reqLogger)
-	SetClusterOK(newStatus, "OK")
+	upgrading, err := r.upgradeCluster(ctx, newClusterInfos, newStatus)
+	if err != nil {
+		reqLogger.WithValues("err", err).Info("upgradeCluster")
+		SetClusterUpdating(newStatus, err.Error())
+		r.updateClusterIfNeed(instance, newStatus, reqLogger)
+		return reconcile.Result{RequeueAfter: requeueAfter}, nil
+	}
+	newStatus.Config = configStatus(instance.Status.Config, instance.Spec.Config, upgrading)
+	if upgrading {
+		SetClusterUpdating(newStatus, newStatus.Upgrade.Message)
+		r.updateClusterIfNeed(instance, newStatus, reqLogger)
+		return reconcile.Result{RequeueAfter: requeueAfter}, nil
+	}
+	if err := r.recordKnownGood(instance); err != nil {
+		reqLogger.Error(err, "recordKnownGood")
+	}
+	newStatus.UpdateStartTime = nil
+	if memory := memoryStatus(admin, newClusterInfos, reqLogger); memory != nil {
+		newStatus.Memory = memory
+	}
+	if tlsStatus, err := r.reloadCertificate(instance, admin, reqLogger); err != nil {
+		reqLogger.Error(err, "reloadCertificate")
+	} else {
+		newStatus.TLS = tlsStatus
+	}
+	newStatus.Users = users
+	newStatus.PasswordRotationTime = rotationTime
+	if newStatus.Canary != nil && instance.Spec.Canary != nil {
+		SetClusterOK(newStatus, fmt.Sprintf("canary shards %v upgraded, waiting for the %s annotation",
+			instance.Spec.Canary.Shards, redisv1alpha1.AnnotationCanary))
+	} else {
+		SetClusterOK(newStatus, "OK")
+	}
 	r.updateClusterIfNeed(instance, newStatus, reqLogger)
+
+	if err := r.syncContinuousBackup(instance, reqLogger); err != nil {
+		reqLogger.Error(err, "syncContinuousBackup")
+	}
 	return reconcile.Result{RequeueAfter: time.Duration(reconcileTime) * time.Second}, nil
 }
 
@@ -0,0 +1,113 @@
+package distributedrediscluster
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/services"
+)
+
+// annotateExternalAddrs sets on each pod of a cluster with ExternalAccess the address its redis node announces,
+// ip:port@busport, from the Service exposing the pod. A restarted node reads it from the annotations of its pod,
+// the admin identifies the nodes announcing it by the address of their pod. It returns a Requeue error while the
+// address of a Service is not assigned yet.
+func (r *ReconcileDistributedRedisCluster) annotateExternalAddrs(cluster *redisv1alpha1.DistributedRedisCluster,
+	pods []*corev1.Pod, reqLogger logr.Logger) error {
+	if cluster.Spec.ExternalAccess == nil {
+		return nil
+	}
+	for _, pod := range pods {
+		svc, err := r.serviceController.GetService(pod.Namespace, services.ExternalSvcName(pod.Name))
+		if err != nil {
+			return Kubernetes.Wrap(err, "GetExternalService")
+		}
+		addr := externalAddr(svc, pod)
+		if addr == "" {
+			return Requeue.Wrap(fmt.Errorf("waiting for the external address of pod %s", pod.Name), "annotateExternalAddrs")
+		}
+		if pod.Annotations[redisv1alpha1.AnnotationAnnounceAddress] == addr {
+			continue
+		}
+		reqLogger.Info("announcing external address", "pod", pod.Name, "address", addr)
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[redisv1alpha1.AnnotationAnnounceAddress] = addr
+		if err := r.podController.UpdatePod(pod); err != nil {
+			return Kubernetes.Wrap(err, "UpdatePod")
+		}
+	}
+	return nil
+}
+
+// externalAddr returns the address the redis node of pod announces through svc, ip:port@busport, empty if the
+// load balancer is not provisioned yet. A NodePort Service is reached on the node of the pod, its traffic
+// policy is Local.
+func externalAddr(svc *corev1.Service, pod *corev1.Pod) string {
+	var ip string
+	ports := map[string]int32{}
+	switch svc.Spec.Type {
+	case corev1.ServiceTypeLoadBalancer:
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			if ingress.IP != "" {
+				ip = ingress.IP
+				break
+			}
+		}
+		for _, port := range svc.Spec.Ports {
+			ports[port.Name] = port.Port
+		}
+	case corev1.ServiceTypeNodePort:
+		ip = pod.Status.HostIP
+		for _, port := range svc.Spec.Ports {
+			ports[port.Name] = port.NodePort
+		}
+	}
+	if ip == "" || ports["client"] == 0 || ports["gossip"] == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s@%d", net.JoinHostPort(ip, strconv.Itoa(int(ports["client"]))), ports["gossip"])
+}
+
+// announceExternalAddrs makes the running redis nodes announce the address set on their pod, the nodes
+// started before it was set announce the address of their pod until then.
+func announceExternalAddrs(cluster *redisv1alpha1.DistributedRedisCluster, pods []*corev1.Pod,
+	admin redisutil.IAdmin) error {
+	if cluster.Spec.ExternalAccess == nil {
+		return nil
+	}
+	for _, pod := range pods {
+		announce := announceConfig(pod.Annotations[redisv1alpha1.AnnotationAnnounceAddress])
+		if announce == nil {
+			continue
+		}
+		if err := admin.SetNodeConfigIfNeed(podAddr(pod), announce); err != nil {
+			return Redis.Wrap(err, "SetNodeConfigIfNeed")
+		}
+	}
+	return nil
+}
+
+// announceConfig returns the parameters announcing addr, ip:port@busport, nil if it is invalid.
+func announceConfig(addr string) map[string]string {
+	i := strings.LastIndex(addr, "@")
+	if i < 0 {
+		return nil
+	}
+	ip, port, err := net.SplitHostPort(addr[:i])
+	if err != nil {
+		return nil
+	}
+	return map[string]string{
+		"cluster-announce-ip":       ip,
+		"cluster-announce-port":     port,
+		"cluster-announce-bus-port": addr[i+1:],
+	}
+}
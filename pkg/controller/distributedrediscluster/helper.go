@@ -2,12 +2,14 @@ package distributedrediscluster
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -48,27 +50,40 @@ func getClusterPassword(client client.Client, cluster *redisv1alpha1.Distributed
 	return string(secret.Data[passwordKey]), nil
 }
 
-// newRedisAdmin builds and returns new redis.Admin from the list of pods
-func newRedisAdmin(pods []*corev1.Pod, password string, cfg *config.Redis, reqLogger logr.Logger) (redisutil.IAdmin, error) {
-	nodesAddrs := []string{}
-	for _, pod := range pods {
-		redisPort := redisutil.DefaultRedisPort
-		for _, container := range pod.Spec.Containers {
-			if container.Name == "redis" {
-				for _, port := range container.Ports {
-					if port.Name == "client" {
-						redisPort = fmt.Sprintf("%d", port.ContainerPort)
-					}
+// podAddr returns the address of the redis node of pod.
+func podAddr(pod *corev1.Pod) string {
+	redisPort := redisutil.DefaultRedisPort
+	for _, container := range pod.Spec.Containers {
+		if container.Name == "redis" {
+			for _, port := range container.Ports {
+				if port.Name == "client" {
+					redisPort = fmt.Sprintf("%d", port.ContainerPort)
 				}
 			}
 		}
-		reqLogger.V(4).Info("append redis admin addr", "addr", pod.Status.PodIP, "port", redisPort)
-		nodesAddrs = append(nodesAddrs, net.JoinHostPort(pod.Status.PodIP, redisPort))
+	}
+	return net.JoinHostPort(pod.Status.PodIP, redisPort)
+}
+
+// newRedisAdmin builds and returns new redis.Admin from the list of pods, connected as user, the default user if empty
+func newRedisAdmin(pods []*corev1.Pod, user, password string, tlsConfig *tls.Config, cfg *config.Redis, reqLogger logr.Logger) (redisutil.IAdmin, error) {
+	nodesAddrs := []string{}
+	announcedAddrs := map[string]string{}
+	for _, pod := range pods {
+		addr := podAddr(pod)
+		reqLogger.V(4).Info("append redis admin addr", "addr", addr)
+		nodesAddrs = append(nodesAddrs, addr)
+		if announced := k8sutil.AnnouncedAddress(pod); announced != "" {
+			announcedAddrs[announced] = addr
+		}
 	}
 	adminConfig := redisutil.AdminOptions{
 		ConnectionTimeout:  time.Duration(cfg.DialTimeout) * time.Millisecond,
 		RenameCommandsFile: cfg.GetRenameCommandsFile(),
+		User:               user,
 		Password:           password,
+		TLSConfig:          tlsConfig,
+		AnnouncedAddrs:     announcedAddrs,
 	}
 
 	return redisutil.NewAdmin(nodesAddrs, &adminConfig, reqLogger), nil
@@ -196,36 +211,27 @@ func (w *waitPodTerminating) Handler() error {
 	return nil
 }
 
-type waitStatefulSetUpdating struct {
-	name                  string
-	timeout               time.Duration
-	tick                  time.Duration
-	statefulSetController k8sutil.IStatefulSetControl
-	cluster               *redisv1alpha1.DistributedRedisCluster
-}
-
-func (w *waitStatefulSetUpdating) Name() string {
-	return w.name
-}
-
-func (w *waitStatefulSetUpdating) Tick() time.Duration {
-	return w.tick
-}
-
-func (w *waitStatefulSetUpdating) Timeout() time.Duration {
-	return w.timeout
-}
-
-func (w *waitStatefulSetUpdating) Handler() error {
-	labels := getLabels(w.cluster)
-	stsList, err := w.statefulSetController.ListStatefulSetByLabels(w.cluster.Namespace, labels)
-	if err != nil {
-		return err
+// preferredMasterNodes returns the names of the nodes matching the role hints of the cluster for a master,
+// or nil if the cluster has no role hints.
+func (r *ReconcileDistributedRedisCluster) preferredMasterNodes(cluster *redisv1alpha1.DistributedRedisCluster) (map[string]bool, error) {
+	hints := cluster.Spec.RoleHints
+	if hints == nil || len(hints.MasterNodeSelector) == 0 && len(hints.ReplicaNodeSelector) == 0 {
+		return nil, nil
+	}
+	nodes := &corev1.NodeList{}
+	if err := r.apiReader.List(context.TODO(), nodes); err != nil {
+		return nil, err
 	}
-	for _, sts := range stsList.Items {
-		if sts.Status.ReadyReplicas != (w.cluster.Spec.ClusterReplicas + 1) {
-			return nil
+	preferred := map[string]bool{}
+	for _, node := range nodes.Items {
+		set := labels.Set(node.Labels)
+		if len(hints.MasterNodeSelector) > 0 && !labels.SelectorFromSet(hints.MasterNodeSelector).Matches(set) {
+			continue
+		}
+		if len(hints.ReplicaNodeSelector) > 0 && labels.SelectorFromSet(hints.ReplicaNodeSelector).Matches(set) {
+			continue
 		}
+		preferred[node.Name] = true
 	}
-	return fmt.Errorf("statefulSet still not updated")
+	return preferred, nil
 }
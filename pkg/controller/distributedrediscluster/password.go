@@ -0,0 +1,144 @@
+package distributedrediscluster
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/config"
+	"github.com/ucloud/redis-cluster-operator/pkg/event"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisconfig"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/secrets"
+)
+
+// rotatePassword makes the redis nodes accept the password of the PasswordSecret without restarting them. The
+// nodes first accept both the current and the new password, the new password is then written to the Secret the
+// pods read their password from, and the previous one is removed once the grace period is over. It returns the
+// password to connect to the nodes with and the start of the rotation in progress, nil if none.
+func (r *ReconcileDistributedRedisCluster) rotatePassword(cluster *redisv1alpha1.DistributedRedisCluster,
+	pods []*corev1.Pod, tlsConfig *tls.Config, reqLogger logr.Logger) (string, *metav1.Time, error) {
+	password, err := getClusterPassword(r.client, cluster)
+	if err != nil {
+		return "", nil, Kubernetes.Wrap(err, "getClusterPassword")
+	}
+	if password == "" {
+		return "", nil, nil
+	}
+	secret := &corev1.Secret{}
+	err = r.client.Get(context.TODO(), types.NamespacedName{
+		Name:      cluster.CurrentPasswordSecretName(),
+		Namespace: cluster.Namespace,
+	}, secret)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return password, nil, nil
+		}
+		return "", nil, Kubernetes.Wrap(err, "GetCurrentPasswordSecret")
+	}
+	current := string(secret.Data[secrets.PasswordKey])
+	previous := string(secret.Data[secrets.PreviousPasswordKey])
+	if current == password && previous == "" {
+		return password, nil, nil
+	}
+
+	if current != password {
+		// a password changed again during the grace period replaces the previous password.
+		reqLogger.Info("rotating password", "secret", cluster.Spec.PasswordSecret.Name)
+		if major := redisconfig.MajorVersion(cluster.Spec.Image); major != 0 && major < 6 {
+			r.recorder.Event(cluster, corev1.EventTypeWarning, event.PasswordRotation,
+				fmt.Sprintf("Redis %d nodes only accept the new password, the clients must switch to it now", major))
+		}
+		if err := r.setPasswords(pods, tlsConfig, current, []string{password, current}, reqLogger); err != nil {
+			return "", nil, err
+		}
+		now := metav1.Now()
+		if err := r.updateCurrentPassword(secret, password, current, &now); err != nil {
+			return "", nil, Kubernetes.Wrap(err, "updateCurrentPassword")
+		}
+		return password, &now, nil
+	}
+
+	start, err := time.Parse(time.RFC3339, secret.Annotations[redisv1alpha1.AnnotationPasswordRotationTime])
+	if err != nil {
+		// the time is reset if it is missing, the grace period starts again.
+		now := metav1.Now()
+		if err := r.updateCurrentPassword(secret, password, previous, &now); err != nil {
+			return "", nil, Kubernetes.Wrap(err, "updateCurrentPassword")
+		}
+		return password, &now, nil
+	}
+	startTime := metav1.NewTime(start)
+	if time.Since(start) < cluster.PasswordGracePeriod() {
+		// the nodes restarted during the grace period only accept the new password.
+		if err := r.setPasswords(pods, tlsConfig, password, []string{password, previous}, reqLogger); err != nil {
+			return "", nil, err
+		}
+		return password, &startTime, nil
+	}
+	reqLogger.Info("removing previous password", "rotationTime", startTime)
+	if err := r.setPasswords(pods, tlsConfig, password, []string{password}, reqLogger); err != nil {
+		return "", nil, err
+	}
+	if err := r.updateCurrentPassword(secret, password, "", nil); err != nil {
+		return "", nil, Kubernetes.Wrap(err, "updateCurrentPassword")
+	}
+	return password, nil, nil
+}
+
+// setPasswords connects to the nodes with password, makes them accept the passwords and checks that they all
+// accept the first one. The nodes that already accept only the first one are connected with it.
+func (r *ReconcileDistributedRedisCluster) setPasswords(pods []*corev1.Pod, tlsConfig *tls.Config, password string,
+	passwords []string, reqLogger logr.Logger) error {
+	admin, err := newRedisAdmin(pods, "", password, tlsConfig, config.RedisConf(), reqLogger)
+	if err != nil {
+		return Redis.Wrap(err, "newRedisAdmin")
+	}
+	defer admin.Close()
+	if err := admin.SetPasswordsIfNeed(passwords); err != nil {
+		return Redis.Wrap(err, "SetPasswordsIfNeed")
+	}
+	if password != passwords[0] {
+		if err := admin.Connections().SetPassword(passwords[0]); err != nil {
+			return Redis.Wrap(err, "SetPassword")
+		}
+		var addrs []string
+		for _, pod := range pods {
+			addrs = append(addrs, podAddr(pod))
+		}
+		admin.Connections().AddAll(addrs)
+		if err := admin.SetPasswordsIfNeed(passwords); err != nil {
+			return Redis.Wrap(err, "SetPasswordsIfNeed")
+		}
+	}
+	if connected := len(admin.Connections().GetAll()); connected != len(pods) {
+		return Requeue.Wrap(fmt.Errorf("%d of %d nodes accept the password", connected, len(pods)), "setPasswords")
+	}
+	return nil
+}
+
+// updateCurrentPassword writes the password the nodes accept and the previous password they still accept,
+// if not empty, to the Secret the pods read their password from.
+func (r *ReconcileDistributedRedisCluster) updateCurrentPassword(secret *corev1.Secret, password, previous string,
+	rotationTime *metav1.Time) error {
+	secret.Data = map[string][]byte{secrets.PasswordKey: []byte(password)}
+	if previous != "" {
+		secret.Data[secrets.PreviousPasswordKey] = []byte(previous)
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	if rotationTime != nil {
+		secret.Annotations[redisv1alpha1.AnnotationPasswordRotationTime] = rotationTime.UTC().Format(time.RFC3339)
+	} else {
+		delete(secret.Annotations, redisv1alpha1.AnnotationPasswordRotationTime)
+	}
+	return r.client.Update(context.TODO(), secret)
+}
@@ -0,0 +1,137 @@
+package distributedrediscluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/event"
+	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
+)
+
+// rollbackIfNeed restores the last known-good template of the StatefulSets when the cluster is not OK
+// within the deadline of its automatic rollback after an update.
+func (r *ReconcileDistributedRedisCluster) rollbackIfNeed(cluster *redisv1alpha1.DistributedRedisCluster, reqLogger logr.Logger) error {
+	if cluster.Spec.AutoRollback == nil || cluster.Status.UpdateStartTime == nil || cluster.IsRolledBack() {
+		return nil
+	}
+	deadline := cluster.Spec.AutoRollback.Deadline()
+	if time.Since(cluster.Status.UpdateStartTime.Time) < deadline {
+		return nil
+	}
+
+	reason := fmt.Sprintf("cluster not %s %v after the update of its statefulsets: %s",
+		redisv1alpha1.ClusterStatusOK, deadline, cluster.Status.Reason)
+	var rolledBack []string
+	for i := 0; i < int(cluster.Spec.MasterSize); i++ {
+		name := statefulsets.ClusterStatefulSetName(cluster.Name, i)
+		sts, err := r.statefulSetController.GetStatefulSet(cluster.Namespace, name)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		data, ok := sts.Annotations[redisv1alpha1.AnnotationKnownGoodTemplate]
+		if !ok {
+			continue
+		}
+		template := corev1.PodTemplateSpec{}
+		if err := json.Unmarshal([]byte(data), &template); err != nil {
+			reqLogger.Error(err, "invalid known-good template", "statefulSet", name)
+			continue
+		}
+		if apiequality.Semantic.DeepEqual(template, sts.Spec.Template) {
+			continue
+		}
+		sts.Spec.Template = template
+		if err := r.statefulSetController.UpdateStatefulSet(sts); err != nil {
+			return err
+		}
+		rolledBack = append(rolledBack, name)
+	}
+	if len(rolledBack) == 0 {
+		reason += ", no known-good template to roll back to"
+	} else {
+		reason += fmt.Sprintf(", statefulsets %v rolled back", rolledBack)
+	}
+
+	reqLogger.Info("rollback", "reason", reason)
+	r.recorder.Event(cluster, corev1.EventTypeWarning, event.RollbackUpgrade, reason)
+	cluster.Status.Rollback = &redisv1alpha1.RollbackStatus{
+		Generation: cluster.Generation,
+		Reason:     reason,
+		Time:       metav1.Now(),
+	}
+	cluster.Status.UpdateStartTime = nil
+	return r.crController.UpdateCRStatus(cluster)
+}
+
+// recordKnownGood saves the pod template of the StatefulSets of the OK cluster as their known-good template.
+func (r *ReconcileDistributedRedisCluster) recordKnownGood(cluster *redisv1alpha1.DistributedRedisCluster) error {
+	for i := 0; i < int(cluster.Spec.MasterSize); i++ {
+		name := statefulsets.ClusterStatefulSetName(cluster.Name, i)
+		sts, err := r.statefulSetController.GetStatefulSet(cluster.Namespace, name)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(sts.Spec.Template)
+		if err != nil {
+			return err
+		}
+		if sts.Annotations[redisv1alpha1.AnnotationKnownGoodTemplate] == string(data) {
+			continue
+		}
+		if sts.Annotations == nil {
+			sts.Annotations = map[string]string{}
+		}
+		sts.Annotations[redisv1alpha1.AnnotationKnownGoodTemplate] = string(data)
+		if err := r.statefulSetController.UpdateStatefulSet(sts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restartFailedOutdatedPods deletes the pods that are not ready and do not run the update revision of their
+// StatefulSet, they do not serve and would otherwise block the upgrade or the rollback of the cluster.
+func (r *ReconcileDistributedRedisCluster) restartFailedOutdatedPods(ctx *syncContext) error {
+	cluster := ctx.cluster
+	for i := 0; i < int(cluster.Spec.MasterSize); i++ {
+		name := statefulsets.ClusterStatefulSetName(cluster.Name, i)
+		sts, err := r.statefulSetController.GetStatefulSet(cluster.Namespace, name)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if sts.Status.ObservedGeneration < sts.Generation {
+			continue
+		}
+		pods, err := r.statefulSetController.GetStatefulSetPods(cluster.Namespace, name)
+		if err != nil {
+			return err
+		}
+		for _, pod := range outdatedPods(sts, pods.Items) {
+			if pod.DeletionTimestamp != nil || k8sutil.IsPodReady(pod) {
+				continue
+			}
+			ctx.reqLogger.Info("restart outdated pod that is not ready", "pod", pod.Name)
+			r.recorder.Event(cluster, corev1.EventTypeNormal, event.RestartPod,
+				fmt.Sprintf("restarting pod %s, it is not ready and runs an outdated revision", pod.Name))
+			if err := r.podController.DeletePod(pod); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
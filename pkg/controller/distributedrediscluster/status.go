@@ -9,6 +9,8 @@ import (
 	corev1 "k8s.io/api/core/v1"
 
 	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisconfig"
 	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
 	"github.com/ucloud/redis-cluster-operator/pkg/utils"
 )
@@ -45,6 +47,18 @@ func buildClusterStatus(clusterInfos *redisutil.ClusterInfos, pods []*corev1.Pod
 		Status:  oldStatus.Status,
 		Reason:  oldStatus.Reason,
 		Restore: oldStatus.Restore,
+
+		ContinuousBackup:     oldStatus.ContinuousBackup,
+		Upgrade:              oldStatus.Upgrade,
+		Canary:               oldStatus.Canary,
+		Shards:               oldStatus.Shards,
+		UpdateStartTime:      oldStatus.UpdateStartTime,
+		Rollback:             oldStatus.Rollback,
+		Config:               oldStatus.Config,
+		Memory:               oldStatus.Memory,
+		TLS:                  oldStatus.TLS,
+		Users:                oldStatus.Users,
+		PasswordRotationTime: oldStatus.PasswordRotationTime,
 	}
 
 	nbMaster := int32(0)
@@ -52,10 +66,11 @@ func buildClusterStatus(clusterInfos *redisutil.ClusterInfos, pods []*corev1.Pod
 
 	for _, pod := range pods {
 		newNode := redisv1alpha1.RedisClusterNode{
-			PodName:  pod.Name,
-			NodeName: pod.Spec.NodeName,
-			IP:       pod.Status.PodIP,
-			Slots:    []string{},
+			PodName:         pod.Name,
+			NodeName:        pod.Spec.NodeName,
+			IP:              pod.Status.PodIP,
+			Slots:           []string{},
+			ExternalAddress: k8sutil.AnnouncedAddress(pod),
 		}
 		if len(pod.OwnerReferences) > 0 {
 			if pod.OwnerReferences[0].Kind == "StatefulSet" {
@@ -63,7 +78,7 @@ func buildClusterStatus(clusterInfos *redisutil.ClusterInfos, pods []*corev1.Pod
 			}
 		}
 		redisNodes, err := clusterInfos.GetNodes().GetNodesByFunc(func(node *redisutil.Node) bool {
-			return node.IP == pod.Status.PodIP
+			return node.MatchPod(pod)
 		})
 		if err != nil {
 			reqLogger.Error(err, fmt.Sprintf("unable to retrieve the associated redis node with the pod: %s, ip:%s", pod.Name, pod.Status.PodIP))
@@ -81,6 +96,7 @@ func buildClusterStatus(clusterInfos *redisutil.ClusterInfos, pods []*corev1.Pod
 			newNode.ID = redisNode.ID
 			newNode.Role = redisNode.GetRole()
 			newNode.Port = redisNode.Port
+			newNode.Hostname = redisNode.Hostname
 			newNode.Slots = []string{}
 			if redisutil.IsSlave(redisNode) && redisNode.MasterReferent != "" {
 				nbSlaveByMaster[redisNode.MasterReferent] = nbSlaveByMaster[redisNode.MasterReferent] + 1
@@ -116,6 +132,91 @@ func buildClusterStatus(clusterInfos *redisutil.ClusterInfos, pods []*corev1.Pod
 	return status
 }
 
+// memoryStatus returns the memory of the masters of the cluster, or nil if a master cannot be reached.
+func memoryStatus(admin redisutil.IAdmin, clusterInfos *redisutil.ClusterInfos, reqLogger logr.Logger) *redisv1alpha1.MemoryStatus {
+	masters, err := clusterInfos.GetNodes().GetNodesByFunc(redisutil.IsMasterWithSlot)
+	if err != nil {
+		reqLogger.Error(err, "memoryStatus")
+		return nil
+	}
+	status := &redisv1alpha1.MemoryStatus{}
+	for _, master := range masters {
+		c, err := admin.Connections().Get(master.IPPort())
+		if err != nil {
+			reqLogger.Error(err, "memoryStatus", "node", master.IPPort())
+			return nil
+		}
+		info, err := redisutil.GetMemoryInfo(c)
+		if err != nil {
+			reqLogger.Error(err, "memoryStatus", "node", master.IPPort())
+			return nil
+		}
+		status.UsedMemory += info.UsedMemory
+		if status.MaxMemory == 0 || info.MaxMemory != 0 && info.MaxMemory < status.MaxMemory {
+			status.MaxMemory = info.MaxMemory
+		}
+	}
+	return status
+}
+
+// memoryUpdateRatio is the change of the used memory worth an update of the status, it changes with every write.
+const memoryUpdateRatio = 0.01
+
+func memoryChanged(old, new *redisv1alpha1.MemoryStatus) bool {
+	if old == nil || new == nil {
+		return old != new
+	}
+	if old.MaxMemory != new.MaxMemory {
+		return true
+	}
+	return math.Abs(float64(new.UsedMemory-old.UsedMemory)) > memoryUpdateRatio*float64(old.UsedMemory)
+}
+
+// tlsChanged compares the TLS statuses, the expiry is compared with Equal as it went through JSON.
+func tlsChanged(old, new *redisv1alpha1.TLSStatus) bool {
+	if old == nil || new == nil {
+		return old != new
+	}
+	return old.SerialNumber != new.SerialNumber || !old.NotAfter.Equal(&new.NotAfter) ||
+		!reflect.DeepEqual(old.OutdatedNodes, new.OutdatedNodes)
+}
+
+// configStatus returns the state of the config of the cluster, the hot parameters are set at runtime
+// and the restart-required ones are applied once all the pods run the update revision of their StatefulSet.
+func configStatus(old *redisv1alpha1.ConfigStatus, config map[string]string, upgrading bool) *redisv1alpha1.ConfigStatus {
+	hot, restart := redisconfig.Split(config)
+	status := &redisv1alpha1.ConfigStatus{Applied: hot, Pending: map[string]string{}}
+	var applied map[string]string
+	if old != nil {
+		applied = old.Applied
+	}
+	for key, value := range restart {
+		if !upgrading || applied[key] == value {
+			status.Applied[key] = value
+		} else {
+			status.Pending[key] = value
+		}
+	}
+	if upgrading {
+		// the removed restart-required parameters are applied until the pods are restarted
+		for key, value := range applied {
+			if _, ok := restart[key]; !ok && redisconfig.IsRestartRequired(key) {
+				status.Applied[key] = value
+			}
+		}
+	}
+	if len(status.Applied) == 0 {
+		status.Applied = nil
+	}
+	if len(status.Pending) == 0 {
+		status.Pending = nil
+	}
+	if status.Applied == nil && status.Pending == nil {
+		return nil
+	}
+	return status
+}
+
 func (r *ReconcileDistributedRedisCluster) updateClusterIfNeed(cluster *redisv1alpha1.DistributedRedisCluster,
 	newStatus *redisv1alpha1.DistributedRedisClusterStatus,
 	reqLogger logr.Logger) {
@@ -148,6 +249,46 @@ func compareStatus(old, new *redisv1alpha1.DistributedRedisClusterStatus, reqLog
 		return true
 	}
 
+	if !reflect.DeepEqual(old.Upgrade, new.Upgrade) {
+		reqLogger.V(4).Info("compare status.Upgrade changed")
+		return true
+	}
+
+	if !reflect.DeepEqual(old.Canary, new.Canary) || !reflect.DeepEqual(old.Shards, new.Shards) {
+		reqLogger.V(4).Info("compare status.Canary or status.Shards changed")
+		return true
+	}
+
+	if !old.UpdateStartTime.Equal(new.UpdateStartTime) || !reflect.DeepEqual(old.Rollback, new.Rollback) {
+		reqLogger.V(4).Info("compare status.UpdateStartTime or status.Rollback changed")
+		return true
+	}
+
+	if !reflect.DeepEqual(old.Config, new.Config) {
+		reqLogger.V(4).Info("compare status.Config changed")
+		return true
+	}
+
+	if memoryChanged(old.Memory, new.Memory) {
+		reqLogger.V(4).Info("compare status.Memory changed")
+		return true
+	}
+
+	if tlsChanged(old.TLS, new.TLS) {
+		reqLogger.V(4).Info("compare status.TLS changed")
+		return true
+	}
+
+	if !reflect.DeepEqual(old.Users, new.Users) {
+		reqLogger.V(4).Info("compare status.Users changed")
+		return true
+	}
+
+	if !old.PasswordRotationTime.Equal(new.PasswordRotationTime) {
+		reqLogger.V(4).Info("compare status.PasswordRotationTime changed")
+		return true
+	}
+
 	for _, nodeA := range old.Nodes {
 		found := false
 		for _, nodeB := range new.Nodes {
@@ -182,6 +323,12 @@ func compareNodes(nodeA, nodeB *redisv1alpha1.RedisClusterNode, reqLogger logr.L
 	if utils.CompareStringValue("Node.Role", string(nodeA.Role), string(nodeB.Role), reqLogger) {
 		return true
 	}
+	if utils.CompareStringValue("Node.ExternalAddress", nodeA.ExternalAddress, nodeB.ExternalAddress, reqLogger) {
+		return true
+	}
+	if utils.CompareStringValue("Node.Hostname", nodeA.Hostname, nodeB.Hostname, reqLogger) {
+		return true
+	}
 
 	sizeSlotsA := 0
 	sizeSlotsB := 0
@@ -0,0 +1,53 @@
+package distributedrediscluster
+
+import (
+	"reflect"
+	"testing"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+)
+
+func TestConfigStatus(t *testing.T) {
+	old := &redisv1alpha1.ConfigStatus{
+		Applied: map[string]string{"maxmemory": "1gb", "databases": "16", "io-threads": "2"},
+	}
+	config := map[string]string{"maxmemory": "2gb", "databases": "32"}
+
+	got := configStatus(old, config, true)
+	want := &redisv1alpha1.ConfigStatus{
+		Applied: map[string]string{"maxmemory": "2gb", "io-threads": "2"},
+		Pending: map[string]string{"databases": "32"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("configStatus() while upgrading = %v, want %v", got, want)
+	}
+
+	got = configStatus(got, config, false)
+	want = &redisv1alpha1.ConfigStatus{Applied: config}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("configStatus() = %v, want %v", got, want)
+	}
+
+	if got := configStatus(old, nil, false); got != nil {
+		t.Errorf("configStatus() without config = %v, want nil", got)
+	}
+}
+
+func TestMemoryChanged(t *testing.T) {
+	old := &redisv1alpha1.MemoryStatus{UsedMemory: 1000, MaxMemory: 4000}
+	tests := []struct {
+		name string
+		new  *redisv1alpha1.MemoryStatus
+		want bool
+	}{
+		{"nil", nil, true},
+		{"small change", &redisv1alpha1.MemoryStatus{UsedMemory: 1005, MaxMemory: 4000}, false},
+		{"large change", &redisv1alpha1.MemoryStatus{UsedMemory: 1100, MaxMemory: 4000}, true},
+		{"maxmemory", &redisv1alpha1.MemoryStatus{UsedMemory: 1000, MaxMemory: 8000}, true},
+	}
+	for _, tt := range tests {
+		if got := memoryChanged(old, tt.new); got != tt.want {
+			t.Errorf("memoryChanged() %s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -6,11 +6,13 @@ import (
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
 	"github.com/ucloud/redis-cluster-operator/pkg/controller/clustering"
 	"github.com/ucloud/redis-cluster-operator/pkg/controller/manager"
 	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/osm"
 	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
 	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
 )
@@ -26,6 +28,8 @@ type syncContext struct {
 	healer       manager.IHeal
 	pods         []*corev1.Pod
 	reqLogger    logr.Logger
+	// preferredMasterNodes are the names of the nodes preferred for the masters by the role hints of the cluster.
+	preferredMasterNodes map[string]bool
 }
 
 func (r *ReconcileDistributedRedisCluster) ensureCluster(ctx *syncContext) error {
@@ -36,7 +40,19 @@ func (r *ReconcileDistributedRedisCluster) ensureCluster(ctx *syncContext) error
 		}
 		return StopRetry.Wrap(err, "stop retry")
 	}
+	if err := r.ensureCanary(cluster, ctx.reqLogger); err != nil {
+		return Kubernetes.Wrap(err, "ensureCanary")
+	}
 	labels := getLabels(cluster)
+	if err := r.ensurer.EnsureRedisCertificate(cluster, labels); err != nil {
+		return Kubernetes.Wrap(err, "EnsureRedisCertificate")
+	}
+	if err := r.ensurer.EnsureRedisOperatorSecret(cluster, labels); err != nil {
+		return Kubernetes.Wrap(err, "EnsureRedisOperatorSecret")
+	}
+	if err := r.ensurer.EnsureRedisPasswordSecret(cluster, labels); err != nil {
+		return Kubernetes.Wrap(err, "EnsureRedisPasswordSecret")
+	}
 	if err := r.ensurer.EnsureRedisConfigMap(cluster, labels); err != nil {
 		return Kubernetes.Wrap(err, "EnsureRedisConfigMap")
 	}
@@ -44,19 +60,17 @@ func (r *ReconcileDistributedRedisCluster) ensureCluster(ctx *syncContext) error
 		ctx.reqLogger.Error(err, "EnsureRedisStatefulSets")
 		return Kubernetes.Wrap(err, "EnsureRedisStatefulSets")
 	} else if updated {
-		// update cluster status = RollingUpdate immediately when cluster's image or resource or password changed
+		// update cluster status = RollingUpdate immediately when cluster's image or resource or password changed,
+		// the pods are restarted by upgradeCluster.
 		SetClusterUpdating(&cluster.Status, "cluster spec updated")
-		r.crController.UpdateCRStatus(cluster)
-		waiter := &waitStatefulSetUpdating{
-			name:                  "waitStatefulSetUpdating",
-			timeout:               30 * time.Second * time.Duration(cluster.Spec.ClusterReplicas+2),
-			tick:                  5 * time.Second,
-			statefulSetController: r.statefulSetController,
-			cluster:               cluster,
-		}
-		if err := waiting(waiter, ctx.reqLogger); err != nil {
-			return err
+		if cluster.Status.UpdateStartTime == nil {
+			now := metav1.Now()
+			cluster.Status.UpdateStartTime = &now
 		}
+		r.crController.UpdateCRStatus(cluster)
+	}
+	if err := r.rollbackIfNeed(cluster, ctx.reqLogger); err != nil {
+		return Kubernetes.Wrap(err, "rollbackIfNeed")
 	}
 	if err := r.ensurer.EnsureRedisHeadLessSvcs(cluster, labels); err != nil {
 		return Kubernetes.Wrap(err, "EnsureRedisHeadLessSvcs")
@@ -64,6 +78,9 @@ func (r *ReconcileDistributedRedisCluster) ensureCluster(ctx *syncContext) error
 	if err := r.ensurer.EnsureRedisSvc(cluster, labels); err != nil {
 		return Kubernetes.Wrap(err, "EnsureRedisSvc")
 	}
+	if err := r.ensurer.EnsureRedisExternalSvcs(cluster, labels); err != nil {
+		return Kubernetes.Wrap(err, "EnsureRedisExternalSvcs")
+	}
 	if err := r.ensurer.EnsureRedisOSMSecret(cluster, labels); err != nil {
 		if k8sutil.IsRequestRetryable(err) {
 			return Kubernetes.Wrap(err, "EnsureRedisOSMSecret")
@@ -77,6 +94,9 @@ func (r *ReconcileDistributedRedisCluster) waitPodReady(ctx *syncContext) error
 	if _, err := ctx.healer.FixTerminatingPods(ctx.cluster, 5*time.Minute); err != nil {
 		return Kubernetes.Wrap(err, "FixTerminatingPods")
 	}
+	if err := r.restartFailedOutdatedPods(ctx); err != nil {
+		return Kubernetes.Wrap(err, "restartFailedOutdatedPods")
+	}
 	if err := r.checker.CheckRedisNodeNum(ctx.cluster); err != nil {
 		return Requeue.Wrap(err, "CheckRedisNodeNum")
 	}
@@ -88,12 +108,18 @@ func (r *ReconcileDistributedRedisCluster) validate(cluster *redisv1alpha1.Distr
 	var update bool
 	var err error
 
+	// the webhook runs the same checks, it may be disabled.
+	if err := cluster.Spec.Validate(); err != nil {
+		return err
+	}
 	if cluster.IsRestoreFromBackup() && !cluster.IsRestored() {
 		update, err = r.validateRestore(cluster, reqLogger)
 		if err != nil {
 			return err
 		}
 	}
+	// the mutating webhook applies the defaults at admission time, they are only missing when it is disabled,
+	// except the image of a cluster restored from a backup, validateRestore sets it from the backup first.
 	updateDefault := cluster.DefaultSpec(reqLogger)
 	if update || updateDefault {
 		return r.crController.UpdateCR(cluster)
@@ -103,9 +129,18 @@ func (r *ReconcileDistributedRedisCluster) validate(cluster *redisv1alpha1.Distr
 
 func (r *ReconcileDistributedRedisCluster) validateRestore(cluster *redisv1alpha1.DistributedRedisCluster, reqLogger logr.Logger) (bool, error) {
 	update := false
+	if err := cluster.Spec.Init.BackupSource.Validate(); err != nil {
+		return update, err
+	}
 	if cluster.Status.Restore.Backup == nil {
 		initSpec := cluster.Spec.Init
-		backup, err := r.crController.GetRedisClusterBackup(initSpec.BackupSource.Namespace, initSpec.BackupSource.Name)
+		var backup *redisv1alpha1.RedisClusterBackup
+		var err error
+		if source := initSpec.BackupSource.Bucket; source != nil {
+			backup, err = osm.ReadBackupMetadata(r.client, source, cluster.Namespace)
+		} else {
+			backup, err = r.crController.GetRedisClusterBackup(initSpec.BackupSource.Namespace, initSpec.BackupSource.Name)
+		}
 		if err != nil {
 			reqLogger.Error(err, "GetRedisClusterBackup")
 			return update, err
@@ -167,6 +202,7 @@ func (r *ReconcileDistributedRedisCluster) syncCluster(ctx *syncContext) error {
 		return Cluster.Wrap(err, "newRedisCluster")
 	}
 	clusterCtx := clustering.NewCtx(rCluster, nodes, cluster.Spec.MasterSize, cluster.Name, ctx.reqLogger)
+	clusterCtx.SetPreferredMasterNodes(ctx.preferredMasterNodes)
 	if err := clusterCtx.DispatchMasters(); err != nil {
 		return Cluster.Wrap(err, "DispatchMasters")
 	}
@@ -282,3 +318,45 @@ func (r *ReconcileDistributedRedisCluster) scalingDown(ctx *syncContext, current
 	}
 	return nil
 }
+
+// syncContinuousBackup stores the metadata of the continuous backup next to the shipped AOF, so that
+// it can be restored from its bucket, and reports the watermarks of the shards in the status.
+func (r *ReconcileDistributedRedisCluster) syncContinuousBackup(cluster *redisv1alpha1.DistributedRedisCluster, reqLogger logr.Logger) error {
+	if cluster.Spec.ContinuousBackup == nil {
+		if cluster.Status.ContinuousBackup == nil {
+			return nil
+		}
+		cluster.Status.ContinuousBackup = nil
+		return r.crController.UpdateCRStatus(cluster)
+	}
+	if err := osm.WriteContinuousBackupMetadata(r.client, cluster); err != nil {
+		return err
+	}
+	shards, err := osm.ReadAOFWatermarks(r.client, cluster)
+	if err != nil {
+		return err
+	}
+	status := &redisv1alpha1.ContinuousBackupStatus{
+		Location: cluster.ContinuousBackupLocation(),
+		Shards:   shards,
+	}
+	if !continuousBackupStatusChanged(cluster.Status.ContinuousBackup, status) {
+		return nil
+	}
+	reqLogger.V(3).Info("continuous backup status changed", "location", status.Location)
+	cluster.Status.ContinuousBackup = status
+	return r.crController.UpdateCRStatus(cluster)
+}
+
+func continuousBackupStatusChanged(old, new *redisv1alpha1.ContinuousBackupStatus) bool {
+	if old == nil || old.Location != new.Location || len(old.Shards) != len(new.Shards) {
+		return true
+	}
+	for i := range old.Shards {
+		a, b := old.Shards[i], new.Shards[i]
+		if a.Index != b.Index || a.NodeID != b.NodeID || !a.Watermark.Equal(b.Watermark) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package distributedrediscluster
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/config"
+	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
+)
+
+// reloadCertificate makes the redis nodes serve the certificate of the TLS Secret of the cluster. The kubelet
+// updates the Secret mounted in the pods some time after it changes, the nodes serving another certificate
+// reload it with CONFIG SET tls-cert-file, which reloads the certificate, the key and the CA without a restart.
+// The nodes whose mounted Secret is not updated yet are reported in the status and retried on the next reconcile.
+// It returns nil if the cluster has no TLS.
+func (r *ReconcileDistributedRedisCluster) reloadCertificate(cluster *redisv1alpha1.DistributedRedisCluster,
+	admin redisutil.IAdmin, reqLogger logr.Logger) (*redisv1alpha1.TLSStatus, error) {
+	secret, err := k8sutil.GetClusterTLSSecret(r.client, cluster)
+	if err != nil || secret == nil {
+		return nil, err
+	}
+	cert, err := redisutil.ParseCertificate(secret.Data[corev1.TLSCertKey])
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := k8sutil.TLSConfigFromSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &redisv1alpha1.TLSStatus{
+		SerialNumber: cert.SerialNumber.String(),
+		NotAfter:     metav1.NewTime(cert.NotAfter),
+	}
+	timeout := time.Duration(config.RedisConf().DialTimeout) * time.Millisecond
+	for addr, c := range admin.Connections().GetAll() {
+		if servesCertificate(addr, timeout, tlsConfig, cert, reqLogger) {
+			continue
+		}
+		reqLogger.Info("reloading certificate", "node", addr, "serialNumber", status.SerialNumber)
+		resp := c.Cmd("CONFIG", "SET", "tls-cert-file", statefulsets.TLSCertFile)
+		if err := admin.Connections().ValidateResp(resp, addr, "Unable to reload the certificate"); err != nil {
+			reqLogger.Error(err, "cannot reload certificate", "node", addr)
+		}
+		if !servesCertificate(addr, timeout, tlsConfig, cert, reqLogger) {
+			status.OutdatedNodes = append(status.OutdatedNodes, addr)
+		}
+	}
+	sort.Strings(status.OutdatedNodes)
+	return status, nil
+}
+
+// servesCertificate returns true if the node listening on addr presents cert.
+func servesCertificate(addr string, timeout time.Duration, tlsConfig *tls.Config, cert *x509.Certificate,
+	reqLogger logr.Logger) bool {
+	peer, err := redisutil.PeerCertificate(addr, timeout, tlsConfig)
+	if err != nil {
+		reqLogger.Error(err, "cannot get the certificate of the node", "node", addr)
+		return false
+	}
+	return peer.Equal(cert)
+}
@@ -0,0 +1,324 @@
+package distributedrediscluster
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
+)
+
+// failoverTimeout is the time to wait for a CLUSTER FAILOVER to promote the slave before sending it again.
+const failoverTimeout = time.Minute
+
+// shardUpgrade is the next step of the upgrade of a shard.
+type shardUpgrade struct {
+	phase   redisv1alpha1.UpgradePhase
+	pod     string
+	message string
+}
+
+// upgradeCluster restarts the pods that do not run the update revision of their StatefulSet. The
+// StatefulSets use the OnDelete strategy and the shards are upgraded one at a time: the slaves are
+// restarted first, then an upgraded slave in sync with the master is promoted by a CLUSTER FAILOVER
+// and the former master is restarted as a slave. It returns true while the upgrade is in progress,
+// status.Upgrade reports its progress.
+func (r *ReconcileDistributedRedisCluster) upgradeCluster(ctx *syncContext, clusterInfos *redisutil.ClusterInfos,
+	status *redisv1alpha1.DistributedRedisClusterStatus) (bool, error) {
+	cluster := ctx.cluster
+	shards := make([]redisv1alpha1.ShardStatus, cluster.Spec.MasterSize)
+	stsList := make([]*appsv1.StatefulSet, cluster.Spec.MasterSize)
+	podLists := make([][]corev1.Pod, cluster.Spec.MasterSize)
+	for i := range shards {
+		name := statefulsets.ClusterStatefulSetName(cluster.Name, i)
+		sts, err := r.statefulSetController.GetStatefulSet(cluster.Namespace, name)
+		if err != nil {
+			return false, Kubernetes.Wrap(err, "GetStatefulSet")
+		}
+		podList, err := r.statefulSetController.GetStatefulSetPods(cluster.Namespace, name)
+		if err != nil {
+			return false, Kubernetes.Wrap(err, "GetStatefulSetPods")
+		}
+		stsList[i], podLists[i] = sts, podList.Items
+		shards[i] = redisv1alpha1.ShardStatus{
+			Index:    i,
+			Image:    statefulsets.RedisContainer(sts).Image,
+			Upgraded: sts.Status.ObservedGeneration >= sts.Generation && len(outdatedPods(sts, podList.Items)) == 0,
+		}
+	}
+	status.Shards = shards
+
+	upgraded := int32(0)
+	for i, sts := range stsList {
+		if shards[i].Upgraded {
+			upgraded++
+			continue
+		}
+		if sts.Status.ObservedGeneration < sts.Generation {
+			setUpgradeStatus(status, i, upgraded, shardUpgrade{
+				phase:   redisv1alpha1.UpgradePhaseReplicas,
+				message: fmt.Sprintf("waiting for the revision of statefulset %s", sts.Name),
+			})
+			return true, nil
+		}
+
+		step, err := r.upgradeShard(ctx, clusterInfos, sts, podLists[i], previousShardUpgrade(status.Upgrade, i))
+		if err != nil {
+			return false, err
+		}
+		ctx.reqLogger.Info("upgrading shard", "shard", i, "phase", step.phase, "pod", step.pod, "message", step.message)
+		setUpgradeStatus(status, i, upgraded, step)
+		return true, nil
+	}
+	status.Upgrade = nil
+	return false, nil
+}
+
+// ensureCanary records the stable image when a canary upgrade starts, and ends the canary once the
+// redis.kun/canary annotation asks to promote or abort it.
+func (r *ReconcileDistributedRedisCluster) ensureCanary(cluster *redisv1alpha1.DistributedRedisCluster, reqLogger logr.Logger) error {
+	action, annotated := cluster.Annotations[redisv1alpha1.AnnotationCanary]
+	if cluster.Spec.Canary == nil {
+		if annotated {
+			reqLogger.Info("no canary upgrade, remove annotation", "action", action)
+			delete(cluster.Annotations, redisv1alpha1.AnnotationCanary)
+			if err := r.crController.UpdateCR(cluster); err != nil {
+				return err
+			}
+		}
+		if cluster.Status.Canary != nil {
+			cluster.Status.Canary = nil
+			return r.crController.UpdateCRStatus(cluster)
+		}
+		return nil
+	}
+
+	if cluster.Status.Canary == nil {
+		stableImage, err := r.stableImage(cluster)
+		if err != nil {
+			return err
+		}
+		reqLogger.Info("canary upgrade started", "shards", cluster.Spec.Canary.Shards, "image", cluster.Spec.Image,
+			"stableImage", stableImage)
+		cluster.Status.Canary = &redisv1alpha1.CanaryStatus{StableImage: stableImage}
+		return r.crController.UpdateCRStatus(cluster)
+	}
+
+	switch action {
+	case redisv1alpha1.CanaryPromote:
+		reqLogger.Info("canary promoted", "image", cluster.Spec.Image)
+	case redisv1alpha1.CanaryAbort:
+		reqLogger.Info("canary aborted", "image", cluster.Spec.Image, "stableImage", cluster.Status.Canary.StableImage)
+		cluster.Spec.Image = cluster.Status.Canary.StableImage
+	default:
+		if annotated {
+			reqLogger.Info("invalid canary annotation", "action", action)
+		}
+		return nil
+	}
+	cluster.Spec.Canary = nil
+	delete(cluster.Annotations, redisv1alpha1.AnnotationCanary)
+	if err := r.crController.UpdateCR(cluster); err != nil {
+		return err
+	}
+	cluster.Status.Canary = nil
+	return r.crController.UpdateCRStatus(cluster)
+}
+
+// stableImage returns the image of the first existing StatefulSet out of the canary, or the image of
+// the spec if there is none.
+func (r *ReconcileDistributedRedisCluster) stableImage(cluster *redisv1alpha1.DistributedRedisCluster) (string, error) {
+	for i := 0; i < int(cluster.Spec.MasterSize); i++ {
+		if cluster.Spec.Canary.Includes(i) {
+			continue
+		}
+		sts, err := r.statefulSetController.GetStatefulSet(cluster.Namespace, statefulsets.ClusterStatefulSetName(cluster.Name, i))
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return statefulsets.RedisContainer(sts).Image, nil
+	}
+	return cluster.Spec.Image, nil
+}
+
+// upgradeShard restarts or promotes at most one node of the shard, once all its nodes are ready and in sync.
+func (r *ReconcileDistributedRedisCluster) upgradeShard(ctx *syncContext, clusterInfos *redisutil.ClusterInfos,
+	sts *appsv1.StatefulSet, pods []corev1.Pod, previous *redisv1alpha1.UpgradeStatus) (shardUpgrade, error) {
+	// the outdated slaves are the former masters once a slave has been promoted
+	slavePhase := redisv1alpha1.UpgradePhaseReplicas
+	if previous != nil && previous.Phase != redisv1alpha1.UpgradePhaseReplicas {
+		slavePhase = redisv1alpha1.UpgradePhaseMaster
+	}
+	wait := func(format string, args ...interface{}) (shardUpgrade, error) {
+		step := shardUpgrade{phase: slavePhase, message: fmt.Sprintf(format, args...)}
+		if previous != nil {
+			step.phase, step.pod = previous.Phase, previous.Pod
+		}
+		return step, nil
+	}
+
+	var master *redisutil.Node
+	var masterPod *corev1.Pod
+	slaves := map[string]*redisutil.Node{}
+	// preferred are the slaves running on the nodes preferred for the masters by the role hints.
+	preferred := map[string]bool{}
+	for i := range pods {
+		pod := &pods[i]
+		if pod.DeletionTimestamp != nil || !k8sutil.IsPodReady(pod) {
+			return wait("waiting for pod %s to be ready", pod.Name)
+		}
+		nodes, err := clusterInfos.GetNodes().GetNodesByFunc(func(node *redisutil.Node) bool {
+			return node.MatchPod(pod)
+		})
+		if err != nil || len(nodes) != 1 {
+			return wait("waiting for pod %s to join the cluster", pod.Name)
+		}
+		node := nodes[0]
+		switch {
+		case redisutil.IsMasterWithSlot(node):
+			if master != nil {
+				return wait("waiting for the failover of statefulset %s", sts.Name)
+			}
+			master, masterPod = node, pod
+		case redisutil.IsSlave(node):
+			slaves[pod.Name] = node
+			preferred[pod.Name] = ctx.preferredMasterNodes[pod.Spec.NodeName]
+		default:
+			return wait("waiting for pod %s to join the cluster", pod.Name)
+		}
+	}
+	if master == nil {
+		return wait("waiting for the master of statefulset %s", sts.Name)
+	}
+
+	masterInfo, err := replicationInfo(ctx.admin, master)
+	if err != nil {
+		return shardUpgrade{}, Redis.Wrap(err, "GetReplicationInfo")
+	}
+	offsets := map[string]int64{}
+	for name, slave := range slaves {
+		if slave.MasterReferent != master.ID {
+			return wait("waiting for pod %s to replicate master %s", name, master.ID)
+		}
+		info, err := replicationInfo(ctx.admin, slave)
+		if err != nil {
+			return shardUpgrade{}, Redis.Wrap(err, "GetReplicationInfo")
+		}
+		if !info.IsSlaveInSync(masterInfo.MasterReplOffset, redisv1alpha1.DefaultMaxSlaveLag) {
+			return wait("waiting for pod %s to be in sync with master %s", name, master.ID)
+		}
+		offsets[name] = info.SlaveReplOffset
+	}
+
+	masterOutdated := false
+	for _, pod := range outdatedPods(sts, pods) {
+		if pod == masterPod {
+			masterOutdated = true
+			continue
+		}
+		if err := r.podController.DeletePod(pod); err != nil {
+			return shardUpgrade{}, Kubernetes.Wrap(err, "DeletePod")
+		}
+		return shardUpgrade{phase: slavePhase, pod: pod.Name, message: fmt.Sprintf("restarting slave %s", pod.Name)}, nil
+	}
+	if !masterOutdated {
+		return wait("waiting for statefulset %s", sts.Name)
+	}
+
+	if len(slaves) == 0 {
+		if err := r.podController.DeletePod(masterPod); err != nil {
+			return shardUpgrade{}, Kubernetes.Wrap(err, "DeletePod")
+		}
+		return shardUpgrade{phase: redisv1alpha1.UpgradePhaseMaster, pod: masterPod.Name,
+			message: fmt.Sprintf("restarting master %s, it has no slave", masterPod.Name)}, nil
+	}
+
+	promoted := ""
+	for name := range slaves {
+		if promoted == "" || isBetterPromotion(name, promoted, preferred, offsets) {
+			promoted = name
+		}
+	}
+	if previous != nil && previous.Phase == redisv1alpha1.UpgradePhaseFailover && previous.Pod == promoted &&
+		previous.LastTransitionTime != nil && time.Since(previous.LastTransitionTime.Time) < failoverTimeout {
+		return shardUpgrade{phase: previous.Phase, pod: previous.Pod, message: previous.Message}, nil
+	}
+	if err := ctx.admin.FailoverSlave(slaves[promoted]); err != nil {
+		return shardUpgrade{}, Redis.Wrap(err, "FailoverSlave")
+	}
+	return shardUpgrade{phase: redisv1alpha1.UpgradePhaseFailover, pod: promoted,
+		message: fmt.Sprintf("promoting slave %s to replace master %s", promoted, masterPod.Name)}, nil
+}
+
+// isBetterPromotion returns true if the slave a is a better master than b: a slave on a node preferred for the
+// masters, then the slave with the highest replication offset, then the first by name.
+func isBetterPromotion(a, b string, preferred map[string]bool, offsets map[string]int64) bool {
+	if preferred[a] != preferred[b] {
+		return preferred[a]
+	}
+	if offsets[a] != offsets[b] {
+		return offsets[a] > offsets[b]
+	}
+	return a < b
+}
+
+// outdatedPods returns the pods that do not run the update revision of the StatefulSet, sorted by name.
+func outdatedPods(sts *appsv1.StatefulSet, pods []corev1.Pod) []*corev1.Pod {
+	var outdated []*corev1.Pod
+	for i := range pods {
+		if pods[i].Labels[appsv1.ControllerRevisionHashLabelKey] != sts.Status.UpdateRevision {
+			outdated = append(outdated, &pods[i])
+		}
+	}
+	sort.Slice(outdated, func(i, j int) bool {
+		return outdated[i].Name < outdated[j].Name
+	})
+	return outdated
+}
+
+// previousShardUpgrade returns the upgrade status if it is the one of the shard.
+func previousShardUpgrade(upgrade *redisv1alpha1.UpgradeStatus, shard int) *redisv1alpha1.UpgradeStatus {
+	if upgrade == nil || upgrade.Shard != shard {
+		return nil
+	}
+	return upgrade
+}
+
+// setUpgradeStatus sets the upgrade status of the shard, LastTransitionTime changes with the phase or the pod.
+func setUpgradeStatus(status *redisv1alpha1.DistributedRedisClusterStatus, shard int, upgraded int32, step shardUpgrade) {
+	old := status.Upgrade
+	upgrade := &redisv1alpha1.UpgradeStatus{
+		Shard:          shard,
+		Phase:          step.phase,
+		Pod:            step.pod,
+		UpgradedShards: upgraded,
+		Message:        step.message,
+	}
+	if old != nil && old.Shard == shard && old.Phase == step.phase && old.Pod == step.pod {
+		upgrade.LastTransitionTime = old.LastTransitionTime
+	} else {
+		now := metav1.Now()
+		upgrade.LastTransitionTime = &now
+	}
+	status.Upgrade = upgrade
+}
+
+func replicationInfo(admin redisutil.IAdmin, node *redisutil.Node) (*redisutil.ReplicationInfo, error) {
+	c, err := admin.Connections().Get(node.IPPort())
+	if err != nil {
+		return nil, err
+	}
+	return redisutil.GetReplicationInfo(c)
+}
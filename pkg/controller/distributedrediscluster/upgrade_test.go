@@ -0,0 +1,55 @@
+package distributedrediscluster
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOutdatedPods(t *testing.T) {
+	newPod := func(name, revision string) corev1.Pod {
+		return corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{appsv1.ControllerRevisionHashLabelKey: revision},
+		}}
+	}
+	sts := &appsv1.StatefulSet{Status: appsv1.StatefulSetStatus{UpdateRevision: "v2"}}
+	pods := []corev1.Pod{
+		newPod("drc-example-0-2", "v1"),
+		newPod("drc-example-0-0", "v2"),
+		newPod("drc-example-0-1", "v1"),
+	}
+
+	outdated := outdatedPods(sts, pods)
+	if len(outdated) != 2 {
+		t.Fatalf("outdatedPods() returned %d pods, want 2", len(outdated))
+	}
+	if outdated[0].Name != "drc-example-0-1" || outdated[1].Name != "drc-example-0-2" {
+		t.Errorf("outdatedPods() = [%s %s], want [drc-example-0-1 drc-example-0-2]", outdated[0].Name, outdated[1].Name)
+	}
+	if outdated[0] != &pods[2] {
+		t.Errorf("outdatedPods() must return pointers to the given pods")
+	}
+}
+
+func TestIsBetterPromotion(t *testing.T) {
+	offsets := map[string]int64{"pod-1": 100, "pod-2": 200, "pod-3": 200}
+	tests := []struct {
+		a, b      string
+		preferred map[string]bool
+		want      bool
+	}{
+		{"pod-2", "pod-1", nil, true},
+		{"pod-1", "pod-2", nil, false},
+		{"pod-2", "pod-3", nil, true},
+		{"pod-1", "pod-2", map[string]bool{"pod-1": true}, true},
+		{"pod-2", "pod-1", map[string]bool{"pod-1": true}, false},
+	}
+	for _, tt := range tests {
+		if got := isBetterPromotion(tt.a, tt.b, tt.preferred, offsets); got != tt.want {
+			t.Errorf("isBetterPromotion(%s, %s, %v) = %v, want %v", tt.a, tt.b, tt.preferred, got, tt.want)
+		}
+	}
+}
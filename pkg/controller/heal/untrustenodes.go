@@ -18,7 +18,7 @@ func (c *CheckAndHeal) FixUntrustedNodes(cluster *redisv1alpha1.DistributedRedis
 	for id, uNode := range untrustedNode {
 		c.Logger.Info("[FixUntrustedNodes] found untrust node", "node", uNode)
 		getByIPFunc := func(n *redisutil.Node) bool {
-			if n.IP == uNode.IP && n.ID != uNode.ID {
+			if n.SameHost(uNode) && n.ID != uNode.ID {
 				return true
 			}
 			return false
@@ -76,10 +76,10 @@ func checkIfPodNameExistAndIsReused(node *redisutil.Node, podlist []*corev1.Pod)
 	for _, currentPod := range podlist {
 		if currentPod.Name == node.PodName {
 			exist = true
-			if currentPod.Status.PodIP == node.IP {
+			if node.MatchPod(currentPod) {
 				// this check is use to see if the Pod name is not use by another RedisNode.
 				// for that we check the the Pod name from the Redis node is not used by another
-				// Redis node, by comparing the IP of the current Pod with the Pod from the cluster bom.
+				// Redis node, by comparing the IP, or the hostname, of the current Pod with the Pod from the cluster bom.
 				// if the Pod  IP and Name from the redis info is equal to the IP/NAME from the getPod; it
 				// means that the Pod is still use and the Redis Node is not a ghost
 				reused = true
@@ -1,19 +1,28 @@
 package manager
 
 import (
+	"context"
+	"fmt"
+	"reflect"
 	"strconv"
 
 	"github.com/go-logr/logr"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
 	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
 	"github.com/ucloud/redis-cluster-operator/pkg/osm"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisconfig"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/certificates"
 	"github.com/ucloud/redis-cluster-operator/pkg/resources/configmaps"
 	"github.com/ucloud/redis-cluster-operator/pkg/resources/poddisruptionbudgets"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/secrets"
 	"github.com/ucloud/redis-cluster-operator/pkg/resources/services"
 	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
 )
@@ -24,6 +33,10 @@ type IEnsureResource interface {
 	EnsureRedisSvc(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error
 	EnsureRedisConfigMap(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error
 	EnsureRedisOSMSecret(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error
+	EnsureRedisCertificate(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error
+	EnsureRedisOperatorSecret(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error
+	EnsureRedisPasswordSecret(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error
+	EnsureRedisExternalSvcs(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error
 }
 
 type realEnsureResource struct {
@@ -55,7 +68,7 @@ func (r *realEnsureResource) EnsureRedisStatefulsets(cluster *redisv1alpha1.Dist
 		svcName := statefulsets.ClusterHeadlessSvcName(cluster.Spec.ServiceName, i)
 		// assign label
 		labels[redisv1alpha1.StatefulSetLabel] = name
-		if stsUpdated, err := r.ensureRedisStatefulset(cluster, name, svcName, labels); err != nil {
+		if stsUpdated, err := r.ensureRedisStatefulset(cluster, i, name, svcName, labels); err != nil {
 			return false, err
 		} else if stsUpdated {
 			updated = stsUpdated
@@ -64,7 +77,7 @@ func (r *realEnsureResource) EnsureRedisStatefulsets(cluster *redisv1alpha1.Dist
 	return updated, nil
 }
 
-func (r *realEnsureResource) ensureRedisStatefulset(cluster *redisv1alpha1.DistributedRedisCluster, ssName, svcName string,
+func (r *realEnsureResource) ensureRedisStatefulset(cluster *redisv1alpha1.DistributedRedisCluster, shard int, ssName, svcName string,
 	labels map[string]string) (bool, error) {
 	if err := r.ensureRedisPDB(cluster, ssName, labels); err != nil {
 		return false, err
@@ -72,19 +85,23 @@ func (r *realEnsureResource) ensureRedisStatefulset(cluster *redisv1alpha1.Distr
 
 	ss, err := r.statefulSetClient.GetStatefulSet(cluster.Namespace, ssName)
 	if err == nil {
-		if shouldUpdateRedis(cluster, ss) {
+		// the StatefulSets keep their last known-good template until the rolled back spec changes.
+		if !cluster.IsRolledBack() && shouldUpdateRedis(cluster, shard, ss) {
 			r.logger.WithValues("StatefulSet.Namespace", cluster.Namespace, "StatefulSet.Name", ssName).
 				Info("updating statefulSet")
-			newSS, err := statefulsets.NewStatefulSetForCR(cluster, ssName, svcName, labels)
+			newSS, err := statefulsets.NewStatefulSetForCR(cluster, shard, ssName, svcName, labels)
 			if err != nil {
 				return false, err
 			}
+			if template, ok := ss.Annotations[redisv1alpha1.AnnotationKnownGoodTemplate]; ok {
+				newSS.Annotations = map[string]string{redisv1alpha1.AnnotationKnownGoodTemplate: template}
+			}
 			return true, r.statefulSetClient.UpdateStatefulSet(newSS)
 		}
 	} else if err != nil && errors.IsNotFound(err) {
 		r.logger.WithValues("StatefulSet.Namespace", cluster.Namespace, "StatefulSet.Name", ssName).
 			Info("creating a new statefulSet")
-		newSS, err := statefulsets.NewStatefulSetForCR(cluster, ssName, svcName, labels)
+		newSS, err := statefulsets.NewStatefulSetForCR(cluster, shard, ssName, svcName, labels)
 		if err != nil {
 			return false, err
 		}
@@ -93,26 +110,52 @@ func (r *realEnsureResource) ensureRedisStatefulset(cluster *redisv1alpha1.Distr
 	return false, err
 }
 
-func shouldUpdateRedis(cluster *redisv1alpha1.DistributedRedisCluster, sts *appsv1.StatefulSet) bool {
+func shouldUpdateRedis(cluster *redisv1alpha1.DistributedRedisCluster, shard int, sts *appsv1.StatefulSet) bool {
 	if (cluster.Spec.ClusterReplicas + 1) != *sts.Spec.Replicas {
 		return true
 	}
-	if cluster.Spec.Image != sts.Spec.Template.Spec.Containers[0].Image {
+	if sts.Spec.UpdateStrategy.Type != appsv1.OnDeleteStatefulSetStrategyType {
+		return true
+	}
+	if cluster.ShardImage(shard) != statefulsets.RedisContainer(sts).Image {
+		return true
+	}
+	if sts.Spec.Template.Annotations[redisv1alpha1.AnnotationConfigHash] != redisconfig.RestartHash(cluster.RedisConfig()) {
+		return true
+	}
+	if sts.Spec.Template.Annotations[redisv1alpha1.AnnotationPodTemplateHash] != statefulsets.PodTemplateHash(cluster) {
+		return true
+	}
+	if shouldUpdateAOFShipper(cluster, sts) {
+		return true
+	}
+	// enabling or disabling TLS is rejected by the webhook, only the Secret may change.
+	if cluster.Spec.TLS != nil && statefulsets.TLSSecretName(sts) != cluster.TLSSecretName() {
 		return true
 	}
 	if cluster.Spec.PasswordSecret != nil {
-		envSet := sts.Spec.Template.Spec.Containers[0].Env
+		envSet := statefulsets.RedisContainer(sts).Env
 		secretName := getSecretKeyRefByKey(redisv1alpha1.PasswordENV, envSet)
 		if secretName == "" {
 			return true
 		}
-		if secretName != cluster.Spec.PasswordSecret.Name {
+		// the StatefulSets created before the password rotation reference the PasswordSecret, they keep
+		// it until the PasswordSecret changes.
+		if secretName != cluster.CurrentPasswordSecretName() && secretName != cluster.Spec.PasswordSecret.Name {
 			return true
 		}
 	}
 
-	expectResource := cluster.Spec.Resources
-	currentResource := sts.Spec.Template.Spec.Containers[0].Resources
+	nodeSelector, tolerations, affinity := statefulsets.ShardScheduling(cluster, shard, sts.Spec.Template.Labels)
+	podSpec := sts.Spec.Template.Spec
+	if !apiequality.Semantic.DeepEqual(nodeSelector, podSpec.NodeSelector) ||
+		!apiequality.Semantic.DeepEqual(tolerations, podSpec.Tolerations) ||
+		!apiequality.Semantic.DeepEqual(affinity, podSpec.Affinity) {
+		return true
+	}
+
+	expectResource := cluster.ShardResources(shard)
+	currentResource := statefulsets.RedisContainer(sts).Resources
 	if result := expectResource.Requests.Memory().Cmp(*currentResource.Requests.Memory()); result != 0 {
 		return true
 	}
@@ -128,6 +171,21 @@ func shouldUpdateRedis(cluster *redisv1alpha1.DistributedRedisCluster, sts *apps
 	return false
 }
 
+// shouldUpdateAOFShipper returns true if the AOF shipping sidecar of sts does not match the continuous backup of the cluster.
+func shouldUpdateAOFShipper(cluster *redisv1alpha1.DistributedRedisCluster, sts *appsv1.StatefulSet) bool {
+	var shipper *corev1.Container
+	for i, c := range sts.Spec.Template.Spec.Containers {
+		if c.Name == statefulsets.AOFShipperName {
+			shipper = &sts.Spec.Template.Spec.Containers[i]
+		}
+	}
+	spec := cluster.Spec.ContinuousBackup
+	if spec == nil || shipper == nil {
+		return spec != nil || shipper != nil
+	}
+	return spec.Image != shipper.Image || !reflect.DeepEqual(statefulsets.AOFShipperArgs(cluster), shipper.Args)
+}
+
 func getSecretKeyRefByKey(key string, envSet []corev1.EnvVar) string {
 	for _, value := range envSet {
 		if key == value.Name {
@@ -187,9 +245,89 @@ func (r *realEnsureResource) EnsureRedisSvc(cluster *redisv1alpha1.DistributedRe
 	return err
 }
 
+// EnsureRedisExternalSvcs creates the Services exposing each redis pod of a cluster with ExternalAccess. The
+// Services of the pods removed by a scale down are deleted once the pods are gone, the other nodes reach them
+// through their Service until then.
+func (r *realEnsureResource) EnsureRedisExternalSvcs(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error {
+	if cluster.Spec.ExternalAccess == nil {
+		return nil
+	}
+	delete(labels, redisv1alpha1.StatefulSetLabel)
+	expected := map[string]bool{}
+	for i := 0; i < int(cluster.Spec.MasterSize); i++ {
+		ssName := statefulsets.ClusterStatefulSetName(cluster.Name, i)
+		for j := 0; j <= int(cluster.Spec.ClusterReplicas); j++ {
+			podName := fmt.Sprintf("%s-%d", ssName, j)
+			expected[services.ExternalSvcName(podName)] = true
+			if err := r.ensureRedisExternalSvc(cluster, podName, labels); err != nil {
+				return err
+			}
+		}
+	}
+
+	svcLabels := map[string]string{redisv1alpha1.LabelExternalAccess: "true"}
+	for key, value := range labels {
+		svcLabels[key] = value
+	}
+	svcs, err := r.svcClient.ListServiceByLabels(cluster.Namespace, svcLabels)
+	if err != nil {
+		return err
+	}
+	for i := range svcs.Items {
+		svc := &svcs.Items[i]
+		if expected[svc.Name] {
+			continue
+		}
+		podName := svc.Spec.Selector[appsv1.StatefulSetPodNameLabel]
+		err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: cluster.Namespace, Name: podName}, &corev1.Pod{})
+		if err == nil {
+			continue
+		}
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		r.logger.WithValues("Service.Namespace", svc.Namespace, "Service.Name", svc.Name).
+			Info("deleting the external service of a removed pod")
+		if err := r.svcClient.DeleteService(svc); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *realEnsureResource) ensureRedisExternalSvc(cluster *redisv1alpha1.DistributedRedisCluster, podName string, labels map[string]string) error {
+	svc := services.NewExternalSvcForCR(cluster, podName, labels)
+	oldSvc, err := r.svcClient.GetService(cluster.Namespace, svc.Name)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			r.logger.WithValues("Service.Namespace", svc.Namespace, "Service.Name", svc.Name).
+				Info("creating a new external service")
+			return r.svcClient.CreateService(svc)
+		}
+		return err
+	}
+	// the annotations of the spec are added, the others may be set by the cloud provider.
+	updated := false
+	for key, value := range svc.Annotations {
+		if oldSvc.Annotations[key] != value {
+			if oldSvc.Annotations == nil {
+				oldSvc.Annotations = map[string]string{}
+			}
+			oldSvc.Annotations[key] = value
+			updated = true
+		}
+	}
+	if !updated {
+		return nil
+	}
+	r.logger.WithValues("Service.Namespace", svc.Namespace, "Service.Name", svc.Name).
+		Info("updating external service annotations")
+	return r.svcClient.UpdateService(oldSvc)
+}
+
 func (r *realEnsureResource) EnsureRedisConfigMap(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error {
 	cmName := configmaps.RedisConfigMapName(cluster.Name)
-	_, err := r.configMapClient.GetConfigMap(cluster.Namespace, cmName)
+	oldCm, err := r.configMapClient.GetConfigMap(cluster.Namespace, cmName)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			r.logger.WithValues("ConfigMap.Namespace", cluster.Namespace, "ConfigMap.Name", cmName).
@@ -201,6 +339,12 @@ func (r *realEnsureResource) EnsureRedisConfigMap(cluster *redisv1alpha1.Distrib
 		} else {
 			return err
 		}
+	} else if cm := configmaps.NewConfigMapForCR(cluster, labels); !reflect.DeepEqual(oldCm.Data, cm.Data) {
+		r.logger.WithValues("ConfigMap.Namespace", cluster.Namespace, "ConfigMap.Name", cmName).
+			Info("updating configMap")
+		if err := r.configMapClient.UpdateConfigMap(cm); err != nil {
+			return err
+		}
 	}
 
 	if cluster.IsRestoreFromBackup() {
@@ -224,6 +368,16 @@ func (r *realEnsureResource) EnsureRedisConfigMap(cluster *redisv1alpha1.Distrib
 }
 
 func (r *realEnsureResource) EnsureRedisOSMSecret(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error {
+	if spec := cluster.Spec.ContinuousBackup; spec != nil {
+		secret, err := osm.NewCephSecret(r.client, cluster.ContinuousBackupSecretName(), cluster.Namespace, spec.Backend)
+		if err != nil {
+			return err
+		}
+		secret.OwnerReferences = redisv1alpha1.DefaultOwnerReferences(cluster)
+		if err := k8sutil.CreateSecret(r.client, secret, r.logger); err != nil {
+			return err
+		}
+	}
 	if !cluster.IsRestoreFromBackup() || cluster.IsRestored() {
 		return nil
 	}
@@ -237,3 +391,87 @@ func (r *realEnsureResource) EnsureRedisOSMSecret(cluster *redisv1alpha1.Distrib
 	}
 	return nil
 }
+
+// EnsureRedisCertificate creates or updates the cert-manager Certificate of a cluster whose TLS certificate
+// is issued by cert-manager. cert-manager renews the Secret, the redis nodes then reload it.
+func (r *realEnsureResource) EnsureRedisCertificate(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error {
+	if cluster.Spec.TLS == nil || cluster.Spec.TLS.IssuerRef == nil {
+		return nil
+	}
+	delete(labels, redisv1alpha1.StatefulSetLabel)
+	cert := certificates.NewCertificateForCR(cluster, labels)
+	oldCert := &unstructured.Unstructured{}
+	oldCert.SetGroupVersionKind(certificates.CertificateGVK)
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: cert.GetNamespace(), Name: cert.GetName()}, oldCert)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			r.logger.WithValues("Certificate.Namespace", cert.GetNamespace(), "Certificate.Name", cert.GetName()).
+				Info("creating a new certificate")
+			return r.client.Create(context.TODO(), cert)
+		}
+		return err
+	}
+	// only the fields set by the operator are compared, cert-manager may default the others.
+	oldSpec, _, _ := unstructured.NestedMap(oldCert.Object, "spec")
+	if oldSpec == nil {
+		oldSpec = map[string]interface{}{}
+	}
+	spec := cert.Object["spec"].(map[string]interface{})
+	updated := false
+	for key, value := range spec {
+		if !apiequality.Semantic.DeepEqual(oldSpec[key], value) {
+			oldSpec[key] = value
+			updated = true
+		}
+	}
+	if !updated {
+		return nil
+	}
+	r.logger.WithValues("Certificate.Namespace", cert.GetNamespace(), "Certificate.Name", cert.GetName()).
+		Info("updating certificate")
+	oldCert.Object["spec"] = oldSpec
+	return r.client.Update(context.TODO(), oldCert)
+}
+
+// EnsureRedisOperatorSecret creates the Secret of the password of the ACL user of the operator for a cluster
+// with ACL users. The password is generated once and kept for the life of the cluster.
+func (r *realEnsureResource) EnsureRedisOperatorSecret(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error {
+	if len(cluster.Spec.Users) == 0 {
+		return nil
+	}
+	delete(labels, redisv1alpha1.StatefulSetLabel)
+	secret, err := secrets.NewOperatorSecretForCR(cluster, labels)
+	if err != nil {
+		return err
+	}
+	return k8sutil.CreateSecret(r.client, secret, r.logger)
+}
+
+// EnsureRedisPasswordSecret creates the Secret of the password the redis nodes accept, the pods read their
+// password from it. It is a copy of the PasswordSecret, or of the Secret the StatefulSets created before the
+// password rotation reference, the operator updates it when it rotates the password on the nodes.
+func (r *realEnsureResource) EnsureRedisPasswordSecret(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) error {
+	if cluster.Spec.PasswordSecret == nil {
+		return nil
+	}
+	_, err := k8sutil.GetSecretPassword(r.client, cluster.Namespace, cluster.CurrentPasswordSecretName())
+	if err == nil || !errors.IsNotFound(err) {
+		return err
+	}
+	source := cluster.Spec.PasswordSecret.Name
+	sts, err := r.statefulSetClient.GetStatefulSet(cluster.Namespace, statefulsets.ClusterStatefulSetName(cluster.Name, 0))
+	if err == nil {
+		if secretName := getSecretKeyRefByKey(redisv1alpha1.PasswordENV, statefulsets.RedisContainer(sts).Env); secretName != "" {
+			source = secretName
+		}
+	} else if !errors.IsNotFound(err) {
+		return err
+	}
+	password, err := k8sutil.GetSecretPassword(r.client, cluster.Namespace, source)
+	if err != nil {
+		return err
+	}
+	delete(labels, redisv1alpha1.StatefulSetLabel)
+	secret := secrets.NewCurrentPasswordSecretForCR(cluster, labels, password, "")
+	return k8sutil.CreateSecret(r.client, secret, r.logger)
+}
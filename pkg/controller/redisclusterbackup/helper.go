@@ -2,13 +2,25 @@ package redisclusterbackup
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/config"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/utils"
 )
 
 func (r *ReconcileRedisClusterBackup) markAsFailedBackup(backup *redisv1alpha1.RedisClusterBackup,
@@ -93,6 +105,28 @@ func redisPassword(cluster *redisv1alpha1.DistributedRedisCluster) corev1.EnvVar
 	}
 }
 
+func newBackupNode(node redisv1alpha1.RedisClusterNode, masterID string) redisv1alpha1.BackupNode {
+	return redisv1alpha1.BackupNode{
+		MasterID: masterID,
+		ID:       node.ID,
+		IP:       node.IP,
+		Port:     node.Port,
+		Role:     node.Role,
+	}
+}
+
+// replicationInfo returns the replication infos of a node of the cluster.
+func replicationInfo(node redisv1alpha1.RedisClusterNode, password string, tlsConfig *tls.Config) (*redisutil.ReplicationInfo, error) {
+	cfg := config.RedisConf()
+	c, err := redisutil.NewTLSClient(net.JoinHostPort(node.IP, node.Port), password,
+		time.Duration(cfg.DialTimeout)*time.Millisecond, utils.BuildCommandReplaceMapping(cfg.GetRenameCommandsFile(), log), tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	return redisutil.GetReplicationInfo(c)
+}
+
 func newDirectClient(config *rest.Config) client.Client {
 	c, err := client.New(config, client.Options{})
 	if err != nil {
@@ -100,3 +134,132 @@ func newDirectClient(config *rest.Config) client.Client {
 	}
 	return c
 }
+
+// jobPods returns the pods of the job, the newest first.
+func (r *ReconcileRedisClusterBackup) jobPods(job *batchv1.Job) ([]corev1.Pod, error) {
+	pods := &corev1.PodList{}
+	if err := r.client.List(context.TODO(), pods, client.InNamespace(job.Namespace),
+		client.MatchingLabels{"job-name": job.Name}); err != nil {
+		return nil, err
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[j].CreationTimestamp.Before(&pods.Items[i].CreationTimestamp)
+	})
+	return pods.Items, nil
+}
+
+// backupManifests reads the manifests written by the backup containers of the succeeded job pod
+// into their termination message.
+func (r *ReconcileRedisClusterBackup) backupManifests(job *batchv1.Job) ([]redisv1alpha1.BackupManifest, error) {
+	pods, err := r.jobPods(job)
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodSucceeded {
+			continue
+		}
+		return parseBackupManifests(pod.Status.ContainerStatuses)
+	}
+	return nil, fmt.Errorf("no succeeded pod found for job %s", job.Name)
+}
+
+// backupContainerIndex returns the index of the shard backed up by the container.
+func backupContainerIndex(name string) (int, bool) {
+	if !strings.HasPrefix(name, redisv1alpha1.JobTypeBackup+"-") {
+		return 0, false
+	}
+	index, err := strconv.Atoi(strings.TrimPrefix(name, redisv1alpha1.JobTypeBackup+"-"))
+	if err != nil {
+		return 0, false
+	}
+	return index, true
+}
+
+// parseBackupManifests returns the manifests of the backup containers, ordered by master index.
+func parseBackupManifests(statuses []corev1.ContainerStatus) ([]redisv1alpha1.BackupManifest, error) {
+	indexes := map[string]int{}
+	manifests := []redisv1alpha1.BackupManifest{}
+	for _, status := range statuses {
+		index, ok := backupContainerIndex(status.Name)
+		if !ok || status.State.Terminated == nil {
+			continue
+		}
+		manifest := redisv1alpha1.BackupManifest{}
+		if err := json.Unmarshal([]byte(status.State.Terminated.Message), &manifest); err != nil {
+			return nil, fmt.Errorf("invalid manifest of container %s: %v", status.Name, err)
+		}
+		if manifest.SHA256 == "" {
+			return nil, fmt.Errorf("manifest of container %s has no checksum", status.Name)
+		}
+		indexes[manifest.Snapshot] = index
+		manifests = append(manifests, manifest)
+	}
+	sort.Slice(manifests, func(i, j int) bool {
+		return indexes[manifests[i].Snapshot] < indexes[manifests[j].Snapshot]
+	})
+	return manifests, nil
+}
+
+// newShardStatuses returns a pending status for the backup of each shard.
+func newShardStatuses(nodes []redisv1alpha1.BackupNode, cluster *redisv1alpha1.DistributedRedisCluster) []redisv1alpha1.BackupShardStatus {
+	pods := map[string]string{}
+	for _, node := range cluster.Status.Nodes {
+		pods[node.ID] = node.PodName
+	}
+	shards := make([]redisv1alpha1.BackupShardStatus, len(nodes))
+	for i, node := range nodes {
+		shards[i] = redisv1alpha1.BackupShardStatus{
+			Index:     i,
+			MasterPod: pods[node.MasterID],
+			Pod:       pods[node.ID],
+			NodeID:    node.ID,
+			Phase:     redisv1alpha1.BackupShardPhasePending,
+		}
+	}
+	return shards
+}
+
+// updateShardStatus updates the status of the backup of a shard from the state of its container,
+// it returns true if the phase of the shard changed.
+func updateShardStatus(shard *redisv1alpha1.BackupShardStatus, status corev1.ContainerStatus) bool {
+	phase := shard.Phase
+	switch {
+	case status.State.Running != nil:
+		shard.Phase = redisv1alpha1.BackupShardPhaseRunning
+		shard.StartTime = status.State.Running.StartedAt.DeepCopy()
+		shard.FinishTime = nil
+		shard.BytesUploaded = 0
+		shard.ExitCode, shard.Reason, shard.Message = 0, "", ""
+	case status.State.Terminated != nil:
+		terminated := status.State.Terminated
+		shard.StartTime = terminated.StartedAt.DeepCopy()
+		shard.FinishTime = terminated.FinishedAt.DeepCopy()
+		shard.ExitCode, shard.Reason, shard.Message = terminated.ExitCode, terminated.Reason, ""
+		if terminated.ExitCode == 0 {
+			shard.Phase = redisv1alpha1.BackupShardPhaseSucceeded
+			manifest := redisv1alpha1.BackupManifest{}
+			// the manifest is missing if the backup is taken by an older redis-tools image.
+			if err := json.Unmarshal([]byte(terminated.Message), &manifest); err == nil {
+				shard.BytesUploaded = manifest.UploadedSize
+			}
+		} else {
+			shard.Phase = redisv1alpha1.BackupShardPhaseFailed
+			shard.Message = terminated.Message
+		}
+	default:
+		shard.Phase = redisv1alpha1.BackupShardPhasePending
+	}
+	return shard.Phase != phase
+}
+
+// failedShards returns the indexes of the shards whose backup failed.
+func failedShards(shards []redisv1alpha1.BackupShardStatus) []int {
+	failed := []int{}
+	for _, shard := range shards {
+		if shard.Phase == redisv1alpha1.BackupShardPhaseFailed {
+			failed = append(failed, shard.Index)
+		}
+	}
+	return failed
+}
@@ -0,0 +1,143 @@
+package redisclusterbackup
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+)
+
+func terminated(name, message string) corev1.ContainerStatus {
+	return corev1.ContainerStatus{
+		Name: name,
+		State: corev1.ContainerState{
+			Terminated: &corev1.ContainerStateTerminated{Message: message},
+		},
+	}
+}
+
+func TestParseBackupManifests(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []corev1.ContainerStatus
+		want     []redisv1alpha1.BackupManifest
+		wantErr  bool
+	}{
+		{
+			name: "ordered by master index",
+			statuses: []corev1.ContainerStatus{
+				terminated("backup-10", `{"snapshot":"b-10","nodeID":"c","slots":"10-11","size":3,"sha256":"cc","redisVersion":"5.0.4","compression":"zstd"}`),
+				terminated("backup-2", `{"snapshot":"b-2","nodeID":"b","slots":"5-9","size":2,"sha256":"bb","redisVersion":"5.0.4","encrypted":true}`),
+				terminated("backup-0", `{"snapshot":"b-0","nodeID":"a","slots":"0-4","size":1,"sha256":"aa","redisVersion":"5.0.4"}`),
+				{Name: "sidecar"},
+			},
+			want: []redisv1alpha1.BackupManifest{
+				{Snapshot: "b-0", NodeID: "a", Slots: "0-4", Size: 1, SHA256: "aa", RedisVersion: "5.0.4"},
+				{Snapshot: "b-2", NodeID: "b", Slots: "5-9", Size: 2, SHA256: "bb", RedisVersion: "5.0.4", Encrypted: true},
+				{Snapshot: "b-10", NodeID: "c", Slots: "10-11", Size: 3, SHA256: "cc", RedisVersion: "5.0.4", Compression: redisv1alpha1.BackupCompressionZstd},
+			},
+		},
+		{
+			name:     "no manifest",
+			statuses: []corev1.ContainerStatus{terminated("backup-0", "Backup successful")},
+			wantErr:  true,
+		},
+		{
+			name:     "no checksum",
+			statuses: []corev1.ContainerStatus{terminated("backup-0", `{"snapshot":"b-0","nodeID":"a"}`)},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBackupManifests(tt.statuses)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseBackupManifests() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseBackupManifests() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateShardStatus(t *testing.T) {
+	started := metav1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	finished := metav1.NewTime(started.Add(time.Minute))
+	tests := []struct {
+		name        string
+		shard       redisv1alpha1.BackupShardStatus
+		status      corev1.ContainerStatus
+		want        redisv1alpha1.BackupShardStatus
+		wantChanged bool
+	}{
+		{
+			name:   "waiting",
+			shard:  redisv1alpha1.BackupShardStatus{Phase: redisv1alpha1.BackupShardPhasePending},
+			status: corev1.ContainerStatus{State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{}}},
+			want:   redisv1alpha1.BackupShardStatus{Phase: redisv1alpha1.BackupShardPhasePending},
+		},
+		{
+			name:   "running",
+			shard:  redisv1alpha1.BackupShardStatus{Phase: redisv1alpha1.BackupShardPhasePending},
+			status: corev1.ContainerStatus{State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{StartedAt: started}}},
+			want: redisv1alpha1.BackupShardStatus{
+				Phase:     redisv1alpha1.BackupShardPhaseRunning,
+				StartTime: &started,
+			},
+			wantChanged: true,
+		},
+		{
+			name:  "succeeded",
+			shard: redisv1alpha1.BackupShardStatus{Phase: redisv1alpha1.BackupShardPhaseRunning, StartTime: &started},
+			status: corev1.ContainerStatus{State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				StartedAt:  started,
+				FinishedAt: finished,
+				Reason:     "Completed",
+				Message:    `{"snapshot":"b-0","nodeID":"a","size":10,"sha256":"aa","uploadedSize":4}`,
+			}}},
+			want: redisv1alpha1.BackupShardStatus{
+				Phase:         redisv1alpha1.BackupShardPhaseSucceeded,
+				BytesUploaded: 4,
+				StartTime:     &started,
+				FinishTime:    &finished,
+				Reason:        "Completed",
+			},
+			wantChanged: true,
+		},
+		{
+			name:  "failed",
+			shard: redisv1alpha1.BackupShardStatus{Phase: redisv1alpha1.BackupShardPhaseRunning, StartTime: &started},
+			status: corev1.ContainerStatus{State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+				StartedAt:  started,
+				FinishedAt: finished,
+				ExitCode:   1,
+				Reason:     "Error",
+				Message:    "unknown compression bz",
+			}}},
+			want: redisv1alpha1.BackupShardStatus{
+				Phase:      redisv1alpha1.BackupShardPhaseFailed,
+				StartTime:  &started,
+				FinishTime: &finished,
+				ExitCode:   1,
+				Reason:     "Error",
+				Message:    "unknown compression bz",
+			},
+			wantChanged: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := updateShardStatus(&tt.shard, tt.status); got != tt.wantChanged {
+				t.Errorf("updateShardStatus() = %v, want %v", got, tt.wantChanged)
+			}
+			if !reflect.DeepEqual(tt.shard, tt.want) {
+				t.Errorf("updateShardStatus() shard = %+v, want %+v", tt.shard, tt.want)
+			}
+		})
+	}
+}
@@ -2,12 +2,16 @@ package redisclusterbackup
 
 import (
 	"context"
+	"reflect"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/spf13/pflag"
 	batch "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -32,7 +36,11 @@ var (
 	maxConcurrentReconciles int
 )
 
-const backupFinalizer = "finalizer.backup.redis.kun"
+const (
+	backupFinalizer = "finalizer.backup.redis.kun"
+	// inUseRequeueAfter is the time to wait before deleting again a backup that was still in use.
+	inUseRequeueAfter = 30 * time.Second
+)
 
 func init() {
 	controllerFlagSet = pflag.NewFlagSet("controller", pflag.ExitOnError)
@@ -79,6 +87,9 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 				return false
 			}
 			log.WithValues("namespace", e.MetaNew.GetNamespace(), "name", e.MetaNew.GetName()).V(5).Info("Call UpdateFunc")
+			if e.MetaNew.GetDeletionTimestamp() != nil {
+				return true
+			}
 			// Ignore updates to CR status in which case metadata.Generation does not change
 			if e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration() {
 				log.WithValues("namespace", e.MetaNew.GetNamespace(), "name", e.MetaNew.GetName()).Info("Generation change return true",
@@ -163,6 +174,39 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
+	podPred := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if !utils.ShoudManage(e.MetaNew) || e.MetaNew.GetLabels()[redisv1alpha1.LabelBackupName] == "" {
+				return false
+			}
+			oldObj := e.ObjectOld.(*corev1.Pod)
+			newObj := e.ObjectNew.(*corev1.Pod)
+			return !reflect.DeepEqual(oldObj.Status.ContainerStatuses, newObj.Status.ContainerStatuses)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return false
+		},
+		CreateFunc: func(e event.CreateEvent) bool {
+			return false
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return false
+		},
+	}
+
+	// Watch for changes to the containers of the backup job pods to report the progress of each shard
+	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(o handler.MapObject) []reconcile.Request {
+			return []reconcile.Request{{NamespacedName: types.NamespacedName{
+				Namespace: o.Meta.GetNamespace(),
+				Name:      o.Meta.GetLabels()[redisv1alpha1.LabelBackupName],
+			}}}
+		}),
+	}, podPred)
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -207,35 +251,39 @@ func (r *ReconcileRedisClusterBackup) Reconcile(request reconcile.Request) (reco
 		return reconcile.Result{}, err
 	}
 
-	//// Check if the RedisClusterBackup instance is marked to be deleted, which is
-	//// indicated by the deletion timestamp being set.
-	//isBackupMarkedToBeDeleted := instance.GetDeletionTimestamp() != nil
-	//if isBackupMarkedToBeDeleted {
-	//	if contains(instance.GetFinalizers(), backupFinalizer) {
-	//		// Run finalization logic for backupFinalizer. If the
-	//		// finalization logic fails, don't remove the finalizer so
-	//		// that we can retry during the next reconciliation.
-	//		if err := r.finalizeBackup(reqLogger, instance); err != nil {
-	//			return reconcile.Result{}, err
-	//		}
-	//
-	//		// Remove backupFinalizer. Once all finalizers have been
-	//		// removed, the object will be deleted.
-	//		instance.SetFinalizers(remove(instance.GetFinalizers(), backupFinalizer))
-	//		err := r.client.Update(context.TODO(), instance)
-	//		if err != nil {
-	//			return reconcile.Result{}, err
-	//		}
-	//	}
-	//	return reconcile.Result{}, nil
-	//}
-
-	//// Add finalizer for this CR
-	//if !contains(instance.GetFinalizers(), backupFinalizer) {
-	//	if err := r.addFinalizer(reqLogger, instance); err != nil {
-	//		return reconcile.Result{}, err
-	//	}
-	//}
+	// Check if the RedisClusterBackup instance is marked to be deleted, which is
+	// indicated by the deletion timestamp being set.
+	isBackupMarkedToBeDeleted := instance.GetDeletionTimestamp() != nil
+	if isBackupMarkedToBeDeleted {
+		if contains(instance.GetFinalizers(), backupFinalizer) {
+			// Run finalization logic for backupFinalizer. If the
+			// finalization logic fails, don't remove the finalizer so
+			// that we can retry during the next reconciliation.
+			finalized, err := r.finalizeBackup(reqLogger, instance)
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+			if !finalized {
+				return reconcile.Result{RequeueAfter: inUseRequeueAfter}, nil
+			}
+
+			// Remove backupFinalizer. Once all finalizers have been
+			// removed, the object will be deleted.
+			instance.SetFinalizers(remove(instance.GetFinalizers(), backupFinalizer))
+			err = r.client.Update(context.TODO(), instance)
+			if err != nil {
+				return reconcile.Result{}, err
+			}
+		}
+		return reconcile.Result{}, nil
+	}
+
+	// Add finalizer for this CR
+	if !contains(instance.GetFinalizers(), backupFinalizer) {
+		if err := r.addFinalizer(reqLogger, instance); err != nil {
+			return reconcile.Result{}, err
+		}
+	}
 
 	if err := r.create(reqLogger, instance); err != nil {
 		return reconcile.Result{}, err
@@ -244,15 +292,6 @@ func (r *ReconcileRedisClusterBackup) Reconcile(request reconcile.Request) (reco
 	return reconcile.Result{}, nil
 }
 
-func (r *ReconcileRedisClusterBackup) finalizeBackup(reqLogger logr.Logger, b *redisv1alpha1.RedisClusterBackup) error {
-	// TODO(user): Add the cleanup steps that the operator
-	// needs to do before the CR can be deleted. Examples
-	// of finalizers include performing backups and deleting
-	// resources that are not owned by this CR, like a PVC.
-	reqLogger.Info("Successfully finalized RedisClusterBackup")
-	return nil
-}
-
 func (r *ReconcileRedisClusterBackup) addFinalizer(reqLogger logr.Logger, b *redisv1alpha1.RedisClusterBackup) error {
 	reqLogger.Info("Adding Finalizer for the backup")
 	b.SetFinalizers(append(b.GetFinalizers(), backupFinalizer))
@@ -2,7 +2,9 @@ package redisclusterbackup
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"reflect"
 
 	"github.com/go-logr/logr"
 	batchv1 "k8s.io/api/batch/v1"
@@ -15,6 +17,7 @@ import (
 	"github.com/ucloud/redis-cluster-operator/pkg/event"
 	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
 	"github.com/ucloud/redis-cluster-operator/pkg/osm"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
 	"github.com/ucloud/redis-cluster-operator/pkg/utils"
 )
 
@@ -139,6 +142,21 @@ func (r *ReconcileRedisClusterBackup) create(reqLogger logr.Logger, backup *redi
 		}
 	}
 
+	// the location is recorded so that the backup can be restored straight from the bucket.
+	location, err := backup.Location()
+	if err != nil {
+		r.recorder.Event(
+			backup,
+			corev1.EventTypeWarning,
+			event.BackupFailed,
+			err.Error(),
+		)
+		return r.markAsFailedBackup(backup, err.Error())
+	}
+	backup.Status.Location = location
+	backup.Status.Nodes = r.backupNodes(reqLogger, backup, cluster)
+	backup.Status.Compression = backup.Compression()
+	backup.Status.Shards = newShardStatuses(backup.Status.Nodes, cluster)
 	job, err := r.getBackupJob(reqLogger, backup, cluster)
 	if err != nil {
 		message := fmt.Sprintf("Failed to create Backup Job. Reason: %v", err)
@@ -251,6 +269,13 @@ func (r *ReconcileRedisClusterBackup) getBackupJob(reqLogger logr.Logger, backup
 		},
 		Spec: batchv1.JobSpec{
 			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						redisv1alpha1.LabelClusterName:  backup.Spec.RedisClusterName,
+						redisv1alpha1.AnnotationJobType: redisv1alpha1.JobTypeBackup,
+						redisv1alpha1.LabelBackupName:   backup.Name,
+					},
+				},
 				Spec: corev1.PodSpec{
 					Containers: containers,
 					Volumes: []corev1.Volume{
@@ -289,11 +314,15 @@ func (r *ReconcileRedisClusterBackup) getBackupJob(reqLogger logr.Logger, backup
 			VolumeSource: backup.Spec.Backend.Local.VolumeSource,
 		})
 	}
+	if cluster.Spec.TLS != nil {
+		job.Spec.Template.Spec.Volumes = append(job.Spec.Template.Spec.Volumes, statefulsets.TLSVolume(cluster))
+	}
 	if utils.IsClusterScoped() {
 		if job.Annotations == nil {
 			job.Annotations = make(map[string]string)
 		}
 		job.Annotations[utils.AnnotationScope] = utils.AnnotationClusterScoped
+		job.Spec.Template.Annotations = map[string]string{utils.AnnotationScope: utils.AnnotationClusterScoped}
 	}
 
 	return job, nil
@@ -305,73 +334,150 @@ func (r *ReconcileRedisClusterBackup) backupContainers(backup *redisv1alpha1.Red
 	if err != nil {
 		return nil, err
 	}
-	masterNum := int(cluster.Spec.MasterSize)
-	containers := make([]corev1.Container, masterNum)
-	i := 0
-	for _, node := range cluster.Status.Nodes {
-		if node.Role == redisv1alpha1.RedisClusterNodeRoleMaster {
-			if i == masterNum {
-				break
-			}
-			folderName, err := backup.Location()
-			if err != nil {
-				r.recorder.Event(
-					backup,
-					corev1.EventTypeWarning,
-					event.BackupError,
-					err.Error(),
-				)
-				return nil, err
-			}
-			reqLogger.V(3).Info("backup", "folderName", folderName)
-			container := corev1.Container{
-				Name:            fmt.Sprintf("%s-%d", redisv1alpha1.JobTypeBackup, i),
-				Image:           backup.Spec.Image,
-				ImagePullPolicy: "Always",
-				Args: []string{
-					redisv1alpha1.JobTypeBackup,
-					fmt.Sprintf(`--data-dir=%s`, redisv1alpha1.BackupDumpDir),
-					fmt.Sprintf(`--bucket=%s`, bucket),
-					fmt.Sprintf(`--host=%s`, node.IP),
-					fmt.Sprintf(`--folder=%s`, folderName),
-					fmt.Sprintf(`--snapshot=%s-%d`, backup.Name, i),
-					"--",
+	containers := make([]corev1.Container, len(backup.Status.Nodes))
+	for i, node := range backup.Status.Nodes {
+		folderName, err := backup.Location()
+		if err != nil {
+			r.recorder.Event(
+				backup,
+				corev1.EventTypeWarning,
+				event.BackupError,
+				err.Error(),
+			)
+			return nil, err
+		}
+		reqLogger.V(3).Info("backup", "folderName", folderName, "node", node.ID, "role", node.Role)
+		args := []string{
+			redisv1alpha1.JobTypeBackup,
+			fmt.Sprintf(`--data-dir=%s`, redisv1alpha1.BackupDumpDir),
+			fmt.Sprintf(`--bucket=%s`, bucket),
+			fmt.Sprintf(`--host=%s`, node.IP),
+			fmt.Sprintf(`--folder=%s`, folderName),
+			fmt.Sprintf(`--snapshot=%s-%d`, backup.Name, i),
+			fmt.Sprintf(`--compression=%s`, backup.Status.Compression),
+		}
+		if node.Role == redisv1alpha1.RedisClusterNodeRoleSlave {
+			// the slave is dumped as its master.
+			args = append(args, fmt.Sprintf(`--master-id=%s`, node.MasterID))
+		}
+		container := corev1.Container{
+			Name:            fmt.Sprintf("%s-%d", redisv1alpha1.JobTypeBackup, i),
+			Image:           backup.Spec.Image,
+			ImagePullPolicy: "Always",
+			Args:            append(args, "--"),
+			VolumeMounts: []corev1.VolumeMount{
+				{
+					Name:      redisv1alpha1.UtilVolumeName,
+					MountPath: redisv1alpha1.BackupDumpDir,
 				},
-				VolumeMounts: []corev1.VolumeMount{
-					{
-						Name:      redisv1alpha1.UtilVolumeName,
-						MountPath: redisv1alpha1.BackupDumpDir,
-					},
-					{
-						Name:      "osmconfig",
-						ReadOnly:  true,
-						MountPath: osm.SecretMountPath,
-					},
+				{
+					Name:      "osmconfig",
+					ReadOnly:  true,
+					MountPath: osm.SecretMountPath,
 				},
-			}
-			if cluster.Spec.PasswordSecret != nil {
-				container.Env = append(container.Env, redisPassword(cluster))
-			}
-			if backup.Spec.Backend.Local != nil {
-				container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
-					Name:      "local",
-					MountPath: backup.Spec.Backend.Local.MountPath,
-					SubPath:   backup.Spec.Backend.Local.SubPath,
-				})
-			}
-			if backup.Spec.PodSpec != nil {
-				container.Resources = backup.Spec.PodSpec.Resources
-				container.LivenessProbe = backup.Spec.PodSpec.LivenessProbe
-				container.ReadinessProbe = backup.Spec.PodSpec.ReadinessProbe
-				container.Lifecycle = backup.Spec.PodSpec.Lifecycle
-			}
-			containers[i] = container
-			i++
+			},
+		}
+		if cluster.Spec.PasswordSecret != nil {
+			container.Env = append(container.Env, redisPassword(cluster))
+		}
+		if key := backup.EncryptionKeyEnv(); key != nil {
+			container.Env = append(container.Env, *key)
 		}
+		if cluster.Spec.TLS != nil {
+			container.Env = append(container.Env, statefulsets.RedisCLIArgs())
+			container.VolumeMounts = append(container.VolumeMounts, statefulsets.TLSVolumeMount())
+		}
+		if backup.Spec.Backend.Local != nil {
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      "local",
+				MountPath: backup.Spec.Backend.Local.MountPath,
+				SubPath:   backup.Spec.Backend.Local.SubPath,
+			})
+		}
+		if backup.Spec.PodSpec != nil {
+			container.Resources = backup.Spec.PodSpec.Resources
+			container.LivenessProbe = backup.Spec.PodSpec.LivenessProbe
+			container.ReadinessProbe = backup.Spec.PodSpec.ReadinessProbe
+			container.Lifecycle = backup.Spec.PodSpec.Lifecycle
+		}
+		containers[i] = container
 	}
 	return containers, nil
 }
 
+// backupNodes returns the node to dump for each shard of the cluster. If PreferSlave is set, the healthy
+// slave the most in sync with its master is preferred, the master is used if the shard has none.
+func (r *ReconcileRedisClusterBackup) backupNodes(reqLogger logr.Logger, backup *redisv1alpha1.RedisClusterBackup,
+	cluster *redisv1alpha1.DistributedRedisCluster) []redisv1alpha1.BackupNode {
+	preferSlave := backup.Spec.PreferSlave
+	password := ""
+	var tlsConfig *tls.Config
+	if preferSlave {
+		var err error
+		if password, err = k8sutil.GetClusterPassword(r.client, cluster); err != nil {
+			reqLogger.Error(err, "cannot get cluster password, backup from masters")
+			preferSlave = false
+		} else if tlsConfig, err = k8sutil.GetClusterTLSConfig(r.client, cluster); err != nil {
+			reqLogger.Error(err, "cannot get cluster tls config, backup from masters")
+			preferSlave = false
+		}
+	}
+
+	masterNum := int(cluster.Spec.MasterSize)
+	nodes := []redisv1alpha1.BackupNode{}
+	for _, master := range cluster.Status.Nodes {
+		if master.Role != redisv1alpha1.RedisClusterNodeRoleMaster {
+			continue
+		}
+		if len(nodes) == masterNum {
+			break
+		}
+		node := newBackupNode(master, master.ID)
+		if preferSlave {
+			if slave := inSyncSlave(reqLogger, backup, cluster, master, password, tlsConfig); slave != nil {
+				node = *slave
+			}
+		}
+		reqLogger.Info("backup node", "master", master.ID, "node", node.ID, "role", node.Role)
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// inSyncSlave returns the slave of master with its link up and the smallest replication lag,
+// it returns nil if no slave lags less than MaxSlaveLag.
+func inSyncSlave(reqLogger logr.Logger, backup *redisv1alpha1.RedisClusterBackup, cluster *redisv1alpha1.DistributedRedisCluster,
+	master redisv1alpha1.RedisClusterNode, password string, tlsConfig *tls.Config) *redisv1alpha1.BackupNode {
+	masterInfo, err := replicationInfo(master, password, tlsConfig)
+	if err != nil {
+		reqLogger.Error(err, "cannot get replication info", "node", master.ID)
+		return nil
+	}
+	var best *redisv1alpha1.BackupNode
+	var bestOffset int64
+	for _, slave := range cluster.Status.Nodes {
+		if slave.Role != redisv1alpha1.RedisClusterNodeRoleSlave || slave.MasterRef != master.ID {
+			continue
+		}
+		info, err := replicationInfo(slave, password, tlsConfig)
+		if err != nil {
+			reqLogger.Error(err, "cannot get replication info", "node", slave.ID)
+			continue
+		}
+		if !info.IsSlaveInSync(masterInfo.MasterReplOffset, backup.MaxSlaveLag()) {
+			reqLogger.Info("slave is not in sync", "node", slave.ID, "linkStatus", info.MasterLinkStatus,
+				"lag", masterInfo.MasterReplOffset-info.SlaveReplOffset)
+			continue
+		}
+		if best == nil || info.SlaveReplOffset > bestOffset {
+			node := newBackupNode(slave, master.ID)
+			best = &node
+			bestOffset = info.SlaveReplOffset
+		}
+	}
+	return best
+}
+
 // GetVolumeForBackup returns pvc or empty directory depending on StorageType.
 // In case of PVC, this function will create a PVC then returns the volume.
 func (r *ReconcileRedisClusterBackup) GetVolumeForBackup(backup *redisv1alpha1.RedisClusterBackup, jobName string) (*corev1.Volume, error) {
@@ -476,6 +582,15 @@ func (r *ReconcileRedisClusterBackup) handleBackupJob(reqLogger logr.Logger, bac
 		}
 		return err
 	}
+	if err := r.syncShardStatus(reqLogger, backup, job); err != nil {
+		r.recorder.Event(
+			backup,
+			corev1.EventTypeWarning,
+			event.BackupError,
+			err.Error(),
+		)
+		return err
+	}
 	if job.Status.Succeeded == 0 && job.Status.Failed < utils.Int32(job.Spec.BackoffLimit) {
 		return fmt.Errorf("wait for job Succeeded or Failed")
 	}
@@ -496,12 +611,39 @@ func (r *ReconcileRedisClusterBackup) handleBackupJob(reqLogger logr.Logger, bac
 				jobSucceeded := job.Status.Succeeded > 0
 				if jobSucceeded {
 					backup.Status.Phase = redisv1alpha1.BackupPhaseSucceeded
+					manifests, err := r.backupManifests(job)
+					if err != nil {
+						// backups taken by an older redis-tools image have no manifest.
+						reqLogger.Error(err, "failed to read backup manifests")
+						r.recorder.Event(
+							backup,
+							corev1.EventTypeWarning,
+							event.BackupError,
+							fmt.Sprintf("Failed to read backup manifests: %v", err),
+						)
+					}
+					backup.Status.Manifests = manifests
 				} else {
 					backup.Status.Phase = redisv1alpha1.BackupPhaseFailed
 					backup.Status.Reason = "run batch job failed"
+					if failed := failedShards(backup.Status.Shards); len(failed) > 0 {
+						backup.Status.Reason = fmt.Sprintf("run batch job failed, failed shards: %v", failed)
+					}
 				}
 				t := metav1.Now()
 				backup.Status.CompletionTime = &t
+				if jobSucceeded && backup.Spec.Local == nil {
+					if err := osm.WriteBackupMetadata(r.client, backup); err != nil {
+						// the backup can still be restored from the RedisClusterBackup.
+						reqLogger.Error(err, "failed to write backup metadata")
+						r.recorder.Event(
+							backup,
+							corev1.EventTypeWarning,
+							event.BackupError,
+							fmt.Sprintf("Failed to write %s: %v", osm.BackupMetadataFile, err),
+						)
+					}
+				}
 				if err := r.crController.UpdateCRStatus(backup); err != nil {
 					r.recorder.Event(
 						backup,
@@ -581,3 +723,148 @@ func (r *ReconcileRedisClusterBackup) handleBackupJob(reqLogger logr.Logger, bac
 
 	return nil
 }
+
+// syncShardStatus reports the progress of the backup of each shard from the containers of the newest pod
+// of the job, an event is emitted each time the backup of a shard starts, succeeds or fails.
+func (r *ReconcileRedisClusterBackup) syncShardStatus(reqLogger logr.Logger, backup *redisv1alpha1.RedisClusterBackup, job *batchv1.Job) error {
+	if len(backup.Status.Shards) == 0 {
+		// the backup was started by an older version of the operator.
+		return nil
+	}
+	pods, err := r.jobPods(job)
+	if err != nil || len(pods) == 0 {
+		return err
+	}
+	shards := make([]redisv1alpha1.BackupShardStatus, len(backup.Status.Shards))
+	for i := range backup.Status.Shards {
+		backup.Status.Shards[i].DeepCopyInto(&shards[i])
+	}
+	for _, status := range pods[0].Status.ContainerStatuses {
+		index, ok := backupContainerIndex(status.Name)
+		if !ok || index >= len(shards) {
+			continue
+		}
+		if updateShardStatus(&shards[index], status) {
+			r.shardEvent(reqLogger, backup, shards[index])
+		}
+	}
+	if reflect.DeepEqual(shards, backup.Status.Shards) {
+		return nil
+	}
+	backup.Status.Shards = shards
+	return r.crController.UpdateCRStatus(backup)
+}
+
+func (r *ReconcileRedisClusterBackup) shardEvent(reqLogger logr.Logger, backup *redisv1alpha1.RedisClusterBackup, shard redisv1alpha1.BackupShardStatus) {
+	reqLogger.Info("shard backup", "shard", shard.Index, "pod", shard.Pod, "phase", shard.Phase)
+	switch shard.Phase {
+	case redisv1alpha1.BackupShardPhaseRunning:
+		r.recorder.Event(
+			backup,
+			corev1.EventTypeNormal,
+			event.ShardStarted,
+			fmt.Sprintf("Backup of shard %d started from pod %s", shard.Index, shard.Pod),
+		)
+	case redisv1alpha1.BackupShardPhaseSucceeded:
+		r.recorder.Event(
+			backup,
+			corev1.EventTypeNormal,
+			event.ShardSucceeded,
+			fmt.Sprintf("Backup of shard %d succeeded, %d bytes uploaded", shard.Index, shard.BytesUploaded),
+		)
+	case redisv1alpha1.BackupShardPhaseFailed:
+		r.recorder.Event(
+			backup,
+			corev1.EventTypeWarning,
+			event.ShardFailed,
+			fmt.Sprintf("Backup of shard %d failed with exit code %d: %s %s", shard.Index, shard.ExitCode, shard.Reason, shard.Message),
+		)
+	}
+}
+
+// finalizeBackup removes the backup data from the object storage before the CR is deleted, it returns false
+// while the backup is still used by a cluster or a restore, the data is kept until the next try.
+func (r *ReconcileRedisClusterBackup) finalizeBackup(reqLogger logr.Logger, b *redisv1alpha1.RedisClusterBackup) (bool, error) {
+	if b.Status.StartTime == nil {
+		reqLogger.Info("Backup never started, nothing to clean up")
+		return true, nil
+	}
+	if b.Spec.Local != nil {
+		// the local volume is only mounted into the backup job.
+		reqLogger.Info("Skip removing backup data from local storage")
+		return true, nil
+	}
+	user, err := r.backupUser(b)
+	if err != nil {
+		return false, err
+	}
+	if user != "" {
+		reqLogger.Info("Backup is still in use, keep its data", "user", user)
+		r.recorder.Event(
+			b,
+			corev1.EventTypeWarning,
+			event.BackupInUse,
+			fmt.Sprintf("Backup data is kept while it is used by %s", user),
+		)
+		return false, nil
+	}
+	folder, err := b.Location()
+	if err != nil {
+		return false, err
+	}
+	if err := osm.DeleteFolder(r.client, b.Spec.Backend, b.Namespace, folder); err != nil {
+		if errors.IsNotFound(err) {
+			// the storage secret is gone, e.g. the namespace is being deleted,
+			// do not block the deletion of the CR forever.
+			reqLogger.Error(err, "Storage secret not found, skip removing backup data", "folder", folder)
+			return true, nil
+		}
+		r.recorder.Event(
+			b,
+			corev1.EventTypeWarning,
+			event.BackupError,
+			err.Error(),
+		)
+		return false, err
+	}
+	reqLogger.Info("Successfully finalized RedisClusterBackup", "folder", folder)
+	return true, nil
+}
+
+// backupUser returns the DistributedRedisCluster initialized from the backup, its pods pull the backup
+// again when they are recreated, or the RedisClusterRestore in progress restoring it, "" if none.
+func (r *ReconcileRedisClusterBackup) backupUser(b *redisv1alpha1.RedisClusterBackup) (string, error) {
+	clusters := &redisv1alpha1.DistributedRedisClusterList{}
+	if err := r.client.List(context.TODO(), clusters); err != nil {
+		return "", err
+	}
+	for _, cluster := range clusters.Items {
+		if cluster.IsRestoreFromBackup() && refersTo(cluster.Spec.Init.BackupSource, cluster.Namespace, b) {
+			return fmt.Sprintf("DistributedRedisCluster %s/%s", cluster.Namespace, cluster.Name), nil
+		}
+	}
+	restores := &redisv1alpha1.RedisClusterRestoreList{}
+	if err := r.client.List(context.TODO(), restores); err != nil {
+		return "", err
+	}
+	for _, restore := range restores.Items {
+		if restore.Status.Phase == redisv1alpha1.RestorePhaseSucceeded || restore.Status.Phase == redisv1alpha1.RestorePhaseFailed {
+			continue
+		}
+		if refersTo(&restore.Spec.BackupSource, restore.Namespace, b) {
+			return fmt.Sprintf("RedisClusterRestore %s/%s", restore.Namespace, restore.Name), nil
+		}
+	}
+	return "", nil
+}
+
+// refersTo returns true if source is the RedisClusterBackup b, its namespace defaults to the namespace of its object.
+func refersTo(source *redisv1alpha1.BackupSourceSpec, namespace string, b *redisv1alpha1.RedisClusterBackup) bool {
+	if source.Bucket != nil || source.Name != b.Name {
+		return false
+	}
+	if source.Namespace != "" {
+		namespace = source.Namespace
+	}
+	return namespace == b.Namespace
+}
@@ -0,0 +1,186 @@
+package redisclusterbackup
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+
+	logrtesting "github.com/go-logr/logr/testing"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	store "kmodules.xyz/objectstore-api/api/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
+)
+
+func TestBackupContainers_TLS(t *testing.T) {
+	now := metav1.Now()
+	cluster := &redisv1alpha1.DistributedRedisCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"},
+		Spec: redisv1alpha1.DistributedRedisClusterSpec{
+			Image: "redis:6.2",
+			TLS:   &redisv1alpha1.TLSSpec{SecretName: "redis-tls"},
+		},
+	}
+	backup := &redisv1alpha1.RedisClusterBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "default"},
+		Spec: redisv1alpha1.RedisClusterBackupSpec{
+			Image:            "redis-tools",
+			RedisClusterName: "cluster",
+			Backend: store.Backend{
+				S3: &store.S3Spec{Bucket: "bucket", Prefix: "prefix"},
+			},
+		},
+		Status: redisv1alpha1.RedisClusterBackupStatus{
+			StartTime: &now,
+			Nodes: []redisv1alpha1.BackupNode{
+				{ID: "a", IP: "10.0.0.1", Port: "6379", Role: redisv1alpha1.RedisClusterNodeRoleMaster},
+			},
+		},
+	}
+	r := &ReconcileRedisClusterBackup{}
+	containers, err := r.backupContainers(backup, cluster, logrtesting.NullLogger{})
+	if err != nil {
+		t.Fatalf("backupContainers() error = %v", err)
+	}
+	if len(containers) != 1 {
+		t.Fatalf("backupContainers() = %d containers, want 1", len(containers))
+	}
+	container := containers[0]
+	want := statefulsets.RedisCLIArgs()
+	found := false
+	for _, env := range container.Env {
+		found = found || env == want
+	}
+	if !found {
+		t.Errorf("backupContainers() env = %v, want %v", container.Env, want)
+	}
+	mounted := false
+	for _, mount := range container.VolumeMounts {
+		mounted = mounted || mount == statefulsets.TLSVolumeMount()
+	}
+	if !mounted {
+		t.Errorf("backupContainers() volume mounts = %v, want the TLS secret", container.VolumeMounts)
+	}
+}
+
+// TestRedisToolsTLS checks that redis-tools.sh, run by the backup jobs and the aof-shipper, passes the TLS
+// arguments of REDIS_CLI_ARGS to redis-cli, the nodes of a TLS cluster do not listen without TLS.
+func TestRedisToolsTLS(t *testing.T) {
+	f, err := os.Open("../../../hack/docker/redis-tools/redis-tools.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	calls := 0
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(text, "#") || !strings.Contains(text, "redis-cli ") {
+			continue
+		}
+		calls++
+		if !strings.Contains(text, "redis-cli ${REDIS_CLI_ARGS} ") {
+			t.Errorf("redis-tools.sh:%d calls redis-cli without ${REDIS_CLI_ARGS}: %s", line, text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if calls == 0 {
+		t.Errorf("redis-tools.sh does not call redis-cli")
+	}
+}
+
+// TestFinalizeBackup_InUse checks that the data of a backup is kept while a cluster or a restore uses it,
+// the storage secret is missing so that the data of an unused backup is dropped without an object storage.
+func TestFinalizeBackup_InUse(t *testing.T) {
+	const namespace = "default"
+	now := metav1.Now()
+	backup := &redisv1alpha1.RedisClusterBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: namespace},
+		Spec: redisv1alpha1.RedisClusterBackupSpec{
+			RedisClusterName: "cluster",
+			Backend: store.Backend{
+				StorageSecretName: "s3-secret",
+				S3:                &store.S3Spec{Bucket: "bucket", Prefix: "prefix"},
+			},
+		},
+		Status: redisv1alpha1.RedisClusterBackupStatus{StartTime: &now},
+	}
+	clusterFrom := func(namespace string, source redisv1alpha1.BackupSourceSpec) runtime.Object {
+		return &redisv1alpha1.DistributedRedisCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "restored", Namespace: namespace},
+			Spec: redisv1alpha1.DistributedRedisClusterSpec{
+				Init: &redisv1alpha1.InitSpec{BackupSource: &source},
+			},
+		}
+	}
+	restoreOf := func(source redisv1alpha1.BackupSourceSpec, phase redisv1alpha1.RestorePhase) runtime.Object {
+		return &redisv1alpha1.RedisClusterRestore{
+			ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: namespace},
+			Spec:       redisv1alpha1.RedisClusterRestoreSpec{RedisClusterName: "cluster", BackupSource: source},
+			Status:     redisv1alpha1.RedisClusterRestoreStatus{Phase: phase},
+		}
+	}
+	tests := []struct {
+		name          string
+		objs          []runtime.Object
+		wantFinalized bool
+	}{
+		{
+			name:          "unused",
+			wantFinalized: true,
+		},
+		{
+			name: "cluster initialized from the backup",
+			objs: []runtime.Object{clusterFrom(namespace, redisv1alpha1.BackupSourceSpec{Name: "backup", Namespace: namespace})},
+		},
+		{
+			name: "cluster initialized from the backup of its namespace",
+			objs: []runtime.Object{clusterFrom(namespace, redisv1alpha1.BackupSourceSpec{Name: "backup"})},
+		},
+		{
+			name:          "cluster initialized from another backup",
+			objs:          []runtime.Object{clusterFrom("other", redisv1alpha1.BackupSourceSpec{Name: "backup"})},
+			wantFinalized: true,
+		},
+		{
+			name: "restore in progress",
+			objs: []runtime.Object{restoreOf(redisv1alpha1.BackupSourceSpec{Name: "backup"}, redisv1alpha1.RestorePhaseRestoring)},
+		},
+		{
+			name:          "restore completed",
+			objs:          []runtime.Object{restoreOf(redisv1alpha1.BackupSourceSpec{Name: "backup"}, redisv1alpha1.RestorePhaseSucceeded)},
+			wantFinalized: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			if err := clientgoscheme.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			if err := redisv1alpha1.SchemeBuilder.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			r := &ReconcileRedisClusterBackup{
+				client:   fake.NewFakeClientWithScheme(scheme, tt.objs...),
+				scheme:   scheme,
+				recorder: record.NewFakeRecorder(10),
+			}
+			finalized, err := r.finalizeBackup(logrtesting.NullLogger{}, backup.DeepCopy())
+			if err != nil {
+				t.Fatalf("finalizeBackup() error = %v", err)
+			}
+			if finalized != tt.wantFinalized {
+				t.Errorf("finalizeBackup() = %v, want %v", finalized, tt.wantFinalized)
+			}
+		})
+	}
+}
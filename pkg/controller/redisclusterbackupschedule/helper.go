@@ -0,0 +1,51 @@
+package redisclusterbackupschedule
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+)
+
+func (r *ReconcileRedisClusterBackupSchedule) markAsScheduled(schedule *redisv1alpha1.RedisClusterBackupSchedule,
+	scheduledTime time.Time, lastBackup, reason string) error {
+	t := metav1.NewTime(scheduledTime)
+	schedule.Status.LastScheduleTime = &t
+	schedule.Status.LastBackup = lastBackup
+	schedule.Status.Reason = reason
+	return r.crController.UpdateCRStatus(schedule)
+}
+
+func (r *ReconcileRedisClusterBackupSchedule) markAsFailedSchedule(schedule *redisv1alpha1.RedisClusterBackupSchedule,
+	reason string) error {
+	schedule.Status.Reason = reason
+	return r.crController.UpdateCRStatus(schedule)
+}
+
+// isBackupRunning returns true if a backup of the scheduled cluster is Running,
+// or if a backup created by this schedule has not been started yet.
+func (r *ReconcileRedisClusterBackupSchedule) isBackupRunning(schedule *redisv1alpha1.RedisClusterBackupSchedule) (bool, error) {
+	backupList := &redisv1alpha1.RedisClusterBackupList{}
+	opts := []client.ListOption{
+		client.InNamespace(schedule.Namespace),
+		client.MatchingLabels{
+			redisv1alpha1.LabelClusterName: schedule.Spec.RedisClusterName,
+		},
+	}
+	if err := r.client.List(context.TODO(), backupList, opts...); err != nil {
+		return false, err
+	}
+
+	for _, backup := range backupList.Items {
+		if backup.Status.Phase == redisv1alpha1.BackupPhaseRunning {
+			return true, nil
+		}
+		if backup.Status.Phase == "" && backup.Labels[redisv1alpha1.LabelBackupSchedule] == schedule.Name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,137 @@
+package redisclusterbackupschedule
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/event"
+)
+
+// applyRetention deletes the completed backups of the schedule which are not kept by its retention policy.
+// The remote data is removed by the finalizer of the RedisClusterBackup.
+func (r *ReconcileRedisClusterBackupSchedule) applyRetention(reqLogger logr.Logger, schedule *redisv1alpha1.RedisClusterBackupSchedule) error {
+	policy := schedule.Spec.Retention
+	if policy == nil {
+		return nil
+	}
+
+	backupList := &redisv1alpha1.RedisClusterBackupList{}
+	opts := []client.ListOption{
+		client.InNamespace(schedule.Namespace),
+		client.MatchingLabels{
+			redisv1alpha1.LabelBackupSchedule: schedule.Name,
+		},
+	}
+	if err := r.client.List(context.TODO(), backupList, opts...); err != nil {
+		return err
+	}
+
+	for _, backup := range backupsToPrune(backupList.Items, policy, r.now()) {
+		if policy.DryRun {
+			msg := fmt.Sprintf("Backup %s would be deleted by the retention policy (dry run)", backup.Name)
+			reqLogger.Info(msg)
+			r.recorder.Event(
+				schedule,
+				corev1.EventTypeNormal,
+				event.BackupDeleted,
+				msg,
+			)
+			continue
+		}
+		if err := r.client.Delete(context.TODO(), backup); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		msg := fmt.Sprintf("Deleted backup %s by the retention policy", backup.Name)
+		reqLogger.Info(msg)
+		r.recorder.Event(
+			schedule,
+			corev1.EventTypeNormal,
+			event.BackupDeleted,
+			msg,
+		)
+	}
+	return nil
+}
+
+// backupsToPrune returns the completed backups which are not kept by policy, newest first.
+// Running and deleting backups are never returned.
+func backupsToPrune(backups []redisv1alpha1.RedisClusterBackup, policy *redisv1alpha1.BackupRetentionPolicy, now time.Time) []*redisv1alpha1.RedisClusterBackup {
+	var completed []*redisv1alpha1.RedisClusterBackup
+	for i := range backups {
+		backup := &backups[i]
+		if backup.DeletionTimestamp != nil {
+			continue
+		}
+		switch backup.Status.Phase {
+		case redisv1alpha1.BackupPhaseSucceeded, redisv1alpha1.BackupPhaseFailed, redisv1alpha1.BackupPhaseIgnored:
+			completed = append(completed, backup)
+		}
+	}
+	sort.SliceStable(completed, func(i, j int) bool {
+		return backupTime(completed[i]).After(backupTime(completed[j]))
+	})
+
+	var succeeded []*redisv1alpha1.RedisClusterBackup
+	for _, backup := range completed {
+		if backup.Status.Phase == redisv1alpha1.BackupPhaseSucceeded {
+			succeeded = append(succeeded, backup)
+		}
+	}
+
+	keep := make(map[string]bool)
+	for i := 0; i < len(succeeded) && i < int(policy.KeepLast); i++ {
+		keep[succeeded[i].Name] = true
+	}
+	keepPerPeriod := func(n int32, period func(t time.Time) string) {
+		lastPeriod := ""
+		for _, backup := range succeeded {
+			if n <= 0 {
+				return
+			}
+			if p := period(backupTime(backup)); p != lastPeriod {
+				keep[backup.Name] = true
+				lastPeriod = p
+				n--
+			}
+		}
+	}
+	keepPerPeriod(policy.KeepDaily, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepPerPeriod(policy.KeepWeekly, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-%d", year, week)
+	})
+	keepPerPeriod(policy.KeepMonthly, func(t time.Time) string {
+		return t.Format("2006-01")
+	})
+	hasKeepRule := policy.KeepLast > 0 || policy.KeepDaily > 0 || policy.KeepWeekly > 0 || policy.KeepMonthly > 0
+
+	var prune []*redisv1alpha1.RedisClusterBackup
+	for _, backup := range completed {
+		if policy.MaxAge != nil && now.Sub(backupTime(backup)) > policy.MaxAge.Duration {
+			prune = append(prune, backup)
+			continue
+		}
+		if hasKeepRule && backup.Status.Phase == redisv1alpha1.BackupPhaseSucceeded && !keep[backup.Name] {
+			prune = append(prune, backup)
+		}
+	}
+	return prune
+}
+
+// backupTime returns the time the backup was taken.
+func backupTime(backup *redisv1alpha1.RedisClusterBackup) time.Time {
+	if backup.Status.StartTime != nil {
+		return backup.Status.StartTime.Time
+	}
+	return backup.CreationTimestamp.Time
+}
@@ -0,0 +1,88 @@
+package redisclusterbackupschedule
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+)
+
+func newTestBackup(name string, phase redisv1alpha1.BackupPhase, start time.Time) redisv1alpha1.RedisClusterBackup {
+	t := metav1.NewTime(start)
+	return redisv1alpha1.RedisClusterBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: redisv1alpha1.RedisClusterBackupStatus{
+			StartTime: &t,
+			Phase:     phase,
+		},
+	}
+}
+
+func Test_backupsToPrune(t *testing.T) {
+	now := time.Date(2020, 3, 10, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	backups := func() []redisv1alpha1.RedisClusterBackup {
+		return []redisv1alpha1.RedisClusterBackup{
+			newTestBackup("running", redisv1alpha1.BackupPhaseRunning, now),
+			newTestBackup("today-2", redisv1alpha1.BackupPhaseSucceeded, now.Add(-1*time.Hour)),
+			newTestBackup("today-1", redisv1alpha1.BackupPhaseSucceeded, now.Add(-2*time.Hour)),
+			newTestBackup("failed", redisv1alpha1.BackupPhaseFailed, now.Add(-3*time.Hour)),
+			newTestBackup("yesterday", redisv1alpha1.BackupPhaseSucceeded, now.Add(-1*day)),
+			newTestBackup("last-week", redisv1alpha1.BackupPhaseSucceeded, now.Add(-7*day)),
+			newTestBackup("last-month", redisv1alpha1.BackupPhaseSucceeded, now.Add(-30*day)),
+		}
+	}
+	tests := []struct {
+		name   string
+		policy *redisv1alpha1.BackupRetentionPolicy
+		want   []string
+	}{
+		{
+			name:   "empty policy keeps everything",
+			policy: &redisv1alpha1.BackupRetentionPolicy{},
+			want:   nil,
+		},
+		{
+			name:   "keep last",
+			policy: &redisv1alpha1.BackupRetentionPolicy{KeepLast: 2},
+			want:   []string{"yesterday", "last-week", "last-month"},
+		},
+		{
+			name:   "keep daily",
+			policy: &redisv1alpha1.BackupRetentionPolicy{KeepDaily: 2},
+			want:   []string{"today-1", "last-week", "last-month"},
+		},
+		{
+			name:   "keep weekly and monthly",
+			policy: &redisv1alpha1.BackupRetentionPolicy{KeepWeekly: 2, KeepMonthly: 2},
+			want:   []string{"today-1", "yesterday"},
+		},
+		{
+			name:   "max age",
+			policy: &redisv1alpha1.BackupRetentionPolicy{MaxAge: &metav1.Duration{Duration: 2 * day}},
+			want:   []string{"last-week", "last-month"},
+		},
+		{
+			name: "max age removes kept backups",
+			policy: &redisv1alpha1.BackupRetentionPolicy{
+				KeepMonthly: 3,
+				MaxAge:      &metav1.Duration{Duration: 10 * day},
+			},
+			want: []string{"today-1", "yesterday", "last-week", "last-month"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, backup := range backupsToPrune(backups(), tt.policy, now) {
+				got = append(got, backup.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("backupsToPrune() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package redisclusterbackupschedule
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/robfig/cron"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/event"
+	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/utils"
+)
+
+func (r *ReconcileRedisClusterBackupSchedule) sync(reqLogger logr.Logger, schedule *redisv1alpha1.RedisClusterBackupSchedule) (reconcile.Result, error) {
+	if err := validateSchedule(schedule); err != nil {
+		r.markAsFailedSchedule(schedule, err.Error())
+		r.recorder.Event(
+			schedule,
+			corev1.EventTypeWarning,
+			event.ScheduleError,
+			err.Error(),
+		)
+		return reconcile.Result{}, nil // stop retry
+	}
+
+	// garbage collect old backups even if the schedule is suspended.
+	if err := r.applyRetention(reqLogger, schedule); err != nil {
+		r.recorder.Event(
+			schedule,
+			corev1.EventTypeWarning,
+			event.ScheduleError,
+			err.Error(),
+		)
+		return reconcile.Result{}, err
+	}
+
+	if schedule.Spec.Suspend {
+		reqLogger.Info("Backup schedule suspended")
+		return reconcile.Result{}, nil
+	}
+
+	sched, err := cron.ParseStandard(schedule.Spec.Schedule)
+	if err != nil {
+		return reconcile.Result{}, nil // already checked by validateSchedule
+	}
+
+	now := r.now()
+	requeue := reconcile.Result{RequeueAfter: sched.Next(now).Sub(now)}
+	scheduledTime, missed := getMostRecentScheduleTime(schedule, sched, now)
+	if scheduledTime == nil {
+		reqLogger.V(3).Info("no unmet start time", "requeueAfter", requeue.RequeueAfter)
+		return requeue, nil
+	}
+	if missed > tooManyMissedRuns {
+		r.recorder.Event(
+			schedule,
+			corev1.EventTypeWarning,
+			event.MissedBackups,
+			fmt.Sprintf("More than %d backups were missed, only the backup scheduled at %s is run",
+				tooManyMissedRuns, scheduledTime.Format(time.RFC3339)),
+		)
+	}
+
+	if _, err := r.crController.GetDistributedRedisCluster(schedule.Namespace, schedule.Spec.RedisClusterName); err != nil {
+		if k8sutil.IsRequestRetryable(err) {
+			return reconcile.Result{}, err
+		}
+		msg := fmt.Sprintf("Skipped backup scheduled at %s. Reason: %v", scheduledTime.Format(time.RFC3339), err)
+		r.recorder.Event(
+			schedule,
+			corev1.EventTypeWarning,
+			event.BackupSkipped,
+			msg,
+		)
+		return requeue, r.markAsScheduled(schedule, *scheduledTime, schedule.Status.LastBackup, msg)
+	}
+
+	running, err := r.isBackupRunning(schedule)
+	if err != nil {
+		r.recorder.Event(
+			schedule,
+			corev1.EventTypeWarning,
+			event.ScheduleError,
+			err.Error(),
+		)
+		return reconcile.Result{}, err
+	}
+	if running {
+		msg := fmt.Sprintf("Skipped backup scheduled at %s, a previous backup is still running", scheduledTime.Format(time.RFC3339))
+		reqLogger.Info(msg)
+		r.recorder.Event(
+			schedule,
+			corev1.EventTypeNormal,
+			event.BackupSkipped,
+			msg,
+		)
+		return requeue, r.markAsScheduled(schedule, *scheduledTime, schedule.Status.LastBackup, msg)
+	}
+
+	backup := newBackupForSchedule(schedule, *scheduledTime)
+	if err := r.client.Create(context.TODO(), backup); err != nil && !errors.IsAlreadyExists(err) {
+		r.recorder.Event(
+			schedule,
+			corev1.EventTypeWarning,
+			event.ScheduleError,
+			err.Error(),
+		)
+		return reconcile.Result{}, err
+	}
+
+	msg := fmt.Sprintf("Created backup %s", backup.Name)
+	reqLogger.Info(msg)
+	r.recorder.Event(
+		schedule,
+		corev1.EventTypeNormal,
+		event.BackupScheduled,
+		msg,
+	)
+	if err := r.markAsScheduled(schedule, *scheduledTime, backup.Name, ""); err != nil {
+		r.recorder.Event(
+			schedule,
+			corev1.EventTypeWarning,
+			event.ScheduleError,
+			err.Error(),
+		)
+		return reconcile.Result{}, err
+	}
+
+	return requeue, nil
+}
+
+func validateSchedule(schedule *redisv1alpha1.RedisClusterBackupSchedule) error {
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+	backup := &redisv1alpha1.RedisClusterBackup{
+		Spec: schedule.Spec.BackupTemplate,
+	}
+	backup.Spec.RedisClusterName = schedule.Spec.RedisClusterName
+	return backup.Validate()
+}
+
+// tooManyMissedRuns bounds the missed runs walked through one by one, as the CronJob controller does.
+const tooManyMissedRuns = 100
+
+// getMostRecentScheduleTime returns the latest scheduled time between the last
+// scheduled (or creation) time and now, and the number of runs missed until then,
+// at most tooManyMissedRuns+1. Missed runs before it are not replayed.
+// Returns nil if no scheduled time has been reached yet.
+func getMostRecentScheduleTime(schedule *redisv1alpha1.RedisClusterBackupSchedule, sched cron.Schedule, now time.Time) (*time.Time, int) {
+	earliestTime := schedule.CreationTimestamp.Time
+	if schedule.Status.LastScheduleTime != nil {
+		earliestTime = schedule.Status.LastScheduleTime.Time
+	}
+	if earliestTime.After(now) {
+		return nil, 0
+	}
+
+	var mostRecent *time.Time
+	missed := 0
+	for t := sched.Next(earliestTime); !t.After(now); t = sched.Next(t) {
+		scheduled := t
+		mostRecent = &scheduled
+		missed++
+		if missed > tooManyMissedRuns {
+			// a schedule suspended or not reconciled for long would be walked through run by run.
+			return latestScheduleTime(sched, scheduled, now), missed
+		}
+	}
+	return mostRecent, missed
+}
+
+// latestScheduleTime returns the latest scheduled time between the scheduled time
+// earliest and now. The runs are only walked through from the start of the shortest
+// window before now, doubled from a minute, with a run in it.
+func latestScheduleTime(sched cron.Schedule, earliest, now time.Time) *time.Time {
+	start := earliest
+	for window := time.Minute; now.Add(-window).After(earliest); window *= 2 {
+		if !sched.Next(now.Add(-window)).After(now) {
+			start = now.Add(-window)
+			break
+		}
+	}
+	latest := earliest
+	for t := sched.Next(start); !t.After(now); t = sched.Next(t) {
+		latest = t
+	}
+	return &latest
+}
+
+func newBackupForSchedule(schedule *redisv1alpha1.RedisClusterBackupSchedule, scheduledTime time.Time) *redisv1alpha1.RedisClusterBackup {
+	spec := schedule.Spec.BackupTemplate.DeepCopy()
+	spec.RedisClusterName = schedule.Spec.RedisClusterName
+	backup := &redisv1alpha1.RedisClusterBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backupName(schedule.Name, scheduledTime),
+			Namespace: schedule.Namespace,
+			Labels: map[string]string{
+				redisv1alpha1.LabelClusterName:    schedule.Spec.RedisClusterName,
+				redisv1alpha1.LabelBackupSchedule: schedule.Name,
+			},
+		},
+		Spec: *spec,
+	}
+	// the backup must be managed by the same operator as its schedule.
+	if scope, ok := schedule.Annotations[utils.AnnotationScope]; ok {
+		backup.Annotations = map[string]string{
+			utils.AnnotationScope: scope,
+		}
+	}
+	return backup
+}
+
+// backupName returns a deterministic name for the backup scheduled at
+// scheduledTime, so that a retried reconcile does not create the same backup twice.
+func backupName(scheduleName string, scheduledTime time.Time) string {
+	return fmt.Sprintf("%s-%d", scheduleName, scheduledTime.Unix())
+}
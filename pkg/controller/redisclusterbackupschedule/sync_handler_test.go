@@ -0,0 +1,121 @@
+package redisclusterbackupschedule
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+)
+
+func Test_getMostRecentScheduleTime(t *testing.T) {
+	sched, err := cron.ParseStandard("0 * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2020, 1, 1, 10, 30, 0, 0, time.UTC)
+	timeAt := func(hour, min int) *time.Time {
+		t := time.Date(2020, 1, 1, hour, min, 0, 0, time.UTC)
+		return &t
+	}
+	type args struct {
+		lastScheduleTime *time.Time
+		now              time.Time
+	}
+	tests := []struct {
+		name string
+		args args
+		want *time.Time
+	}{
+		{
+			name: "not yet reached",
+			args: args{
+				now: *timeAt(10, 50),
+			},
+			want: nil,
+		},
+		{
+			name: "first schedule after creation",
+			args: args{
+				now: *timeAt(11, 5),
+			},
+			want: timeAt(11, 0),
+		},
+		{
+			name: "missed schedules are not replayed",
+			args: args{
+				now: *timeAt(13, 20),
+			},
+			want: timeAt(13, 0),
+		},
+		{
+			name: "already scheduled",
+			args: args{
+				lastScheduleTime: timeAt(13, 0),
+				now:              *timeAt(13, 20),
+			},
+			want: nil,
+		},
+		{
+			name: "next schedule after last",
+			args: args{
+				lastScheduleTime: timeAt(13, 0),
+				now:              *timeAt(14, 0),
+			},
+			want: timeAt(14, 0),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := &redisv1alpha1.RedisClusterBackupSchedule{
+				ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)},
+			}
+			if tt.args.lastScheduleTime != nil {
+				last := metav1.NewTime(*tt.args.lastScheduleTime)
+				schedule.Status.LastScheduleTime = &last
+			}
+			if got, _ := getMostRecentScheduleTime(schedule, sched, tt.args.now); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getMostRecentScheduleTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getMostRecentScheduleTime_TooManyMissed(t *testing.T) {
+	sched, err := cron.ParseStandard("* * * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	schedule := &redisv1alpha1.RedisClusterBackupSchedule{
+		ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)},
+	}
+	now := time.Date(2020, 7, 1, 12, 30, 30, 0, time.UTC)
+	got, missed := getMostRecentScheduleTime(schedule, sched, now)
+	if want := time.Date(2020, 7, 1, 12, 30, 0, 0, time.UTC); got == nil || !got.Equal(want) {
+		t.Errorf("getMostRecentScheduleTime() = %v, want %v", got, want)
+	}
+	if missed != tooManyMissedRuns+1 {
+		t.Errorf("getMostRecentScheduleTime() missed = %d, want %d", missed, tooManyMissedRuns+1)
+	}
+
+	// the runs of a schedule with long gaps are not lost.
+	sched, err = cron.ParseStandard("* 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ = getMostRecentScheduleTime(schedule, sched, now)
+	if want := time.Date(2020, 7, 1, 9, 59, 0, 0, time.UTC); got == nil || !got.Equal(want) {
+		t.Errorf("getMostRecentScheduleTime() = %v, want %v", got, want)
+	}
+}
+
+func Test_backupName(t *testing.T) {
+	scheduled := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got, want := backupName("daily", scheduled), "daily-1577836800"; got != want {
+		t.Errorf("backupName() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,160 @@
+package redisclusterrestore
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/config"
+	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/osm"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
+)
+
+func (r *ReconcileRedisClusterRestore) markAsFailedRestore(restore *redisv1alpha1.RedisClusterRestore,
+	reason string) error {
+	t := metav1.Now()
+	restore.Status.CompletionTime = &t
+	restore.Status.Phase = redisv1alpha1.RestorePhaseFailed
+	restore.Status.Reason = reason
+	return r.crController.UpdateCRStatus(restore)
+}
+
+func (r *ReconcileRedisClusterRestore) markAsPhase(restore *redisv1alpha1.RedisClusterRestore,
+	phase redisv1alpha1.RestorePhase) error {
+	if phase == redisv1alpha1.RestorePhaseSucceeded {
+		t := metav1.Now()
+		restore.Status.CompletionTime = &t
+	}
+	restore.Status.Phase = phase
+	restore.Status.Reason = ""
+	return r.crController.UpdateCRStatus(restore)
+}
+
+// getBackup returns the backup to restore, from its RedisClusterBackup or from its metadata in the bucket.
+func (r *ReconcileRedisClusterRestore) getBackup(restore *redisv1alpha1.RedisClusterRestore) (*redisv1alpha1.RedisClusterBackup, error) {
+	if source := restore.Spec.BackupSource.Bucket; source != nil {
+		return osm.ReadBackupMetadata(r.client, source, restore.Namespace)
+	}
+	return r.crController.GetRedisClusterBackup(backupNamespace(restore), restore.Spec.BackupSource.Name)
+}
+
+// backupNamespace returns the namespace of the backup to restore, defaults to the namespace of the restore.
+func backupNamespace(restore *redisv1alpha1.RedisClusterRestore) string {
+	if restore.Spec.BackupSource.Namespace != "" {
+		return restore.Spec.BackupSource.Namespace
+	}
+	return restore.Namespace
+}
+
+// hasRestoreInitContainer returns true if the pods of ss pull the given backup when they start.
+func hasRestoreInitContainer(ss *appsv1.StatefulSet, backup *redisv1alpha1.RedisClusterBackup) bool {
+	snapshot := fmt.Sprintf("--snapshot=%s", backup.Name)
+	for _, c := range ss.Spec.Template.Spec.InitContainers {
+		if c.Name != redisv1alpha1.JobTypeRestore {
+			continue
+		}
+		for _, arg := range c.Args {
+			if arg == snapshot {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkShardSlots returns an error if a shard of the backup does not own the slots of the shard of the cluster
+// with the same index, the master of the StatefulSet drc-<name>-<i> loads the RDB file of the shard i.
+// Backups taken before the manifests record the slots are not checked.
+func checkShardSlots(cluster *redisv1alpha1.DistributedRedisCluster, backup *redisv1alpha1.RedisClusterBackup) error {
+	for i, manifest := range backup.Status.Manifests {
+		if manifest.Slots == "" {
+			continue
+		}
+		backupSlots, err := normalizeSlots(strings.Fields(manifest.Slots))
+		if err != nil {
+			return fmt.Errorf("cannot decode the slots of shard %d of backup %s: %v", i, backup.Name, err)
+		}
+		name := statefulsets.ClusterStatefulSetName(cluster.Name, i)
+		var ranges []string
+		found := false
+		for _, node := range cluster.Status.Nodes {
+			if node.StatefulSet == name && node.Role == redisv1alpha1.RedisClusterNodeRoleMaster {
+				ranges = append(ranges, node.Slots...)
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("shard %d of cluster %s has no master, set reshard to restore it anyway", i, cluster.Name)
+		}
+		clusterSlots, err := normalizeSlots(ranges)
+		if err != nil {
+			return fmt.Errorf("cannot decode the slots of shard %d of cluster %s: %v", i, cluster.Name, err)
+		}
+		if backupSlots != clusterSlots {
+			return fmt.Errorf("shard %d of backup has slots %q but shard %d of cluster %s has %q, set reshard to restore it anyway",
+				i, backupSlots, i, cluster.Name, clusterSlots)
+		}
+	}
+	return nil
+}
+
+// normalizeSlots returns the slot ranges owned by a node, the slots being migrated or imported are ignored.
+func normalizeSlots(ranges []string) (string, error) {
+	var slots []redisutil.Slot
+	for _, r := range ranges {
+		decoded, _, _, err := redisutil.DecodeSlotRange(r)
+		if err != nil {
+			return "", err
+		}
+		slots = append(slots, decoded...)
+	}
+	var normalized []string
+	for _, r := range redisutil.SlotRangesFromSlots(slots) {
+		normalized = append(normalized, r.String())
+	}
+	return strings.Join(normalized, " "), nil
+}
+
+// newRedisAdmin builds and returns new redis.Admin connected to the running pods of the cluster.
+func (r *ReconcileRedisClusterRestore) newRedisAdmin(reqLogger logr.Logger, cluster *redisv1alpha1.DistributedRedisCluster,
+	password string) (redisutil.IAdmin, error) {
+	nodesAddrs := []string{}
+	announcedAddrs := map[string]string{}
+	for i := 0; i < int(cluster.Spec.MasterSize); i++ {
+		pods, err := r.statefulSetController.GetStatefulSetPods(cluster.Namespace, statefulsets.ClusterStatefulSetName(cluster.Name, i))
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods.Items {
+			if pod.Status.PodIP == "" || pod.DeletionTimestamp != nil {
+				continue
+			}
+			addr := net.JoinHostPort(pod.Status.PodIP, redisutil.DefaultRedisPort)
+			nodesAddrs = append(nodesAddrs, addr)
+			if announced := k8sutil.AnnouncedAddress(&pod); announced != "" {
+				announcedAddrs[announced] = addr
+			}
+		}
+	}
+	tlsConfig, err := k8sutil.GetClusterTLSConfig(r.client, cluster)
+	if err != nil {
+		return nil, err
+	}
+	cfg := config.RedisConf()
+	adminConfig := redisutil.AdminOptions{
+		ConnectionTimeout:  time.Duration(cfg.DialTimeout) * time.Millisecond,
+		RenameCommandsFile: cfg.GetRenameCommandsFile(),
+		Password:           password,
+		TLSConfig:          tlsConfig,
+		AnnouncedAddrs:     announcedAddrs,
+	}
+	return redisutil.NewAdmin(nodesAddrs, &adminConfig, reqLogger), nil
+}
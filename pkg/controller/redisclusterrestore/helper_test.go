@@ -0,0 +1,140 @@
+package redisclusterrestore
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+)
+
+func TestCheckShardSlots(t *testing.T) {
+	cluster := &redisv1alpha1.DistributedRedisCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"},
+		Status: redisv1alpha1.DistributedRedisClusterStatus{
+			Nodes: []redisv1alpha1.RedisClusterNode{
+				{ID: "a", Role: redisv1alpha1.RedisClusterNodeRoleMaster, Slots: []string{"0-8191"}, StatefulSet: "drc-cluster-0"},
+				{ID: "b", Role: redisv1alpha1.RedisClusterNodeRoleSlave, MasterRef: "a", StatefulSet: "drc-cluster-0"},
+				{ID: "c", Role: redisv1alpha1.RedisClusterNodeRoleMaster, Slots: []string{"8192-16382", "16383-16383"}, StatefulSet: "drc-cluster-1"},
+			},
+		},
+	}
+	tests := []struct {
+		name      string
+		manifests []redisv1alpha1.BackupManifest
+		wantErr   bool
+	}{
+		{
+			name: "same slots",
+			manifests: []redisv1alpha1.BackupManifest{
+				{NodeID: "x", Slots: "0-8191"},
+				{NodeID: "y", Slots: "8192-16383"},
+			},
+		},
+		{
+			name: "same slots in other ranges",
+			manifests: []redisv1alpha1.BackupManifest{
+				{NodeID: "x", Slots: "0-4095 4096-8191"},
+				{NodeID: "y", Slots: "16383 8192-16382 [8192->-x]"},
+			},
+		},
+		{
+			name:      "backup without slots",
+			manifests: []redisv1alpha1.BackupManifest{{NodeID: "x"}, {NodeID: "y"}},
+		},
+		{
+			name: "shards swapped",
+			manifests: []redisv1alpha1.BackupManifest{
+				{NodeID: "x", Slots: "8192-16383"},
+				{NodeID: "y", Slots: "0-8191"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "slots moved",
+			manifests: []redisv1alpha1.BackupManifest{
+				{NodeID: "x", Slots: "0-8000"},
+				{NodeID: "y", Slots: "8001-16383"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "shard without master",
+			manifests: []redisv1alpha1.BackupManifest{
+				{NodeID: "x", Slots: "0-8191"},
+				{NodeID: "y", Slots: "8192-16383"},
+				{NodeID: "z", Slots: "0"},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backup := &redisv1alpha1.RedisClusterBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "default"},
+				Status:     redisv1alpha1.RedisClusterBackupStatus{Manifests: tt.manifests},
+			}
+			if err := checkShardSlots(cluster, backup); (err != nil) != tt.wantErr {
+				t.Errorf("checkShardSlots() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRestore_Storage(t *testing.T) {
+	backup := &redisv1alpha1.RedisClusterBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "default"},
+		Status: redisv1alpha1.RedisClusterBackupStatus{
+			Phase:      redisv1alpha1.BackupPhaseSucceeded,
+			MasterSize: 3,
+		},
+	}
+	tests := []struct {
+		name    string
+		storage *redisv1alpha1.RedisStorage
+		reshard bool
+		wantErr bool
+	}{
+		{
+			name:    "persistent claim",
+			storage: &redisv1alpha1.RedisStorage{Type: redisv1alpha1.PersistentClaim},
+		},
+		{
+			name:    "ephemeral",
+			storage: &redisv1alpha1.RedisStorage{Type: redisv1alpha1.Ephemeral},
+			wantErr: true,
+		},
+		{
+			name:    "no storage",
+			wantErr: true,
+		},
+		{
+			name:    "no storage resharded",
+			reshard: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster := &redisv1alpha1.DistributedRedisCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"},
+				Spec: redisv1alpha1.DistributedRedisClusterSpec{
+					MasterSize: 3,
+					Storage:    tt.storage,
+				},
+				Status: redisv1alpha1.DistributedRedisClusterStatus{Status: redisv1alpha1.ClusterStatusOK},
+			}
+			restore := &redisv1alpha1.RedisClusterRestore{
+				ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: "default"},
+				Spec: redisv1alpha1.RedisClusterRestoreSpec{
+					RedisClusterName: "cluster",
+					BackupSource:     redisv1alpha1.BackupSourceSpec{Name: "backup"},
+					Reshard:          tt.reshard,
+				},
+			}
+			r := &ReconcileRedisClusterRestore{}
+			if err := r.validateRestore(restore, cluster, backup); (err != nil) != tt.wantErr {
+				t.Errorf("validateRestore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
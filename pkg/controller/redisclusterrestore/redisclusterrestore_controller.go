@@ -0,0 +1,154 @@
+package redisclusterrestore
+
+import (
+	"context"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/utils"
+)
+
+var (
+	log = logf.Log.WithName("controller_redisclusterrestore")
+
+	controllerFlagSet *pflag.FlagSet
+	// maxConcurrentReconciles is the maximum number of concurrent Reconciles which can be run. Defaults to 1.
+	maxConcurrentReconciles int
+)
+
+func init() {
+	controllerFlagSet = pflag.NewFlagSet("controller", pflag.ExitOnError)
+	controllerFlagSet.IntVar(&maxConcurrentReconciles, "restorectr-maxconcurrent", 1, "the maximum number of concurrent Reconciles which can be run. Defaults to 1.")
+}
+
+func FlagSet() *pflag.FlagSet {
+	return controllerFlagSet
+}
+
+// Add creates a new RedisClusterRestore Controller and adds it to the Manager. The Manager will set fields on the Controller
+// and Start it when the Manager is Started.
+func Add(mgr manager.Manager) error {
+	return add(mgr, newReconciler(mgr))
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+	r := &ReconcileRedisClusterRestore{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	r.crController = k8sutil.NewCRControl(r.client)
+	r.statefulSetController = k8sutil.NewStatefulSetController(r.client)
+	r.serviceController = k8sutil.NewServiceController(r.client)
+	r.configMapController = k8sutil.NewConfigMapController(r.client)
+	r.pvcController = k8sutil.NewPvcController(r.client)
+	r.podController = k8sutil.NewPodController(r.client)
+	r.recorder = mgr.GetEventRecorderFor("redis-cluster-operator-restore")
+	return r
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	// Create a new controller
+	c, err := controller.New("redisclusterrestore-controller", mgr, controller.Options{Reconciler: r, MaxConcurrentReconciles: maxConcurrentReconciles})
+	if err != nil {
+		return err
+	}
+
+	pred := predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			// returns false if RedisClusterRestore is ignored (not managed) by this operator.
+			if !utils.ShoudManage(e.MetaNew) {
+				return false
+			}
+			log.WithValues("namespace", e.MetaNew.GetNamespace(), "name", e.MetaNew.GetName()).V(5).Info("Call UpdateFunc")
+			// Ignore updates to CR status in which case metadata.Generation does not change
+			if e.MetaOld.GetGeneration() != e.MetaNew.GetGeneration() {
+				log.WithValues("namespace", e.MetaNew.GetNamespace(), "name", e.MetaNew.GetName()).Info("Generation change return true",
+					"old", e.ObjectOld, "new", e.ObjectNew)
+				return true
+			}
+			return false
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			// returns false if RedisClusterRestore is ignored (not managed) by this operator.
+			if !utils.ShoudManage(e.Meta) {
+				return false
+			}
+			log.WithValues("namespace", e.Meta.GetNamespace(), "name", e.Meta.GetName()).Info("Call DeleteFunc")
+			// Evaluates to false if the object has been confirmed deleted.
+			return !e.DeleteStateUnknown
+		},
+		CreateFunc: func(e event.CreateEvent) bool {
+			// returns false if RedisClusterRestore is ignored (not managed) by this operator.
+			if !utils.ShoudManage(e.Meta) {
+				return false
+			}
+			log.WithValues("namespace", e.Meta.GetNamespace(), "name", e.Meta.GetName()).Info("Call CreateFunc")
+			return true
+		},
+	}
+
+	// Watch for changes to primary resource RedisClusterRestore
+	err = c.Watch(&source.Kind{Type: &redisv1alpha1.RedisClusterRestore{}}, &handler.EnqueueRequestForObject{}, pred)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// blank assignment to verify that ReconcileRedisClusterRestore implements reconcile.Reconciler
+var _ reconcile.Reconciler = &ReconcileRedisClusterRestore{}
+
+// ReconcileRedisClusterRestore reconciles a RedisClusterRestore object
+type ReconcileRedisClusterRestore struct {
+	// This client, initialized using mgr.Client() above, is a split client
+	// that reads objects from the cache and writes to the apiserver
+	client   client.Client
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
+
+	crController          k8sutil.ICustomResource
+	statefulSetController k8sutil.IStatefulSetControl
+	serviceController     k8sutil.IServiceControl
+	configMapController   k8sutil.IConfigMapControl
+	pvcController         k8sutil.IPvcControl
+	podController         k8sutil.IPodControl
+}
+
+// Reconcile reads that state of the cluster for a RedisClusterRestore object and moves the restore
+// to its next phase, the request is requeued until the restore is completed.
+// Note:
+// The Controller will requeue the Request to be processed again if the returned error is non-nil or
+// Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
+func (r *ReconcileRedisClusterRestore) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	reqLogger := log.WithValues("Request.Namespace", request.Namespace, "Request.Name", request.Name)
+	reqLogger.Info("Reconciling RedisClusterRestore")
+
+	// Fetch the RedisClusterRestore instance
+	instance := &redisv1alpha1.RedisClusterRestore{}
+	err := r.client.Get(context.TODO(), request.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// Request object not found, could have been deleted after reconcile request.
+			// Return and don't requeue
+			return reconcile.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		return reconcile.Result{}, err
+	}
+
+	return r.sync(reqLogger, instance)
+}
@@ -0,0 +1,196 @@
+package redisclusterrestore
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/config"
+	"github.com/ucloud/redis-cluster-operator/pkg/event"
+	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
+)
+
+const (
+	// reshardBatch is the number of keys moved by a single MIGRATE command.
+	reshardBatch = 100
+	// reshardDuration is how long a reconcile keeps moving keys before updating the restore status.
+	reshardDuration = 30 * time.Second
+	// migrateTimeout is the MIGRATE timeout in milliseconds.
+	migrateTimeout = 30000
+)
+
+// loadBackup starts a standalone redis server for each snapshot of the backup and returns true once
+// all of them have loaded their snapshot.
+func (r *ReconcileRedisClusterRestore) loadBackup(reqLogger logr.Logger, restore *redisv1alpha1.RedisClusterRestore,
+	cluster *redisv1alpha1.DistributedRedisCluster, backup *redisv1alpha1.RedisClusterBackup) (bool, error) {
+	if err := r.ensureOSMSecret(reqLogger, cluster, backup); err != nil {
+		return false, err
+	}
+
+	loaded := true
+	for i := 0; i < int(backup.Status.MasterSize); i++ {
+		name := statefulsets.RestoreLoaderPodName(restore.Name, i)
+		pod, err := r.podController.GetPod(restore.Namespace, name)
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				return false, err
+			}
+			reqLogger.Info("creating loader", "pod", name, "backup", backup.Name)
+			pod, err = statefulsets.NewRestoreLoaderPod(cluster, backup, name, loaderLabels(), restoreOwnerReferences(restore))
+			if err != nil {
+				return false, err
+			}
+			if err := r.podController.CreatePod(pod); err != nil {
+				return false, err
+			}
+			loaded = false
+			continue
+		}
+		if err := statefulsets.RestoreContainerError(pod); err != nil {
+			return false, err
+		}
+		if pod.Status.Phase == corev1.PodFailed {
+			return false, fmt.Errorf("loader pod %s failed: %s %s", pod.Name, pod.Status.Reason, pod.Status.Message)
+		}
+		if !k8sutil.IsPodReady(pod) {
+			loaded = false
+		}
+	}
+	return loaded, nil
+}
+
+// flushCluster removes all the keys of the cluster before the backup is resharded into it.
+func (r *ReconcileRedisClusterRestore) flushCluster(reqLogger logr.Logger, cluster *redisv1alpha1.DistributedRedisCluster) error {
+	password, err := k8sutil.GetClusterPassword(r.client, cluster)
+	if err != nil {
+		return err
+	}
+	admin, err := r.newRedisAdmin(reqLogger, cluster, password)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+	infos, err := admin.GetClusterInfos()
+	if err != nil {
+		return err
+	}
+	for _, master := range infos.GetNodes().FilterByFunc(redisutil.IsMasterWithSlot) {
+		reqLogger.Info("flushing master", "node", master.IPPort())
+		c, err := admin.Connections().Get(master.IPPort())
+		if err != nil {
+			return err
+		}
+		if err := admin.Connections().ValidateResp(c.Cmd("FLUSHALL"), master.IPPort(), "Unable to run command FLUSHALL"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reshardKeys moves the keys of the loader pods into the masters serving their slots, it returns true
+// once all the keys are moved and the loader pods are deleted. Redis errors are retried on the next reconcile.
+func (r *ReconcileRedisClusterRestore) reshardKeys(reqLogger logr.Logger, restore *redisv1alpha1.RedisClusterRestore,
+	cluster *redisv1alpha1.DistributedRedisCluster, backup *redisv1alpha1.RedisClusterBackup) (bool, error) {
+	password, err := k8sutil.GetClusterPassword(r.client, cluster)
+	if err != nil {
+		return false, err
+	}
+	admin, err := r.newRedisAdmin(reqLogger, cluster, password)
+	if err != nil {
+		return false, err
+	}
+	defer admin.Close()
+	infos, err := admin.GetClusterInfos()
+	if err != nil {
+		r.reshardError(reqLogger, restore, err)
+		return false, nil
+	}
+	owners, err := redisutil.SlotOwners(infos.GetNodes())
+	if err != nil {
+		r.reshardError(reqLogger, restore, err)
+		return false, nil
+	}
+
+	tlsConfig, err := k8sutil.GetClusterTLSConfig(r.client, cluster)
+	if err != nil {
+		return false, err
+	}
+	timeout := time.Duration(config.RedisConf().DialTimeout) * time.Millisecond
+	deadline := time.Now().Add(reshardDuration)
+	var remaining int64
+	for i := 0; i < int(backup.Status.MasterSize); i++ {
+		pod, err := r.podController.GetPod(restore.Namespace, statefulsets.RestoreLoaderPodName(restore.Name, i))
+		if err != nil {
+			return false, err
+		}
+		if pod.Status.Phase == corev1.PodFailed {
+			return false, fmt.Errorf("loader pod %s failed: %s %s", pod.Name, pod.Status.Reason, pod.Status.Message)
+		}
+		c, err := redisutil.NewTLSClient(net.JoinHostPort(pod.Status.PodIP, redisutil.DefaultRedisPort), password, timeout, nil, tlsConfig)
+		if err != nil {
+			r.reshardError(reqLogger, restore, err)
+			return false, nil
+		}
+		left, err := c.Cmd("DBSIZE").Int64()
+		for err == nil && left > 0 && time.Now().Before(deadline) {
+			left, err = redisutil.ReshardKeys(c, owners, reshardBatch, migrateTimeout, password)
+		}
+		c.Close()
+		if err != nil {
+			r.reshardError(reqLogger, restore, err)
+			return false, nil
+		}
+		reqLogger.V(3).Info("resharding", "pod", pod.Name, "remaining", left)
+		remaining += left
+	}
+
+	if restore.Status.RemainingKeys != remaining {
+		restore.Status.RemainingKeys = remaining
+		if err := r.crController.UpdateCRStatus(restore); err != nil {
+			return false, err
+		}
+	}
+	if remaining > 0 {
+		return false, nil
+	}
+
+	for i := 0; i < int(backup.Status.MasterSize); i++ {
+		name := statefulsets.RestoreLoaderPodName(restore.Name, i)
+		reqLogger.Info("deleting loader", "pod", name)
+		if err := r.podController.DeletePodByName(restore.Namespace, name); err != nil && !errors.IsNotFound(err) {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (r *ReconcileRedisClusterRestore) reshardError(reqLogger logr.Logger, restore *redisv1alpha1.RedisClusterRestore, err error) {
+	reqLogger.Error(err, "resharding failed, retrying")
+	r.recorder.Event(
+		restore,
+		corev1.EventTypeWarning,
+		event.RestoreError,
+		err.Error(),
+	)
+}
+
+func loaderLabels() map[string]string {
+	return map[string]string{
+		redisv1alpha1.LabelManagedByKey: redisv1alpha1.OperatorName,
+		redisv1alpha1.AnnotationJobType: redisv1alpha1.JobTypeRestore,
+	}
+}
+
+func restoreOwnerReferences(restore *redisv1alpha1.RedisClusterRestore) []metav1.OwnerReference {
+	return []metav1.OwnerReference{
+		*metav1.NewControllerRef(restore, redisv1alpha1.SchemeGroupVersion.WithKind(redisv1alpha1.RedisClusterRestoreKind)),
+	}
+}
@@ -0,0 +1,511 @@
+package redisclusterrestore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/event"
+	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/osm"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/configmaps"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
+)
+
+const (
+	requeueAfter = 10 * time.Second
+)
+
+// sync moves the restore forward by one step:
+// "" -> Draining -> Stopping -> Restoring -> Starting -> Succeeded, or
+// "" -> Draining -> Loading -> Resharding -> Starting -> Succeeded if the backup is resharded.
+// The cluster paused by a restore that fails is resumed, see resumeCluster.
+func (r *ReconcileRedisClusterRestore) sync(reqLogger logr.Logger, restore *redisv1alpha1.RedisClusterRestore) (reconcile.Result, error) {
+	// Do not process completed restores.
+	if restore.Status.Phase == redisv1alpha1.RestorePhaseSucceeded {
+		return reconcile.Result{}, nil
+	}
+	if restore.Status.Phase == redisv1alpha1.RestorePhaseFailed {
+		// retried until the cluster is resumed, it does nothing once it is.
+		return reconcile.Result{}, r.resumeCluster(reqLogger, restore)
+	}
+
+	if restore.Status.StartTime == nil {
+		t := metav1.Now()
+		restore.Status.StartTime = &t
+		if err := r.crController.UpdateCRStatus(restore); err != nil {
+			r.recorder.Event(
+				restore,
+				corev1.EventTypeWarning,
+				event.RestoreError,
+				err.Error(),
+			)
+			return reconcile.Result{}, err
+		}
+	}
+
+	next, err := r.step(reqLogger, restore)
+	if err != nil {
+		if k8sutil.IsRequestRetryable(err) || errors.IsConflict(err) {
+			return reconcile.Result{}, err
+		}
+		reqLogger.Error(err, "restore failed", "phase", restore.Status.Phase)
+		r.markAsFailedRestore(restore, err.Error())
+		r.recorder.Event(
+			restore,
+			corev1.EventTypeWarning,
+			event.RestoreFailed,
+			err.Error(),
+		)
+		return reconcile.Result{}, r.resumeCluster(reqLogger, restore) // stop retry once resumed
+	}
+
+	if next != restore.Status.Phase {
+		msg := fmt.Sprintf("Restore phase changed from %q to %q", restore.Status.Phase, next)
+		reqLogger.Info(msg)
+		if err := r.markAsPhase(restore, next); err != nil {
+			r.recorder.Event(
+				restore,
+				corev1.EventTypeWarning,
+				event.RestoreError,
+				err.Error(),
+			)
+			return reconcile.Result{}, err
+		}
+		if next == redisv1alpha1.RestorePhaseSucceeded {
+			r.recorder.Event(
+				restore,
+				corev1.EventTypeNormal,
+				event.RestoreSucceeded,
+				"Restore completed successfully",
+			)
+			return reconcile.Result{}, nil
+		}
+		r.recorder.Event(
+			restore,
+			corev1.EventTypeNormal,
+			event.RestorePhase,
+			msg,
+		)
+	}
+
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// step runs the current phase of the restore and returns the phase to move to.
+func (r *ReconcileRedisClusterRestore) step(reqLogger logr.Logger, restore *redisv1alpha1.RedisClusterRestore) (redisv1alpha1.RestorePhase, error) {
+	phase := restore.Status.Phase
+	if err := restore.Validate(); err != nil {
+		return phase, err
+	}
+	cluster, err := r.crController.GetDistributedRedisCluster(restore.Namespace, restore.Spec.RedisClusterName)
+	if err != nil {
+		return phase, err
+	}
+	backup, err := r.getBackup(restore)
+	if err != nil {
+		return phase, err
+	}
+
+	switch phase {
+	case "":
+		if err := r.validateRestore(restore, cluster, backup); err != nil {
+			return phase, err
+		}
+		return redisv1alpha1.RestorePhaseDraining, r.pauseCluster(reqLogger, restore, cluster)
+	case redisv1alpha1.RestorePhaseDraining:
+		if restore.Spec.Reshard {
+			return redisv1alpha1.RestorePhaseLoading, r.drainCluster(reqLogger, restore, cluster)
+		}
+		return redisv1alpha1.RestorePhaseStopping, r.drainCluster(reqLogger, restore, cluster)
+	case redisv1alpha1.RestorePhaseLoading:
+		loaded, err := r.loadBackup(reqLogger, restore, cluster, backup)
+		if err != nil || !loaded {
+			return phase, err
+		}
+		return redisv1alpha1.RestorePhaseResharding, r.flushCluster(reqLogger, cluster)
+	case redisv1alpha1.RestorePhaseResharding:
+		resharded, err := r.reshardKeys(reqLogger, restore, cluster, backup)
+		if err != nil || !resharded {
+			return phase, err
+		}
+		return redisv1alpha1.RestorePhaseStarting, nil
+	case redisv1alpha1.RestorePhaseStopping:
+		stopped, err := r.stopCluster(reqLogger, cluster)
+		if err != nil || !stopped {
+			return phase, err
+		}
+		return redisv1alpha1.RestorePhaseRestoring, nil
+	case redisv1alpha1.RestorePhaseRestoring:
+		restored, err := r.restoreMasters(reqLogger, cluster, backup)
+		if err != nil || !restored {
+			return phase, err
+		}
+		return redisv1alpha1.RestorePhaseStarting, nil
+	case redisv1alpha1.RestorePhaseStarting:
+		started, err := r.startCluster(reqLogger, restore, cluster, backup)
+		if err != nil || !started {
+			return phase, err
+		}
+		return redisv1alpha1.RestorePhaseSucceeded, nil
+	}
+	return phase, fmt.Errorf("unknown restore phase %q", phase)
+}
+
+func (r *ReconcileRedisClusterRestore) validateRestore(restore *redisv1alpha1.RedisClusterRestore,
+	cluster *redisv1alpha1.DistributedRedisCluster, backup *redisv1alpha1.RedisClusterBackup) error {
+	if backup.Status.Phase != redisv1alpha1.BackupPhaseSucceeded {
+		return fmt.Errorf("backup %s/%s is not succeeded", backup.Namespace, backup.Name)
+	}
+	if cluster.IsRestoreFromBackup() && !cluster.IsRestored() {
+		return fmt.Errorf("cluster %s is still restoring from its init backup", cluster.Name)
+	}
+	if name, ok := cluster.Annotations[redisv1alpha1.AnnotationRestore]; ok && name != restore.Name {
+		return fmt.Errorf("cluster %s is being restored by %s", cluster.Name, name)
+	}
+	if restore.Spec.Reshard {
+		// the keys are moved into the running cluster.
+		if cluster.Status.Status != redisv1alpha1.ClusterStatusOK {
+			return fmt.Errorf("cluster %s is not healthy: %s", cluster.Name, cluster.Status.Status)
+		}
+		return nil
+	}
+	// the stopped masters keep the slots they own in the nodes.conf of their volume.
+	if cluster.Spec.Storage == nil || cluster.Spec.Storage.Type != redisv1alpha1.PersistentClaim {
+		return fmt.Errorf("cluster %s has no persistent-claim storage, its nodes lose their slots when they are stopped, "+
+			"set reshard to restore it", cluster.Name)
+	}
+	if backup.Status.MasterSize != cluster.Spec.MasterSize {
+		return fmt.Errorf("backup has %d masters but cluster %s has %d, set reshard to restore it anyway",
+			backup.Status.MasterSize, cluster.Name, cluster.Spec.MasterSize)
+	}
+	// the restored masters keep the slots of their nodes.conf, they must be the slots of the keys they load.
+	return checkShardSlots(cluster, backup)
+}
+
+// pauseCluster stops the DistributedRedisCluster controller from reconciling the cluster during the restore.
+func (r *ReconcileRedisClusterRestore) pauseCluster(reqLogger logr.Logger, restore *redisv1alpha1.RedisClusterRestore,
+	cluster *redisv1alpha1.DistributedRedisCluster) error {
+	if cluster.Annotations[redisv1alpha1.AnnotationRestore] == restore.Name {
+		return nil
+	}
+	reqLogger.Info("pausing cluster", "cluster", cluster.Name)
+	if cluster.Annotations == nil {
+		cluster.Annotations = make(map[string]string)
+	}
+	cluster.Annotations[redisv1alpha1.AnnotationRestore] = restore.Name
+	return r.crController.UpdateCR(cluster)
+}
+
+// resumeCluster undoes the pause of the cluster by a failed restore: the StatefulSets stopped by the restore
+// are scaled up again, the DistributedRedisCluster controller reconciles the cluster again and the client Service
+// selects its pods again. The masters keep the data they have, the backup if they already pulled it, the slaves
+// whose data was removed join them as new nodes.
+func (r *ReconcileRedisClusterRestore) resumeCluster(reqLogger logr.Logger, restore *redisv1alpha1.RedisClusterRestore) error {
+	cluster, err := r.crController.GetDistributedRedisCluster(restore.Namespace, restore.Spec.RedisClusterName)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if cluster.Annotations[redisv1alpha1.AnnotationRestore] == restore.Name {
+		scaled, err := r.scaleUpCluster(reqLogger, cluster)
+		if err != nil {
+			return err
+		}
+		reqLogger.Info("resuming cluster after a failed restore", "cluster", cluster.Name)
+		delete(cluster.Annotations, redisv1alpha1.AnnotationRestore)
+		if err := r.crController.UpdateCR(cluster); err != nil {
+			return err
+		}
+		if scaled {
+			cluster.Status.Status = redisv1alpha1.ClusterStatusScaling
+			cluster.Status.Reason = fmt.Sprintf("resumed after the failed restore %s", restore.Name)
+			if err := r.crController.UpdateCRStatus(cluster); err != nil {
+				return err
+			}
+		}
+		r.recorder.Event(
+			restore,
+			corev1.EventTypeNormal,
+			event.RestorePhase,
+			fmt.Sprintf("Cluster %s resumed", cluster.Name),
+		)
+	}
+
+	svc, err := r.serviceController.GetService(cluster.Namespace, cluster.Spec.ServiceName)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if svc.Spec.Selector[redisv1alpha1.AnnotationRestore] != restore.Name {
+		return nil
+	}
+	reqLogger.Info("undraining service", "service", svc.Name)
+	delete(svc.Spec.Selector, redisv1alpha1.AnnotationRestore)
+	return r.serviceController.UpdateService(svc)
+}
+
+// scaleUpCluster scales the StatefulSets of the cluster stopped by a restore back to their size, the masters
+// started by the restore do not pull the backup again. It returns true if a StatefulSet was scaled.
+func (r *ReconcileRedisClusterRestore) scaleUpCluster(reqLogger logr.Logger, cluster *redisv1alpha1.DistributedRedisCluster) (bool, error) {
+	size := cluster.Spec.ClusterReplicas + 1
+	var stopped []*appsv1.StatefulSet
+	for i := 0; i < int(cluster.Spec.MasterSize); i++ {
+		ss, err := r.statefulSetController.GetStatefulSet(cluster.Namespace, statefulsets.ClusterStatefulSetName(cluster.Name, i))
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return false, err
+		}
+		if ss.Spec.Replicas != nil && *ss.Spec.Replicas < size {
+			stopped = append(stopped, ss)
+		}
+	}
+	if len(stopped) == 0 {
+		return false, nil
+	}
+	if err := r.ensureRestoreConfigMap(cluster, true); err != nil {
+		return false, err
+	}
+	for _, ss := range stopped {
+		reqLogger.Info("scaling up statefulSet", "statefulSet", ss.Name, "replicas", size)
+		replicas := size
+		ss.Spec.Replicas = &replicas
+		if err := r.statefulSetController.UpdateStatefulSet(ss); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// drainCluster removes all the pods from the client Service of the cluster, by selecting a label none of them has.
+func (r *ReconcileRedisClusterRestore) drainCluster(reqLogger logr.Logger, restore *redisv1alpha1.RedisClusterRestore,
+	cluster *redisv1alpha1.DistributedRedisCluster) error {
+	svc, err := r.serviceController.GetService(cluster.Namespace, cluster.Spec.ServiceName)
+	if err != nil {
+		return err
+	}
+	if svc.Spec.Selector[redisv1alpha1.AnnotationRestore] == restore.Name {
+		return nil
+	}
+	reqLogger.Info("draining service", "service", svc.Name)
+	if svc.Spec.Selector == nil {
+		svc.Spec.Selector = make(map[string]string)
+	}
+	svc.Spec.Selector[redisv1alpha1.AnnotationRestore] = restore.Name
+	return r.serviceController.UpdateService(svc)
+}
+
+// stopCluster scales all the StatefulSets of the cluster down to zero and returns true once all the pods are gone.
+// The data of the slaves is removed so that they join the restored masters as new nodes.
+func (r *ReconcileRedisClusterRestore) stopCluster(reqLogger logr.Logger, cluster *redisv1alpha1.DistributedRedisCluster) (bool, error) {
+	stopped := true
+	for i := 0; i < int(cluster.Spec.MasterSize); i++ {
+		name := statefulsets.ClusterStatefulSetName(cluster.Name, i)
+		ss, err := r.statefulSetController.GetStatefulSet(cluster.Namespace, name)
+		if err != nil {
+			return false, err
+		}
+		if *ss.Spec.Replicas != 0 {
+			reqLogger.Info("stopping statefulSet", "statefulSet", name)
+			zero := int32(0)
+			ss.Spec.Replicas = &zero
+			if err := r.statefulSetController.UpdateStatefulSet(ss); err != nil {
+				return false, err
+			}
+		}
+		pods, err := r.statefulSetController.GetStatefulSetPods(cluster.Namespace, name)
+		if err != nil {
+			return false, err
+		}
+		if len(pods.Items) > 0 {
+			stopped = false
+		}
+	}
+	if !stopped {
+		return false, nil
+	}
+
+	if cluster.Spec.Storage == nil || cluster.Spec.Storage.Type != redisv1alpha1.PersistentClaim {
+		return true, nil
+	}
+	for i := 0; i < int(cluster.Spec.MasterSize); i++ {
+		name := statefulsets.ClusterStatefulSetName(cluster.Name, i)
+		for j := 1; j <= int(cluster.Spec.ClusterReplicas); j++ {
+			pvc, err := r.pvcController.GetPvc(cluster.Namespace, statefulsets.ClusterPvcName(name, j))
+			if err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return false, err
+			}
+			reqLogger.Info("deleting slave data", "pvc", pvc.Name)
+			if err := r.pvcController.DeletePvc(pvc); err != nil && !errors.IsNotFound(err) {
+				return false, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// restoreMasters starts the masters with an init container pulling their RDB file from the backup,
+// and returns true once all the masters are ready.
+func (r *ReconcileRedisClusterRestore) restoreMasters(reqLogger logr.Logger, cluster *redisv1alpha1.DistributedRedisCluster,
+	backup *redisv1alpha1.RedisClusterBackup) (bool, error) {
+	if err := r.ensureOSMSecret(reqLogger, cluster, backup); err != nil {
+		return false, err
+	}
+	if err := r.ensureRestoreConfigMap(cluster, false); err != nil {
+		return false, err
+	}
+
+	ready := true
+	for i := 0; i < int(cluster.Spec.MasterSize); i++ {
+		name := statefulsets.ClusterStatefulSetName(cluster.Name, i)
+		ss, err := r.statefulSetController.GetStatefulSet(cluster.Namespace, name)
+		if err != nil {
+			return false, err
+		}
+		if !hasRestoreInitContainer(ss, backup) || *ss.Spec.Replicas != 1 {
+			reqLogger.Info("restoring master", "statefulSet", name, "backup", backup.Name)
+			if err := statefulsets.SetRestoreInitContainer(ss, cluster, backup); err != nil {
+				return false, err
+			}
+			one := int32(1)
+			ss.Spec.Replicas = &one
+			if err := r.statefulSetController.UpdateStatefulSet(ss); err != nil {
+				return false, err
+			}
+			ready = false
+			continue
+		}
+		if ss.Status.ObservedGeneration < ss.Generation || ss.Status.ReadyReplicas != 1 {
+			ready = false
+		}
+		pods, err := r.statefulSetController.GetStatefulSetPods(cluster.Namespace, name)
+		if err != nil {
+			return false, err
+		}
+		for _, pod := range pods.Items {
+			if err := statefulsets.RestoreContainerError(&pod); err != nil {
+				return false, err
+			}
+		}
+	}
+	return ready, nil
+}
+
+// startCluster starts the slaves and resumes the reconciliation of the cluster, it returns true
+// once the cluster is healthy and the client Service selects its pods again.
+func (r *ReconcileRedisClusterRestore) startCluster(reqLogger logr.Logger, restore *redisv1alpha1.RedisClusterRestore,
+	cluster *redisv1alpha1.DistributedRedisCluster, backup *redisv1alpha1.RedisClusterBackup) (bool, error) {
+	if _, paused := cluster.Annotations[redisv1alpha1.AnnotationRestore]; paused {
+		// a resharded cluster has kept running, there is nothing to restart.
+		if !restore.Spec.Reshard {
+			if err := r.restartCluster(reqLogger, cluster, backup); err != nil {
+				return false, err
+			}
+		}
+		reqLogger.Info("resuming cluster", "cluster", cluster.Name)
+		delete(cluster.Annotations, redisv1alpha1.AnnotationRestore)
+		return false, r.crController.UpdateCR(cluster)
+	}
+
+	if cluster.Status.Status != redisv1alpha1.ClusterStatusOK {
+		reqLogger.V(3).Info("waiting for cluster to be healthy", "status", cluster.Status.Status)
+		return false, nil
+	}
+
+	svc, err := r.serviceController.GetService(cluster.Namespace, cluster.Spec.ServiceName)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := svc.Spec.Selector[redisv1alpha1.AnnotationRestore]; ok {
+		reqLogger.Info("undraining service", "service", svc.Name)
+		delete(svc.Spec.Selector, redisv1alpha1.AnnotationRestore)
+		if err := r.serviceController.UpdateService(svc); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// restartCluster starts the slaves of the restored masters, the cluster is then rebuilt by the
+// DistributedRedisCluster controller.
+func (r *ReconcileRedisClusterRestore) restartCluster(reqLogger logr.Logger, cluster *redisv1alpha1.DistributedRedisCluster,
+	backup *redisv1alpha1.RedisClusterBackup) error {
+	// the masters must not pull the backup again when they restart.
+	if err := r.ensureRestoreConfigMap(cluster, true); err != nil {
+		return err
+	}
+	size := cluster.Spec.ClusterReplicas + 1
+	for i := 0; i < int(cluster.Spec.MasterSize); i++ {
+		name := statefulsets.ClusterStatefulSetName(cluster.Name, i)
+		ss, err := r.statefulSetController.GetStatefulSet(cluster.Namespace, name)
+		if err != nil {
+			return err
+		}
+		if *ss.Spec.Replicas != size {
+			reqLogger.Info("starting slaves", "statefulSet", name)
+			ss.Spec.Replicas = &size
+			if err := r.statefulSetController.UpdateStatefulSet(ss); err != nil {
+				return err
+			}
+		}
+	}
+
+	cluster.Status.Status = redisv1alpha1.ClusterStatusScaling
+	cluster.Status.Reason = fmt.Sprintf("restored from backup %s/%s", backup.Namespace, backup.Name)
+	return r.crController.UpdateCRStatus(cluster)
+}
+
+// ensureOSMSecret creates the secret used by the restore init containers to access the backup backend.
+func (r *ReconcileRedisClusterRestore) ensureOSMSecret(reqLogger logr.Logger, cluster *redisv1alpha1.DistributedRedisCluster,
+	backup *redisv1alpha1.RedisClusterBackup) error {
+	secret, err := osm.NewCephSecret(r.client, backup.OSMSecretName(), cluster.Namespace, backup.Spec.Backend)
+	if err != nil {
+		return err
+	}
+	secret.OwnerReferences = redisv1alpha1.DefaultOwnerReferences(cluster)
+	return k8sutil.CreateSecret(r.client, secret, reqLogger)
+}
+
+// ensureRestoreConfigMap tells the restore init container whether the backup has already been pulled.
+func (r *ReconcileRedisClusterRestore) ensureRestoreConfigMap(cluster *redisv1alpha1.DistributedRedisCluster, restored bool) error {
+	value := "0"
+	if restored {
+		value = "1"
+	}
+	cm, err := r.configMapController.GetConfigMap(cluster.Namespace, configmaps.RestoreConfigMapName(cluster.Name))
+	if err != nil {
+		if errors.IsNotFound(err) {
+			cm = configmaps.NewConfigMapForRestore(cluster, map[string]string{
+				redisv1alpha1.LabelManagedByKey: redisv1alpha1.OperatorName,
+				redisv1alpha1.LabelClusterName:  cluster.Name,
+			})
+			cm.Data[configmaps.RestoreSucceeded] = value
+			return r.configMapController.CreateConfigMap(cm)
+		}
+		return err
+	}
+	if cm.Data[configmaps.RestoreSucceeded] == value {
+		return nil
+	}
+	if cm.Data == nil {
+		cm.Data = make(map[string]string)
+	}
+	cm.Data[configmaps.RestoreSucceeded] = value
+	return r.configMapController.UpdateConfigMap(cm)
+}
@@ -0,0 +1,179 @@
+package redisclusterrestore
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	logrtesting "github.com/go-logr/logr/testing"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/k8sutil"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
+)
+
+func newTestReconciler(t *testing.T, objs ...runtime.Object) *ReconcileRedisClusterRestore {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := redisv1alpha1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewFakeClientWithScheme(scheme, objs...)
+	return &ReconcileRedisClusterRestore{
+		client:                c,
+		scheme:                scheme,
+		recorder:              record.NewFakeRecorder(10),
+		crController:          k8sutil.NewCRControl(c),
+		statefulSetController: k8sutil.NewStatefulSetController(c),
+		serviceController:     k8sutil.NewServiceController(c),
+		configMapController:   k8sutil.NewConfigMapController(c),
+		pvcController:         k8sutil.NewPvcController(c),
+		podController:         k8sutil.NewPodController(c),
+	}
+}
+
+// TestSync_ResumeFailedRestore fails the Stopping phase of a restore, the StatefulSet of the second shard is
+// missing, and checks that the cluster paused by the restore is resumed.
+func TestSync_ResumeFailedRestore(t *testing.T) {
+	const namespace = "default"
+	cluster := &redisv1alpha1.DistributedRedisCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "cluster",
+			Namespace:   namespace,
+			Annotations: map[string]string{redisv1alpha1.AnnotationRestore: "restore"},
+		},
+		Spec: redisv1alpha1.DistributedRedisClusterSpec{
+			MasterSize:      2,
+			ClusterReplicas: 1,
+			ServiceName:     "cluster",
+		},
+	}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: namespace},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{
+				redisv1alpha1.LabelClusterName:  "cluster",
+				redisv1alpha1.AnnotationRestore: "restore",
+			},
+		},
+	}
+	labels := map[string]string{redisv1alpha1.StatefulSetLabel: statefulsets.ClusterStatefulSetName("cluster", 0)}
+	replicas := int32(2)
+	ss := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: statefulsets.ClusterStatefulSetName("cluster", 0), Namespace: namespace},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+		},
+	}
+	backup := &redisv1alpha1.RedisClusterBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: namespace},
+		Status: redisv1alpha1.RedisClusterBackupStatus{
+			Phase:      redisv1alpha1.BackupPhaseSucceeded,
+			MasterSize: 2,
+		},
+	}
+	restore := &redisv1alpha1.RedisClusterRestore{
+		ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: namespace},
+		Spec: redisv1alpha1.RedisClusterRestoreSpec{
+			RedisClusterName: "cluster",
+			BackupSource:     redisv1alpha1.BackupSourceSpec{Name: "backup"},
+		},
+		Status: redisv1alpha1.RedisClusterRestoreStatus{
+			Phase: redisv1alpha1.RestorePhaseStopping,
+		},
+	}
+	r := newTestReconciler(t, cluster, svc, ss, backup, restore)
+
+	if _, err := r.sync(logrtesting.NullLogger{}, restore); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+	if restore.Status.Phase != redisv1alpha1.RestorePhaseFailed {
+		t.Errorf("restore phase = %q, want %q", restore.Status.Phase, redisv1alpha1.RestorePhaseFailed)
+	}
+
+	ctx := context.TODO()
+	gotCluster := &redisv1alpha1.DistributedRedisCluster{}
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "cluster"}, gotCluster); err != nil {
+		t.Fatal(err)
+	}
+	if name, ok := gotCluster.Annotations[redisv1alpha1.AnnotationRestore]; ok {
+		t.Errorf("cluster is still paused by the restore %s", name)
+	}
+	gotSvc := &corev1.Service{}
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: "cluster"}, gotSvc); err != nil {
+		t.Fatal(err)
+	}
+	if name, ok := gotSvc.Spec.Selector[redisv1alpha1.AnnotationRestore]; ok {
+		t.Errorf("service is still drained by the restore %s", name)
+	}
+	gotSS := &appsv1.StatefulSet{}
+	if err := r.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ss.Name}, gotSS); err != nil {
+		t.Fatal(err)
+	}
+	if *gotSS.Spec.Replicas != 2 {
+		t.Errorf("statefulSet %s replicas = %d, want 2", ss.Name, *gotSS.Spec.Replicas)
+	}
+
+	// the resumed cluster is left alone by the failed restore.
+	if _, err := r.sync(logrtesting.NullLogger{}, restore); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+}
+
+// TestSync_RejectEphemeralCluster checks that the in-place restore of a cluster without persistent storage fails
+// before the cluster is paused.
+func TestSync_RejectEphemeralCluster(t *testing.T) {
+	const namespace = "default"
+	cluster := &redisv1alpha1.DistributedRedisCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: namespace},
+		Spec: redisv1alpha1.DistributedRedisClusterSpec{
+			MasterSize:  2,
+			ServiceName: "cluster",
+			Storage:     &redisv1alpha1.RedisStorage{Type: redisv1alpha1.Ephemeral},
+		},
+	}
+	backup := &redisv1alpha1.RedisClusterBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: namespace},
+		Status: redisv1alpha1.RedisClusterBackupStatus{
+			Phase:      redisv1alpha1.BackupPhaseSucceeded,
+			MasterSize: 2,
+		},
+	}
+	restore := &redisv1alpha1.RedisClusterRestore{
+		ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: namespace},
+		Spec: redisv1alpha1.RedisClusterRestoreSpec{
+			RedisClusterName: "cluster",
+			BackupSource:     redisv1alpha1.BackupSourceSpec{Name: "backup"},
+		},
+	}
+	r := newTestReconciler(t, cluster, backup, restore)
+
+	if _, err := r.sync(logrtesting.NullLogger{}, restore); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+	if restore.Status.Phase != redisv1alpha1.RestorePhaseFailed {
+		t.Errorf("restore phase = %q, want %q", restore.Status.Phase, redisv1alpha1.RestorePhaseFailed)
+	}
+	if !strings.Contains(restore.Status.Reason, "persistent-claim") {
+		t.Errorf("restore reason = %q, want the missing persistent-claim storage", restore.Status.Reason)
+	}
+
+	gotCluster := &redisv1alpha1.DistributedRedisCluster{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: "cluster"}, gotCluster); err != nil {
+		t.Fatal(err)
+	}
+	if name, ok := gotCluster.Annotations[redisv1alpha1.AnnotationRestore]; ok {
+		t.Errorf("cluster is paused by the restore %s", name)
+	}
+}
@@ -6,4 +6,20 @@ const (
 	Starting         string = "Starting"
 	Successful       string = "Successful"
 	BackupSuccessful string = "SuccessfulBackup"
+	BackupScheduled  string = "ScheduledBackup"
+	BackupSkipped    string = "SkippedBackup"
+	ScheduleError    string = "ScheduleError"
+	BackupDeleted    string = "DeletedBackup"
+	BackupInUse      string = "BackupInUse"
+	ShardStarted     string = "StartedShardBackup"
+	ShardSucceeded   string = "SuccessfulShardBackup"
+	ShardFailed      string = "FailedShardBackup"
+	RestoreError     string = "RestoreError"
+	RestoreFailed    string = "RestoreFailed"
+	RestorePhase     string = "RestorePhase"
+	RestoreSucceeded string = "SuccessfulRestore"
+	RollbackUpgrade  string = "RollbackUpgrade"
+	RestartPod       string = "RestartPod"
+	PasswordRotation string = "PasswordRotation"
+	MissedBackups    string = "TooManyMissedBackups"
 )
@@ -2,10 +2,13 @@ package k8sutil
 
 import (
 	"context"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
 )
 
 // IPodControl defines the interface that uses to create, update, and delete Pods.
@@ -64,3 +67,23 @@ func (p *PodController) GetPod(namespace, name string) (*corev1.Pod, error) {
 	}, pod)
 	return pod, err
 }
+
+// AnnouncedAddress returns the ip:port the redis node of pod announces with external access,
+// empty if the operator has not set it yet.
+func AnnouncedAddress(pod *corev1.Pod) string {
+	addr := pod.Annotations[redisv1alpha1.AnnotationAnnounceAddress]
+	if i := strings.Index(addr, "@"); i >= 0 {
+		addr = addr[:i]
+	}
+	return addr
+}
+
+// IsPodReady returns true if the Ready condition of the pod is true.
+func IsPodReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -19,6 +19,8 @@ type IServiceControl interface {
 	DeleteServiceByName(namespace, name string) error
 	// GetService get Service in a DistributedRedisCluster.
 	GetService(namespace, name string) (*corev1.Service, error)
+	// ListServiceByLabels lists the Services matching the labels.
+	ListServiceByLabels(namespace string, labels map[string]string) (*corev1.ServiceList, error)
 }
 
 type serviceController struct {
@@ -63,3 +65,10 @@ func (s *serviceController) GetService(namespace, name string) (*corev1.Service,
 	}, svc)
 	return svc, err
 }
+
+// ListServiceByLabels implement the IServiceControl.Interface.
+func (s *serviceController) ListServiceByLabels(namespace string, labels map[string]string) (*corev1.ServiceList, error) {
+	foundSvcs := &corev1.ServiceList{}
+	err := s.client.List(context.TODO(), foundSvcs, client.InNamespace(namespace), client.MatchingLabels(labels))
+	return foundSvcs, err
+}
@@ -2,6 +2,8 @@ package k8sutil
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
@@ -9,6 +11,9 @@ import (
 	kerr "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisutil"
 )
 
 func IsRequestRetryable(err error) bool {
@@ -34,3 +39,99 @@ func CreateSecret(client client.Client, secret *corev1.Secret, logger logr.Logge
 	}
 	return err
 }
+
+// GetClusterPassword returns the password of the cluster, it is empty if the cluster has no password.
+func GetClusterPassword(client client.Client, cluster *redisv1alpha1.DistributedRedisCluster) (string, error) {
+	if cluster.Spec.PasswordSecret == nil {
+		return "", nil
+	}
+	secret := &corev1.Secret{}
+	err := client.Get(context.TODO(), types.NamespacedName{
+		Name:      cluster.Spec.PasswordSecret.Name,
+		Namespace: cluster.Namespace,
+	}, secret)
+	if err != nil {
+		return "", err
+	}
+	return string(secret.Data["password"]), nil
+}
+
+// GetClusterOperatorPassword returns the password of the ACL user of the operator, it is empty if the cluster
+// has no ACL users.
+func GetClusterOperatorPassword(client client.Client, cluster *redisv1alpha1.DistributedRedisCluster) (string, error) {
+	if len(cluster.Spec.Users) == 0 {
+		return "", nil
+	}
+	return GetSecretPassword(client, cluster.Namespace, cluster.OperatorSecretName())
+}
+
+// GetClusterACLUsers returns the ACL users of the cluster with the passwords of their Secrets.
+func GetClusterACLUsers(client client.Client, cluster *redisv1alpha1.DistributedRedisCluster) ([]redisutil.ACLUser, error) {
+	var users []redisutil.ACLUser
+	for _, user := range cluster.Spec.Users {
+		password, err := GetSecretPassword(client, cluster.Namespace, user.PasswordSecret.Name)
+		if err != nil {
+			return nil, err
+		}
+		if password == "" {
+			return nil, fmt.Errorf("user %s: secret %s has no password", user.Name, user.PasswordSecret.Name)
+		}
+		users = append(users, redisutil.ACLUser{
+			Name:     user.Name,
+			Password: password,
+			Commands: user.Commands,
+			Keys:     user.Keys,
+		})
+	}
+	return users, nil
+}
+
+// GetSecretPassword returns the password key of the Secret name.
+func GetSecretPassword(client client.Client, namespace, name string) (string, error) {
+	secret := &corev1.Secret{}
+	err := client.Get(context.TODO(), types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}, secret)
+	if err != nil {
+		return "", err
+	}
+	return string(secret.Data["password"]), nil
+}
+
+// GetClusterTLSSecret returns the TLS Secret of the cluster, it is nil if the cluster has no TLS.
+func GetClusterTLSSecret(client client.Client, cluster *redisv1alpha1.DistributedRedisCluster) (*corev1.Secret, error) {
+	if cluster.Spec.TLS == nil {
+		return nil, nil
+	}
+	secret := &corev1.Secret{}
+	err := client.Get(context.TODO(), types.NamespacedName{
+		Name:      cluster.TLSSecretName(),
+		Namespace: cluster.Namespace,
+	}, secret)
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// GetClusterTLSConfig returns the TLS configuration to connect to the redis nodes of the cluster,
+// it is nil if the cluster has no TLS.
+func GetClusterTLSConfig(client client.Client, cluster *redisv1alpha1.DistributedRedisCluster) (*tls.Config, error) {
+	secret, err := GetClusterTLSSecret(client, cluster)
+	if err != nil || secret == nil {
+		return nil, err
+	}
+	return TLSConfigFromSecret(secret)
+}
+
+// TLSConfigFromSecret returns the TLS configuration verifying the redis nodes with the CA of the secret
+// and presenting its certificate as client certificate.
+func TLSConfigFromSecret(secret *corev1.Secret) (*tls.Config, error) {
+	tlsConfig, err := redisutil.NewTLSConfig(secret.Data[redisv1alpha1.TLSCAKey],
+		secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("secret %s: %v", secret.Name, err)
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,104 @@
+package osm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gomodules.xyz/stow"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+)
+
+// AOFWatermarkFile is written by the AOF shipping sidecar in the folder of its shard, it holds the
+// unix time up to which the writes of the shard are shipped and the ID of the master they come from.
+const AOFWatermarkFile = "watermark"
+
+// WriteContinuousBackupMetadata stores the cluster as a RedisClusterBackup in the folder of its
+// continuous backup, so that it can be restored from a BackupSourceSpec.Bucket.
+func WriteContinuousBackupMetadata(client client.Client, cluster *redisv1alpha1.DistributedRedisCluster) error {
+	spec := cluster.Spec.ContinuousBackup
+	backup := &redisv1alpha1.RedisClusterBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cluster.Name,
+			Namespace: cluster.Namespace,
+		},
+		Spec: redisv1alpha1.RedisClusterBackupSpec{
+			Image:            spec.Image,
+			RedisClusterName: cluster.Name,
+			Backend:          spec.Backend,
+		},
+		Status: redisv1alpha1.RedisClusterBackupStatus{
+			Phase:           redisv1alpha1.BackupPhaseSucceeded,
+			MasterSize:      cluster.Spec.MasterSize,
+			ClusterReplicas: cluster.Spec.ClusterReplicas,
+			ClusterImage:    cluster.Spec.Image,
+			Location:        cluster.ContinuousBackupLocation(),
+		},
+	}
+	return WriteBackupMetadata(client, backup)
+}
+
+// ReadAOFWatermarks returns the watermarks of the shards of the continuous backup of the cluster,
+// the watermark of a shard that has not shipped its AOF yet is nil.
+func ReadAOFWatermarks(client client.Client, cluster *redisv1alpha1.DistributedRedisCluster) ([]redisv1alpha1.ContinuousBackupShard, error) {
+	c, err := openContainer(client, cluster.Spec.ContinuousBackup.Backend, cluster.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	folder := cluster.ContinuousBackupLocation()
+	shards := make([]redisv1alpha1.ContinuousBackupShard, cluster.Spec.MasterSize)
+	for i := range shards {
+		shards[i].Index = i
+		item, err := c.Item(path.Join(folder, strconv.Itoa(i), AOFWatermarkFile))
+		if err == stow.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get the watermark of shard %d", i)
+		}
+		data, err := readItem(item)
+		if err != nil {
+			return nil, err
+		}
+		if err := parseAOFWatermark(data, &shards[i]); err != nil {
+			return nil, errors.Wrapf(err, "invalid watermark of shard %d", i)
+		}
+	}
+	return shards, nil
+}
+
+func readItem(item stow.Item) (string, error) {
+	r, err := item.Open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// parseAOFWatermark decodes the "<unix time> <node ID>" content of a watermark file into shard.
+func parseAOFWatermark(data string, shard *redisv1alpha1.ContinuousBackupShard) error {
+	fields := strings.Fields(data)
+	if len(fields) != 2 {
+		return fmt.Errorf("expected \"<unix time> <node ID>\", got %q", data)
+	}
+	sec, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return err
+	}
+	watermark := metav1.NewTime(time.Unix(sec, 0))
+	shard.Watermark = &watermark
+	shard.NodeID = fields[1]
+	return nil
+}
@@ -0,0 +1,38 @@
+package osm
+
+import (
+	"testing"
+	"time"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+)
+
+func TestParseAOFWatermark(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    time.Time
+		nodeID  string
+		wantErr bool
+	}{
+		{"valid", "1700000000 07c37dfeb235213a872192d90877d0cd55635b91\n", time.Unix(1700000000, 0), "07c37dfeb235213a872192d90877d0cd55635b91", false},
+		{"no node id", "1700000000", time.Time{}, "", true},
+		{"invalid time", "yesterday 07c37dfeb235213a872192d90877d0cd55635b91", time.Time{}, "", true},
+		{"empty", "", time.Time{}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shard := &redisv1alpha1.ContinuousBackupShard{}
+			err := parseAOFWatermark(tt.data, shard)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAOFWatermark() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !shard.Watermark.Time.Equal(tt.want) || shard.NodeID != tt.nodeID {
+				t.Errorf("parseAOFWatermark() = %v %s, want %v %s", shard.Watermark, shard.NodeID, tt.want, tt.nodeID)
+			}
+		})
+	}
+}
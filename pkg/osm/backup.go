@@ -0,0 +1,71 @@
+package osm
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+)
+
+// BackupMetadataFile is the file describing a backup, it is stored in the folder of the backup.
+const BackupMetadataFile = "backup.json"
+
+// WriteBackupMetadata stores the RedisClusterBackup in the folder of the backup, so that it can be
+// restored without the RedisClusterBackup object.
+func WriteBackupMetadata(client client.Client, backup *redisv1alpha1.RedisClusterBackup) error {
+	folder, err := backup.Location()
+	if err != nil {
+		return err
+	}
+	metadata := &redisv1alpha1.RedisClusterBackup{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: redisv1alpha1.SchemeGroupVersion.String(),
+			Kind:       redisv1alpha1.RedisClusterBackupKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backup.Name,
+			Namespace: backup.Namespace,
+		},
+		Spec:   backup.Spec,
+		Status: backup.Status,
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	c, err := openContainer(client, backup.Spec.Backend, backup.Namespace)
+	if err != nil {
+		return err
+	}
+	_, err = c.Put(path.Join(folder, BackupMetadataFile), bytes.NewReader(data), int64(len(data)), nil)
+	return err
+}
+
+// ReadBackupMetadata returns the RedisClusterBackup stored in the folder of source, it is restored
+// from source with the storage secret of namespace.
+func ReadBackupMetadata(client client.Client, source *redisv1alpha1.BucketSourceSpec, namespace string) (*redisv1alpha1.RedisClusterBackup, error) {
+	c, err := openContainer(client, source.Backend, namespace)
+	if err != nil {
+		return nil, err
+	}
+	item, err := c.Item(path.Join(source.Folder, BackupMetadataFile))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s from folder %s", BackupMetadataFile, source.Folder)
+	}
+	data, err := readItem(item)
+	if err != nil {
+		return nil, err
+	}
+	backup := &redisv1alpha1.RedisClusterBackup{}
+	if err := json.Unmarshal([]byte(data), backup); err != nil {
+		return nil, errors.Wrapf(err, "invalid %s in folder %s", BackupMetadataFile, source.Folder)
+	}
+	backup.Spec.Backend = source.Backend
+	backup.Status.Location = source.Folder
+	return backup, nil
+}
@@ -144,6 +144,51 @@ func CheckBucketAccess(client client.Client, spec api.Backend, namespace string)
 	return c.HasWriteAccess()
 }
 
+// openContainer returns the bucket of spec.
+func openContainer(client client.Client, spec api.Backend, namespace string) (stow.Container, error) {
+	cfg, err := NewOSMContext(client, spec, namespace)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := stow.Dial(cfg.Provider, cfg.Config)
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := spec.Container()
+	if err != nil {
+		return nil, err
+	}
+	return loc.Container(bucket)
+}
+
+// DeleteFolder removes all the items under folder from the bucket of spec.
+func DeleteFolder(client client.Client, spec api.Backend, namespace, folder string) error {
+	c, err := openContainer(client, spec, namespace)
+	if err != nil {
+		return err
+	}
+
+	// collect the items first, removing them while paging may skip items.
+	var ids []string
+	prefix := strings.TrimSuffix(folder, "/") + "/"
+	err = stow.Walk(c, prefix, 100, func(item stow.Item, err error) error {
+		if err != nil {
+			return err
+		}
+		ids = append(ids, item.ID())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := c.RemoveItem(id); err != nil {
+			return errors.Wrapf(err, "failed to remove %s", id)
+		}
+	}
+	return nil
+}
+
 func NewOSMContext(client client.Client, spec api.Backend, namespace string) (*otx.Context, error) {
 	config := make(map[string][]byte)
 
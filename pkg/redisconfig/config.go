@@ -0,0 +1,55 @@
+// Package redisconfig classifies and renders the parameters of the redis.conf of the redis nodes.
+package redisconfig
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ConfigFile is the name of the redis.conf rendered in the configmap of the cluster.
+const ConfigFile = "redis.conf"
+
+// IsRestartRequired returns true if the parameter is only applied by a restart of the node.
+func IsRestartRequired(key string) bool {
+	return params[strings.ToLower(key)].restart
+}
+
+// Split returns the parameters of config that are applied at runtime, and the ones applied by a restart.
+func Split(config map[string]string) (hot, restart map[string]string) {
+	hot, restart = map[string]string{}, map[string]string{}
+	for key, value := range config {
+		if IsRestartRequired(key) {
+			restart[key] = value
+		} else {
+			hot[key] = value
+		}
+	}
+	return hot, restart
+}
+
+// Render returns the content of a redis.conf setting the parameters of config, sorted by key.
+func Render(config map[string]string) string {
+	keys := make([]string, 0, len(config))
+	for key := range config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	b := strings.Builder{}
+	for _, key := range keys {
+		value := config[key]
+		if value == "" {
+			value = `""`
+		}
+		fmt.Fprintf(&b, "%s %s\n", key, value)
+	}
+	return b.String()
+}
+
+// RestartHash returns a hash of the restart-required parameters of config, the nodes are restarted
+// when it changes.
+func RestartHash(config map[string]string) string {
+	_, restart := Split(config)
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(Render(restart))))[:16]
+}
@@ -0,0 +1,43 @@
+package redisconfig
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	config := map[string]string{
+		"maxmemory":  "1gb",
+		"databases":  "32",
+		"IO-Threads": "4",
+	}
+	hot, restart := Split(config)
+	if want := map[string]string{"maxmemory": "1gb"}; !reflect.DeepEqual(hot, want) {
+		t.Errorf("Split() hot = %v, want %v", hot, want)
+	}
+	if want := map[string]string{"databases": "32", "IO-Threads": "4"}; !reflect.DeepEqual(restart, want) {
+		t.Errorf("Split() restart = %v, want %v", restart, want)
+	}
+}
+
+func TestRender(t *testing.T) {
+	config := map[string]string{
+		"save":                   "900 1 300 10",
+		"notify-keyspace-events": "",
+		"databases":              "32",
+	}
+	want := "databases 32\nnotify-keyspace-events \"\"\nsave 900 1 300 10\n"
+	if got := Render(config); got != want {
+		t.Errorf("Render() = %q, want %q", got, want)
+	}
+}
+
+func TestRestartHash(t *testing.T) {
+	base := RestartHash(map[string]string{"databases": "32", "maxmemory": "1gb"})
+	if got := RestartHash(map[string]string{"databases": "32", "maxmemory": "2gb"}); got != base {
+		t.Errorf("RestartHash() changed with a hot parameter: %s != %s", got, base)
+	}
+	if got := RestartHash(map[string]string{"databases": "16", "maxmemory": "1gb"}); got == base {
+		t.Errorf("RestartHash() did not change with a restart-required parameter")
+	}
+}
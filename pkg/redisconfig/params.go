@@ -0,0 +1,326 @@
+package redisconfig
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LatestVersion is the latest major version of Redis known by the parameter table, the parameters of an
+// image whose version cannot be detected are validated against it.
+const LatestVersion = 7
+
+type kind int
+
+const (
+	kindString kind = iota
+	kindBool
+	kindInt
+	kindMemory
+	kindEnum
+)
+
+// param describes a parameter of the redis.conf.
+type param struct {
+	kind kind
+	// min and max bound the value of a kindInt parameter.
+	min, max int64
+	// values are the values of a kindEnum parameter.
+	values []string
+	// since is the major version the parameter was introduced in, until the last one it exists in.
+	since, until int
+	// restart is set for the parameters CONFIG SET cannot change.
+	restart bool
+	// forbidden is set for the parameters managed by the operator.
+	forbidden bool
+}
+
+func (p param) existsIn(major int) bool {
+	return major >= p.since && (p.until == 0 || major <= p.until)
+}
+
+var (
+	boolean  = param{kind: kindBool}
+	str      = param{kind: kindString}
+	memory   = param{kind: kindMemory}
+	positive = param{kind: kindInt, min: 0, max: math.MaxInt64}
+	managed  = param{kind: kindString, forbidden: true}
+)
+
+func since(major int, p param) param {
+	p.since = major
+	return p
+}
+
+func until(major int, p param) param {
+	p.until = major
+	return p
+}
+
+func restart(p param) param {
+	p.restart = true
+	return p
+}
+
+func intRange(min, max int64) param {
+	return param{kind: kindInt, min: min, max: max}
+}
+
+func enum(values ...string) param {
+	return param{kind: kindEnum, values: values}
+}
+
+// params are the parameters of the redis.conf, by lower case name.
+var params = map[string]param{
+	// managed by the operator
+	"bind":                            managed,
+	"cluster-announce-bus-port":       since(4, managed),
+	"cluster-announce-hostname":       since(7, managed),
+	"cluster-announce-ip":             since(4, managed),
+	"cluster-announce-port":           since(4, managed),
+	"cluster-config-file":             restart(managed),
+	"cluster-enabled":                 restart(managed),
+	"cluster-port":                    since(7, restart(managed)),
+	"cluster-preferred-endpoint-type": since(7, managed),
+	"daemonize":                       restart(managed),
+	"dir":                             managed,
+	"include":                         restart(managed),
+	"masterauth":                      managed,
+	"port":                            managed,
+	"replicaof":                       since(5, managed),
+	"requirepass":                     managed,
+	"slaveof":                         managed,
+
+	// general
+	"aclfile":                    since(6, restart(str)),
+	"acllog-max-len":             since(6, positive),
+	"always-show-logo":           since(4, restart(boolean)),
+	"busy-reply-threshold":       since(7, positive),
+	"crash-log-enabled":          since(6, boolean),
+	"crash-memcheck-enabled":     since(6, boolean),
+	"databases":                  restart(intRange(1, math.MaxInt32)),
+	"disable-thp":                since(6, restart(boolean)),
+	"dynamic-hz":                 since(5, boolean),
+	"enable-debug-command":       since(7, restart(enum("no", "yes", "local"))),
+	"enable-module-command":      since(7, restart(enum("no", "yes", "local"))),
+	"enable-protected-configs":   since(7, restart(enum("no", "yes", "local"))),
+	"hz":                         intRange(1, 500),
+	"io-threads":                 since(6, restart(intRange(1, 128))),
+	"io-threads-do-reads":        since(6, restart(boolean)),
+	"jemalloc-bg-thread":         since(6, boolean),
+	"latency-monitor-threshold":  positive,
+	"latency-tracking":           since(7, boolean),
+	"loadmodule":                 since(4, restart(str)),
+	"logfile":                    restart(str),
+	"loglevel":                   enum("debug", "verbose", "notice", "warning"),
+	"lua-replicate-commands":     since(5, until(6, boolean)),
+	"lua-time-limit":             positive,
+	"maxclients":                 intRange(1, math.MaxInt32),
+	"notify-keyspace-events":     str,
+	"oom-score-adj":              since(6, enum("no", "yes", "relative", "absolute")),
+	"oom-score-adj-values":       since(6, str),
+	"pidfile":                    restart(str),
+	"propagation-error-behavior": since(7, enum("ignore", "panic", "panic-on-replicas")),
+	"protected-mode":             boolean,
+	"sanitize-dump-payload":      since(6, enum("no", "yes", "clients")),
+	"set-proc-title":             since(6, restart(boolean)),
+	"shutdown-on-sigint":         since(7, str),
+	"shutdown-on-sigterm":        since(7, str),
+	"shutdown-timeout":           since(7, positive),
+	"slowlog-log-slower-than":    intRange(-1, math.MaxInt64),
+	"slowlog-max-len":            positive,
+	"supervised":                 restart(enum("no", "upstart", "systemd", "auto")),
+	"syslog-enabled":             restart(boolean),
+	"syslog-facility":            restart(enum("user", "local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7")),
+	"syslog-ident":               restart(str),
+	"tcp-backlog":                restart(positive),
+	"tcp-keepalive":              positive,
+	"timeout":                    positive,
+	"tracking-table-max-keys":    since(6, positive),
+	"unixsocket":                 restart(str),
+	"unixsocketperm":             restart(str),
+
+	// persistence
+	"aof-load-truncated":            boolean,
+	"aof-rewrite-incremental-fsync": boolean,
+	"aof-timestamp-enabled":         since(7, boolean),
+	"aof-use-rdb-preamble":          since(4, boolean),
+	"appenddirname":                 since(7, restart(str)),
+	"appendfilename":                restart(str),
+	"appendfsync":                   enum("always", "everysec", "no"),
+	"appendonly":                    boolean,
+	"auto-aof-rewrite-min-size":     memory,
+	"auto-aof-rewrite-percentage":   positive,
+	"dbfilename":                    str,
+	"no-appendfsync-on-rewrite":     boolean,
+	"rdb-del-sync-files":            since(6, boolean),
+	"rdb-save-incremental-fsync":    since(5, boolean),
+	"rdbchecksum":                   boolean,
+	"rdbcompression":                boolean,
+	"save":                          str,
+	"stop-writes-on-bgsave-error":   boolean,
+
+	// memory
+	"active-defrag-cycle-max":       since(4, intRange(1, 99)),
+	"active-defrag-cycle-min":       since(4, intRange(1, 99)),
+	"active-defrag-ignore-bytes":    since(4, memory),
+	"active-defrag-max-scan-fields": since(5, positive),
+	"active-defrag-threshold-lower": since(4, intRange(0, 1000)),
+	"active-defrag-threshold-upper": since(4, intRange(0, 1000)),
+	"active-expire-effort":          since(6, intRange(1, 10)),
+	"activedefrag":                  since(4, boolean),
+	"activerehashing":               boolean,
+	"client-output-buffer-limit":    str,
+	"client-query-buffer-limit":     since(4, memory),
+	"lazyfree-lazy-eviction":        since(4, boolean),
+	"lazyfree-lazy-expire":          since(4, boolean),
+	"lazyfree-lazy-server-del":      since(4, boolean),
+	"lazyfree-lazy-user-del":        since(6, boolean),
+	"lazyfree-lazy-user-flush":      since(6, boolean),
+	"lfu-decay-time":                since(4, positive),
+	"lfu-log-factor":                since(4, positive),
+	"maxmemory":                     memory,
+	"maxmemory-clients":             since(7, str),
+	"maxmemory-eviction-tenacity":   since(6, intRange(0, 100)),
+	"maxmemory-policy":              enum("volatile-lru", "allkeys-lru", "volatile-lfu", "allkeys-lfu", "volatile-random", "allkeys-random", "volatile-ttl", "noeviction"),
+	"maxmemory-samples":             intRange(1, 64),
+	"proto-max-bulk-len":            since(4, memory),
+
+	// data structures
+	"hash-max-listpack-entries": since(7, positive),
+	"hash-max-listpack-value":   since(7, positive),
+	"hash-max-ziplist-entries":  positive,
+	"hash-max-ziplist-value":    positive,
+	"hll-sparse-max-bytes":      positive,
+	"list-compress-depth":       positive,
+	"list-max-listpack-size":    since(7, intRange(math.MinInt32, math.MaxInt32)),
+	"list-max-ziplist-size":     intRange(math.MinInt32, math.MaxInt32),
+	"set-max-intset-entries":    positive,
+	"stream-node-max-bytes":     since(5, memory),
+	"stream-node-max-entries":   since(5, positive),
+	"zset-max-listpack-entries": since(7, positive),
+	"zset-max-listpack-value":   since(7, positive),
+	"zset-max-ziplist-entries":  positive,
+	"zset-max-ziplist-value":    positive,
+
+	// replication
+	"min-replicas-max-lag":            since(5, positive),
+	"min-replicas-to-write":           since(5, positive),
+	"min-slaves-max-lag":              positive,
+	"min-slaves-to-write":             positive,
+	"repl-backlog-size":               memory,
+	"repl-backlog-ttl":                positive,
+	"repl-disable-tcp-nodelay":        boolean,
+	"repl-diskless-load":              since(6, enum("disabled", "on-empty-db", "swapdb")),
+	"repl-diskless-sync":              boolean,
+	"repl-diskless-sync-delay":        positive,
+	"repl-diskless-sync-max-replicas": since(7, positive),
+	"repl-ping-replica-period":        since(5, intRange(1, math.MaxInt32)),
+	"repl-ping-slave-period":          intRange(1, math.MaxInt32),
+	"repl-timeout":                    intRange(1, math.MaxInt32),
+	"replica-announce-ip":             since(5, str),
+	"replica-ignore-maxmemory":        since(5, boolean),
+	"replica-lazy-flush":              since(5, boolean),
+	"replica-priority":                since(5, positive),
+	"replica-read-only":               since(5, boolean),
+	"replica-serve-stale-data":        since(5, boolean),
+	"slave-lazy-flush":                since(4, boolean),
+	"slave-priority":                  positive,
+	"slave-read-only":                 boolean,
+	"slave-serve-stale-data":          boolean,
+
+	// cluster
+	"cluster-allow-pubsubshard-when-down": since(7, boolean),
+	"cluster-allow-reads-when-down":       since(6, boolean),
+	"cluster-allow-replica-migration":     since(6, boolean),
+	"cluster-link-sendbuf-limit":          since(7, memory),
+	"cluster-migration-barrier":           positive,
+	"cluster-node-timeout":                intRange(1, math.MaxInt64),
+	"cluster-replica-no-failover":         since(5, boolean),
+	"cluster-replica-validity-factor":     since(5, positive),
+	"cluster-require-full-coverage":       boolean,
+	"cluster-slave-no-failover":           since(4, boolean),
+	"cluster-slave-validity-factor":       positive,
+}
+
+var (
+	versionPattern = regexp.MustCompile(`^v?(\d+)`)
+	memoryPattern  = regexp.MustCompile(`^(?i)\d+(b|k|kb|m|mb|g|gb)?$`)
+)
+
+// MajorVersion returns the major version of Redis in the tag of the image, e.g. 5 for redis:5.0.4-alpine,
+// it returns 0 if the tag has no version.
+func MajorVersion(image string) int {
+	if i := strings.Index(image, "@"); i >= 0 {
+		image = image[:i]
+	}
+	i := strings.LastIndex(image, ":")
+	if i < 0 || i < strings.LastIndex(image, "/") {
+		return 0
+	}
+	match := versionPattern.FindStringSubmatch(image[i+1:])
+	if match == nil {
+		return 0
+	}
+	major, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0
+	}
+	return major
+}
+
+// Validate checks the parameters of config against the parameters of the major version of Redis, the
+// latest version is assumed if it is 0. The parameters of a version newer than LatestVersion are
+// unknown, only the known ones are checked.
+func Validate(config map[string]string, major int) error {
+	if major == 0 {
+		major = LatestVersion
+	}
+	for key, value := range config {
+		p, ok := params[strings.ToLower(key)]
+		if !ok && major > LatestVersion {
+			continue
+		}
+		if !ok || !p.existsIn(major) {
+			return fmt.Errorf("config [%s] is not a parameter of redis %d", key, major)
+		}
+		if p.forbidden {
+			return fmt.Errorf("config [%s] is managed by the operator", key)
+		}
+		if err := p.validate(value); err != nil {
+			return fmt.Errorf("config [%s] %v", key, err)
+		}
+	}
+	return nil
+}
+
+func (p param) validate(value string) error {
+	switch p.kind {
+	case kindBool:
+		if value != "yes" && value != "no" {
+			return fmt.Errorf("must be yes or no, got %q", value)
+		}
+	case kindInt:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("must be an integer, got %q", value)
+		}
+		if i < p.min || i > p.max {
+			return fmt.Errorf("must be in [%d, %d], got %d", p.min, p.max, i)
+		}
+	case kindMemory:
+		if !memoryPattern.MatchString(value) {
+			return fmt.Errorf("must be a number of bytes, e.g. 100mb, got %q", value)
+		}
+	case kindEnum:
+		for _, v := range p.values {
+			if strings.EqualFold(value, v) {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s, got %q", strings.Join(p.values, ", "), value)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package redisconfig
+
+import "testing"
+
+func TestMajorVersion(t *testing.T) {
+	tests := []struct {
+		image string
+		want  int
+	}{
+		{"redis:5.0.4-alpine", 5},
+		{"uhub.service.ucloud.cn/operator/redis:5.0.4-alpine", 5},
+		{"registry:5000/redis:v6.2", 6},
+		{"redis:7@sha256:0123456789abcdef", 7},
+		{"registry:5000/redis", 0},
+		{"redis:latest", 0},
+		{"redis", 0},
+	}
+	for _, tt := range tests {
+		if got := MajorVersion(tt.image); got != tt.want {
+			t.Errorf("MajorVersion(%q) = %d, want %d", tt.image, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	example := map[string]string{
+		"activerehashing":             "yes",
+		"appendfsync":                 "everysec",
+		"appendonly":                  "yes",
+		"hash-max-ziplist-entries":    "512",
+		"hash-max-ziplist-value":      "64",
+		"hll-sparse-max-bytes":        "3000",
+		"list-compress-depth":         "0",
+		"maxmemory-policy":            "noeviction",
+		"maxmemory-samples":           "5",
+		"no-appendfsync-on-rewrite":   "no",
+		"notify-keyspace-events":      "",
+		"set-max-intset-entries":      "512",
+		"slowlog-log-slower-than":     "10000",
+		"slowlog-max-len":             "128",
+		"stop-writes-on-bgsave-error": "yes",
+		"tcp-keepalive":               "0",
+		"timeout":                     "0",
+		"zset-max-ziplist-entries":    "128",
+		"zset-max-ziplist-value":      "64",
+	}
+	tests := []struct {
+		name    string
+		config  map[string]string
+		major   int
+		wantErr bool
+	}{
+		{"example", example, 5, false},
+		{"example with unknown version", example, 0, false},
+		{"memory", map[string]string{"maxmemory": "1GB", "repl-backlog-size": "1048576"}, 5, false},
+		{"invalid memory", map[string]string{"maxmemory": "1 gb"}, 5, true},
+		{"invalid bool", map[string]string{"appendonly": "true"}, 5, true},
+		{"invalid int", map[string]string{"databases": "many"}, 5, true},
+		{"int out of range", map[string]string{"hz": "1000"}, 5, true},
+		{"invalid enum", map[string]string{"maxmemory-policy": "lru"}, 5, true},
+		{"forbidden", map[string]string{"dir": "/tmp"}, 5, true},
+		{"forbidden upper case", map[string]string{"Port": "6380"}, 5, true},
+		{"unknown", map[string]string{"max-memory": "1gb"}, 5, true},
+		{"newer parameter", map[string]string{"io-threads": "4"}, 5, true},
+		{"newer parameter in its version", map[string]string{"io-threads": "4"}, 6, false},
+		{"removed parameter", map[string]string{"lua-replicate-commands": "yes"}, 7, true},
+		{"unknown parameter of a newer version", map[string]string{"new-parameter": "yes"}, LatestVersion + 1, false},
+		{"forbidden in a newer version", map[string]string{"cluster-enabled": "no"}, LatestVersion + 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.config, tt.major); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
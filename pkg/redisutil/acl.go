@@ -0,0 +1,148 @@
+package redisutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mediocregopher/radix.v2/redis"
+)
+
+// ACLUser is an ACL user of the redis nodes, Redis 6 or later.
+type ACLUser struct {
+	Name     string
+	Password string
+	// Commands are the command rules, e.g. +@read or -flushall.
+	Commands []string
+	// Keys are the key patterns without the ~ prefix.
+	Keys []string
+}
+
+// Rules returns the arguments of ACL SETUSER replacing the user with u.
+func (u *ACLUser) Rules() []interface{} {
+	rules := []interface{}{u.Name, "reset", "on", ">" + u.Password}
+	for _, key := range u.Keys {
+		rules = append(rules, "~"+key)
+	}
+	for _, cmd := range u.Commands {
+		rules = append(rules, cmd)
+	}
+	return rules
+}
+
+// ACLUserInfo is a user as reported by ACL GETUSER.
+type ACLUserInfo struct {
+	Flags     []string
+	Passwords []string
+	Commands  string
+	Keys      []string
+}
+
+// GetACLUser returns the user name of the node c is connected to, or nil if it does not exist.
+func GetACLUser(c IClient, name string) (*ACLUserInfo, error) {
+	resp := c.Cmd("ACL", "GETUSER", name)
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	if resp.IsType(redis.Nil) {
+		return nil, nil
+	}
+	return DecodeACLUser(resp)
+}
+
+// DecodeACLUser decodes the reply of ACL GETUSER. The keys are an array of patterns before Redis 7 and a
+// string of rules since.
+func DecodeACLUser(resp *redis.Resp) (*ACLUserInfo, error) {
+	fields, err := resp.Array()
+	if err != nil {
+		return nil, fmt.Errorf("wrong format from ACL GETUSER: %v", err)
+	}
+	info := &ACLUserInfo{}
+	for i := 0; i+1 < len(fields); i += 2 {
+		name, err := fields[i].Str()
+		if err != nil {
+			return nil, fmt.Errorf("wrong format from ACL GETUSER: %v", err)
+		}
+		value := fields[i+1]
+		switch name {
+		case "flags":
+			info.Flags, err = value.List()
+		case "passwords":
+			info.Passwords, err = value.List()
+		case "commands":
+			info.Commands, err = value.Str()
+		case "keys":
+			if value.IsType(redis.Array) {
+				info.Keys, err = value.List()
+			} else {
+				var keys string
+				keys, err = value.Str()
+				for _, rule := range strings.Fields(keys) {
+					info.Keys = append(info.Keys, strings.TrimPrefix(rule, "~"))
+				}
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("wrong format from ACL GETUSER %s: %v", name, err)
+		}
+	}
+	return info, nil
+}
+
+// Matches returns true if the user is enabled with the password, the key patterns and the command rules of u.
+// Redis 6 describes the commands from their bitmap rather than with the rules they were set with, a user
+// whose rules it rewrites is then set again at each check, which is harmless.
+func (info *ACLUserInfo) Matches(u *ACLUser) bool {
+	if !containsString(info.Flags, "on") {
+		return false
+	}
+	if len(info.Passwords) != 1 || info.Passwords[0] != passwordHash(u.Password) {
+		return false
+	}
+	keys := append([]string{}, info.Keys...)
+	if containsString(info.Flags, "allkeys") && !containsString(keys, "*") {
+		keys = append(keys, "*")
+	}
+	if !sameStrings(keys, u.Keys) {
+		return false
+	}
+	commands := strings.Fields(strings.ToLower(info.Commands))
+	for _, cmd := range u.Commands {
+		if !containsString(commands, strings.ToLower(cmd)) {
+			return false
+		}
+	}
+	return true
+}
+
+func passwordHash(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package redisutil
+
+import (
+	"testing"
+
+	"github.com/mediocregopher/radix.v2/redis"
+)
+
+func TestDecodeACLUser(t *testing.T) {
+	user := &ACLUser{
+		Name:     "app",
+		Password: "secret",
+		Commands: []string{"+@read", "-flushall"},
+		Keys:     []string{"app:*", "cache:*"},
+	}
+	tests := []struct {
+		name  string
+		reply []interface{}
+		want  bool
+	}{
+		{
+			name: "redis 6",
+			reply: []interface{}{
+				"flags", []interface{}{"on"},
+				"passwords", []interface{}{passwordHash("secret")},
+				"commands", "-@all +@read -flushall",
+				"keys", []interface{}{"cache:*", "app:*"},
+			},
+			want: true,
+		},
+		{
+			name: "redis 7",
+			reply: []interface{}{
+				"flags", []interface{}{"on"},
+				"passwords", []interface{}{passwordHash("secret")},
+				"commands", "-@all +@read -flushall",
+				"keys", "~app:* ~cache:*",
+				"channels", "",
+			},
+			want: true,
+		},
+		{
+			name: "disabled",
+			reply: []interface{}{
+				"flags", []interface{}{"off"},
+				"passwords", []interface{}{passwordHash("secret")},
+				"commands", "-@all +@read -flushall",
+				"keys", []interface{}{"app:*", "cache:*"},
+			},
+			want: false,
+		},
+		{
+			name: "other password",
+			reply: []interface{}{
+				"flags", []interface{}{"on"},
+				"passwords", []interface{}{passwordHash("old")},
+				"commands", "-@all +@read -flushall",
+				"keys", []interface{}{"app:*", "cache:*"},
+			},
+			want: false,
+		},
+		{
+			name: "missing key pattern",
+			reply: []interface{}{
+				"flags", []interface{}{"on"},
+				"passwords", []interface{}{passwordHash("secret")},
+				"commands", "-@all +@read -flushall",
+				"keys", []interface{}{"app:*"},
+			},
+			want: false,
+		},
+		{
+			name: "missing command",
+			reply: []interface{}{
+				"flags", []interface{}{"on"},
+				"passwords", []interface{}{passwordHash("secret")},
+				"commands", "-@all +@read",
+				"keys", []interface{}{"app:*", "cache:*"},
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := DecodeACLUser(redis.NewResp(tt.reply))
+			if err != nil {
+				t.Fatalf("DecodeACLUser() error = %v", err)
+			}
+			if got := info.Matches(user); got != tt.want {
+				t.Errorf("Matches() = %v, want %v, info %+v", got, tt.want, info)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package redisutil
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"regexp"
@@ -42,8 +43,15 @@ type IAdmin interface {
 	ClusterManagerNodeIsEmpty() (bool, error)
 	// SetConfigEpoch Assign a different config epoch to each node
 	SetConfigEpoch() error
-	// SetConfigIfNeed set redis config
+	// SetConfigIfNeed sets the runtime parameters that differ on the nodes and rewrites their config file
 	SetConfigIfNeed(newConfig map[string]string) error
+	// SetACLUsersIfNeed sets the ACL users that differ on the nodes and deletes the removed ones
+	SetACLUsersIfNeed(users []ACLUser, removed []string) error
+	// SetPasswordsIfNeed makes the default user of the nodes accept the passwords and the nodes
+	// authenticate to their master with the first one
+	SetPasswordsIfNeed(passwords []string) error
+	// SetNodeConfigIfNeed sets the runtime parameters that differ on the node addr, without rewriting its config file
+	SetNodeConfigIfNeed(addr string, config map[string]string) error
 	// GetAllConfig get redis config by CONFIG GET *
 	GetAllConfig(c IClient, addr string) (map[string]string, error)
 	// AttachNodeToCluster command use to connect a Node to the cluster
@@ -53,6 +61,8 @@ type IAdmin interface {
 	AttachSlaveToMaster(slave *Node, masterID string) error
 	// DetachSlave dettach a slave to its master
 	DetachSlave(slave *Node) error
+	// FailoverSlave promotes the slave to master with a manual failover coordinated with its master
+	FailoverSlave(slave *Node) error
 	// ForgetNode execute the Redis command to force the cluster to forgot the the Node
 	ForgetNode(id string) error
 	// SetSlots exec the redis command to set slots in a pipeline, provide
@@ -78,14 +88,21 @@ type AdminOptions struct {
 	ConnectionTimeout  time.Duration
 	ClientName         string
 	RenameCommandsFile string
-	Password           string
+	// User is the ACL user of the connections, the default user if empty
+	User     string
+	Password string
+	// TLSConfig enables TLS connections to the nodes when set
+	TLSConfig *tls.Config
+	// AnnouncedAddrs maps the ip:port announced by the nodes with external access to their ip:port in the cluster
+	AnnouncedAddrs map[string]string
 }
 
 // Admin wraps redis cluster admin logic
 type Admin struct {
-	hashMaxSlots Slot
-	cnx          IAdminConnections
-	log          logr.Logger
+	hashMaxSlots   Slot
+	cnx            IAdminConnections
+	announcedAddrs map[string]string
+	log            logr.Logger
 }
 
 // NewAdmin returns new AdminInterface instance
@@ -95,6 +112,9 @@ func NewAdmin(addrs []string, options *AdminOptions, log logr.Logger) IAdmin {
 		hashMaxSlots: DefaultHashMaxSlots,
 		log:          log.WithName("redis_util"),
 	}
+	if options != nil {
+		a.announcedAddrs = options.AnnouncedAddrs
+	}
 
 	// perform initial connections
 	a.cnx = NewAdminConnections(addrs, options, log)
@@ -157,6 +177,7 @@ func (a *Admin) getInfos(c IClient, addr string) (*NodeInfos, error) {
 	}
 
 	nodeInfos := DecodeNodeInfos(&raw, addr, a.log)
+	TranslateAnnouncedAddrs(nodeInfos, a.announcedAddrs)
 
 	return nodeInfos, nil
 }
@@ -331,7 +352,8 @@ func (a *Admin) GetAllConfig(c IClient, addr string) (map[string]string, error)
 	return raw, nil
 }
 
-// SetConfigIfNeed set redis config
+// SetConfigIfNeed sets the runtime parameters that differ on the nodes with CONFIG SET, then persists
+// them with CONFIG REWRITE
 func (a *Admin) SetConfigIfNeed(newConfig map[string]string) error {
 	for addr, c := range a.Connections().GetAll() {
 		oldConfig, err := a.GetAllConfig(c, addr)
@@ -339,6 +361,7 @@ func (a *Admin) SetConfigIfNeed(newConfig map[string]string) error {
 			return err
 		}
 
+		changed := false
 		for key, value := range newConfig {
 			if value != oldConfig[key] {
 				a.log.V(3).Info("CONFIG SET", key, value)
@@ -346,6 +369,108 @@ func (a *Admin) SetConfigIfNeed(newConfig map[string]string) error {
 				if err := a.Connections().ValidateResp(resp, addr, "unable to retrieve config"); err != nil {
 					return err
 				}
+				changed = true
+			}
+		}
+		if changed {
+			// the nodes started before the redis.conf was rendered run without a config file,
+			// they get the parameters from the configmap once restarted.
+			resp := c.Cmd("CONFIG", "REWRITE")
+			if err := a.Connections().ValidateResp(resp, addr, "unable to rewrite config"); err != nil {
+				a.log.Info("CONFIG REWRITE failed", "addr", addr, "err", err)
+			}
+		}
+	}
+	return nil
+}
+
+// SetPasswordsIfNeed makes the default user of the nodes accept the passwords, and only them, with ACL SETUSER
+// and sets the masterauth of the nodes to the first password. Before Redis 6 a node has a single password,
+// its requirepass is set to the first password. The connections are not closed, the clients already
+// authenticated stay so.
+func (a *Admin) SetPasswordsIfNeed(passwords []string) error {
+	for addr, c := range a.Connections().GetAll() {
+		major, err := GetMajorVersion(c)
+		if err != nil {
+			return fmt.Errorf("unable to get the version of node %s: %v", addr, err)
+		}
+		if major >= 6 {
+			user, err := GetACLUser(c, "default")
+			if err != nil {
+				return fmt.Errorf("unable to get the default user on node %s: %v", addr, err)
+			}
+			if user == nil || !samePasswords(user.Passwords, passwords) {
+				a.log.Info("ACL SETUSER default", "addr", addr, "passwords", len(passwords))
+				rules := []interface{}{"SETUSER", "default", "on", "resetpass"}
+				for _, password := range passwords {
+					rules = append(rules, ">"+password)
+				}
+				resp := c.Cmd("ACL", rules...)
+				if err := a.Connections().ValidateResp(resp, addr, "unable to set the passwords"); err != nil {
+					return err
+				}
+			}
+		} else if err := a.setConfigIfNeed(c, addr, "requirepass", passwords[0]); err != nil {
+			return err
+		}
+		if err := a.setConfigIfNeed(c, addr, "masterauth", passwords[0]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetNodeConfigIfNeed sets the parameters that differ on the node addr with CONFIG SET, without CONFIG REWRITE.
+func (a *Admin) SetNodeConfigIfNeed(addr string, config map[string]string) error {
+	c, err := a.Connections().Get(addr)
+	if err != nil {
+		return err
+	}
+	for key, value := range config {
+		if err := a.setConfigIfNeed(c, addr, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setConfigIfNeed sets a parameter of a node with CONFIG SET if it differs, without CONFIG REWRITE.
+func (a *Admin) setConfigIfNeed(c IClient, addr, key, value string) error {
+	current, err := c.Cmd("CONFIG", "GET", key).List()
+	if err != nil {
+		return fmt.Errorf("wrong format from CONFIG GET %s: %v", key, err)
+	}
+	if len(current) == 2 && current[1] == value {
+		return nil
+	}
+	a.log.Info("CONFIG SET", "addr", addr, "key", key)
+	resp := c.Cmd("CONFIG", "SET", key, value)
+	return a.Connections().ValidateResp(resp, addr, "unable to set "+key)
+}
+
+// SetACLUsersIfNeed sets the users that do not match on the nodes with ACL SETUSER and deletes the removed
+// users with ACL DELUSER. The users are not persisted by the nodes, they are set again after a restart.
+func (a *Admin) SetACLUsersIfNeed(users []ACLUser, removed []string) error {
+	for addr, c := range a.Connections().GetAll() {
+		for i := range users {
+			user := &users[i]
+			info, err := GetACLUser(c, user.Name)
+			if err != nil {
+				return fmt.Errorf("unable to get user %s on node %s: %v", user.Name, addr, err)
+			}
+			if info != nil && info.Matches(user) {
+				continue
+			}
+			a.log.Info("ACL SETUSER", "addr", addr, "user", user.Name)
+			resp := c.Cmd("ACL", append([]interface{}{"SETUSER"}, user.Rules()...)...)
+			if err := a.Connections().ValidateResp(resp, addr, "unable to set user "+user.Name); err != nil {
+				return err
+			}
+		}
+		for _, name := range removed {
+			resp := c.Cmd("ACL", "DELUSER", name)
+			if err := a.Connections().ValidateResp(resp, addr, "unable to delete user "+name); err != nil {
+				return err
 			}
 		}
 	}
@@ -502,6 +627,18 @@ func (a *Admin) DetachSlave(slave *Node) error {
 	return nil
 }
 
+// FailoverSlave runs CLUSTER FAILOVER on the slave, the master stops accepting writes until the slave has
+// caught up its replication stream then the slave is promoted, the failover completes asynchronously.
+func (a *Admin) FailoverSlave(slave *Node) error {
+	c, err := a.Connections().Get(slave.IPPort())
+	if err != nil {
+		return err
+	}
+
+	resp := c.Cmd("CLUSTER", "FAILOVER")
+	return a.Connections().ValidateResp(resp, slave.IPPort(), "unable to run command CLUSTER FAILOVER")
+}
+
 // FlushAndReset flush the cluster and reset the cluster configuration of the node. Commands are piped, to ensure no items arrived between flush and reset
 func (a *Admin) FlushAndReset(addr string, mode string) error {
 	c, err := a.Connections().Get(addr)
@@ -0,0 +1,29 @@
+package redisutil
+
+import "net"
+
+// TranslateAnnouncedAddrs replaces the ip:port announced by the nodes of infos with their ip:port in the
+// cluster, so the nodes with external access are identified by the address of their pod. The nodes announcing
+// an address missing from addrs are left as is.
+func TranslateAnnouncedAddrs(infos *NodeInfos, addrs map[string]string) {
+	if infos == nil || len(addrs) == 0 {
+		return
+	}
+	translate := func(node *Node) {
+		if node == nil {
+			return
+		}
+		addr, ok := addrs[node.IPPort()]
+		if !ok {
+			return
+		}
+		if ip, port, err := net.SplitHostPort(addr); err == nil {
+			node.IP = ip
+			node.Port = port
+		}
+	}
+	translate(infos.Node)
+	for _, friend := range infos.Friends {
+		translate(friend)
+	}
+}
@@ -0,0 +1,25 @@
+package redisutil
+
+import "testing"
+
+func TestTranslateAnnouncedAddrs(t *testing.T) {
+	infos := &NodeInfos{
+		Node: &Node{ID: "a", IP: "35.1.1.1", Port: "6379"},
+		Friends: Nodes{
+			&Node{ID: "b", IP: "192.168.0.10", Port: "31001"},
+			&Node{ID: "c", IP: "10.0.0.3", Port: "6379"},
+		},
+	}
+	addrs := map[string]string{
+		"35.1.1.1:6379":      "10.0.0.1:6379",
+		"192.168.0.10:31001": "10.0.0.2:6379",
+	}
+	TranslateAnnouncedAddrs(infos, addrs)
+
+	want := map[string]string{"a": "10.0.0.1:6379", "b": "10.0.0.2:6379", "c": "10.0.0.3:6379"}
+	for _, node := range append(Nodes{infos.Node}, infos.Friends...) {
+		if got := node.IPPort(); got != want[node.ID] {
+			t.Errorf("node %s address = %s, want %s", node.ID, got, want[node.ID])
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package redisutil
 
 import (
+	"crypto/tls"
+	"net"
 	"strings"
 	"time"
 
@@ -48,21 +50,55 @@ type Client struct {
 
 // NewClient build a client connection and connect to a redis address
 func NewClient(addr, password string, cnxTimeout time.Duration, commandsMapping map[string]string) (IClient, error) {
+	return NewTLSClient(addr, password, cnxTimeout, commandsMapping, nil)
+}
+
+// NewTLSClient build a client connection and connect to a redis address over TLS,
+// a nil tlsConfig falls back to plain TCP
+func NewTLSClient(addr, password string, cnxTimeout time.Duration, commandsMapping map[string]string, tlsConfig *tls.Config) (IClient, error) {
+	return NewUserClient(addr, "", password, cnxTimeout, commandsMapping, tlsConfig)
+}
+
+// NewUserClient build a client connection authenticated as an ACL user, Redis 6 or later,
+// the default user is used if user is empty
+func NewUserClient(addr, user, password string, cnxTimeout time.Duration, commandsMapping map[string]string, tlsConfig *tls.Config) (IClient, error) {
 	var err error
 	c := &Client{
 		commandsMapping: commandsMapping,
 	}
 
-	c.client, err = redis.DialTimeout("tcp", addr, cnxTimeout)
+	if tlsConfig == nil {
+		c.client, err = redis.DialTimeout("tcp", addr, cnxTimeout)
+	} else {
+		c.client, err = dialTLS(addr, cnxTimeout, tlsConfig)
+	}
 	if err != nil {
 		return c, err
 	}
-	if password != "" {
+	if user != "" {
+		err = c.client.Cmd("AUTH", user, password).Err
+	} else if password != "" {
 		err = c.client.Cmd("AUTH", password).Err
 	}
 	return c, err
 }
 
+// dialTLS mirrors redis.DialTimeout for a TLS connection
+func dialTLS(addr string, cnxTimeout time.Duration, tlsConfig *tls.Config) (*redis.Client, error) {
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: cnxTimeout}, "tcp", addr, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	client, err := redis.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	client.ReadTimeout = cnxTimeout
+	client.WriteTimeout = cnxTimeout
+	return client, nil
+}
+
 // Close closes the connection.
 func (c *Client) Close() error {
 	return c.client.Close()
@@ -1,6 +1,8 @@
 package redisutil
 
 import (
+	corev1 "k8s.io/api/core/v1"
+
 	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
 )
 
@@ -60,6 +62,16 @@ func (c *Cluster) GetNodeByIP(ip string) (*Node, error) {
 	return c.GetNodeByFunc(findFunc)
 }
 
+// GetNodeByPod returns the Cluster Node of the Pod, by hostname if the node announces one, by IP otherwise
+// if not present in the cluster return an error
+func (c *Cluster) GetNodeByPod(pod *corev1.Pod) (*Node, error) {
+	findFunc := func(node *Node) bool {
+		return node.MatchPod(pod)
+	}
+
+	return c.GetNodeByFunc(findFunc)
+}
+
 // GetNodeByPodName returns a Cluster Node by its Pod name
 // if not present in the cluster return an error
 func (c *Cluster) GetNodeByPodName(name string) (*Node, error) {
@@ -85,8 +85,14 @@ func DecodeNodeInfos(input *string, addr string, log logr.Logger) *NodeInfos {
 			node := NewDefaultNode()
 
 			node.ID = values[0]
+			// Redis 7 appends the hostname after the address, followed by the auxiliary fields since 7.2:
+			// ip:port@cport[,hostname[,field=value...]]
+			addrFields := strings.Split(values[1], ",")
+			if len(addrFields) > 1 && !strings.Contains(addrFields[1], "=") {
+				node.Hostname = addrFields[1]
+			}
 			//remove trailing port for cluster internal protocol
-			ipPort := strings.Split(values[1], "@")
+			ipPort := strings.Split(addrFields[0], "@")
 			if ip, port, err := splitHostPort(ipPort[0]); err == nil {
 				node.IP = ip
 				node.Port = port
@@ -0,0 +1,44 @@
+package redisutil
+
+import (
+	"testing"
+
+	logrtesting "github.com/go-logr/logr/testing"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDecodeNodeInfos_Hostname(t *testing.T) {
+	raw := "a 10.0.0.1:6379@16379,drc-test-0-0.redis-0.default.svc myself,master - 0 0 1 connected 0-5460\n" +
+		"b 10.0.0.2:6379@16379,drc-test-0-1.redis-0.default.svc,shard-id=1f2e slave a 0 1 1 connected\n" +
+		"c 10.0.0.3:6379@16379,,shard-id=3a4b master - 0 1 2 connected 5461-10922\n" +
+		"d 10.0.0.4:6379@16379 master - 0 1 3 connected 10923-16383\n"
+	infos := DecodeNodeInfos(&raw, "10.0.0.1:6379", logrtesting.NullLogger{})
+	if infos.Node == nil || infos.Node.IPPort() != "10.0.0.1:6379" {
+		t.Fatalf("DecodeNodeInfos() node = %v, want 10.0.0.1:6379", infos.Node)
+	}
+	want := map[string]string{
+		"a": "drc-test-0-0.redis-0.default.svc",
+		"b": "drc-test-0-1.redis-0.default.svc",
+		"c": "",
+		"d": "",
+	}
+	for _, node := range append(Nodes{infos.Node}, infos.Friends...) {
+		if node.Hostname != want[node.ID] {
+			t.Errorf("node %s Hostname = %q, want %q", node.ID, node.Hostname, want[node.ID])
+		}
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "drc-test-0-0"},
+		Status:     corev1.PodStatus{PodIP: "10.0.0.9"},
+	}
+	if !infos.Node.MatchPod(pod) {
+		t.Errorf("MatchPod() = false for the pod of the hostname with another IP")
+	}
+	for _, friend := range infos.Friends {
+		if friend.MatchPod(pod) {
+			t.Errorf("MatchPod() = true for node %s", friend.ID)
+		}
+	}
+}
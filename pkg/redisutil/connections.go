@@ -1,6 +1,7 @@
 package redisutil
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"math/rand"
@@ -58,6 +59,8 @@ type IAdminConnections interface {
 	ValidatePipeResp(c IClient, addr, errMessage string) bool
 	// Reset close all connections and clear the connection map
 	Reset()
+	// SetPassword changes the password of the connections and reconnects them with it
+	SetPassword(password string) error
 }
 
 // AdminConnections connection map for redis cluster
@@ -67,7 +70,9 @@ type AdminConnections struct {
 	connectionTimeout time.Duration
 	commandsMapping   map[string]string
 	clientName        string
+	user              string
 	password          string
+	tlsConfig         *tls.Config
 	log               logr.Logger
 }
 
@@ -92,7 +97,9 @@ func NewAdminConnections(addrs []string, options *AdminOptions, log logr.Logger)
 			cnx.commandsMapping = utils.BuildCommandReplaceMapping(options.RenameCommandsFile, cnx.log)
 		}
 		cnx.clientName = options.ClientName
+		cnx.user = options.User
 		cnx.password = options.Password
+		cnx.tlsConfig = options.TLSConfig
 	}
 	cnx.AddAll(addrs)
 	return cnx
@@ -130,6 +137,22 @@ func (cnx *AdminConnections) Reset() {
 	cnx.clients = map[string]IClient{}
 }
 
+// SetPassword changes the password of the connections and reconnects them with it,
+// it returns the error of the first node rejecting the password
+func (cnx *AdminConnections) SetPassword(password string) error {
+	cnx.password = password
+	addrs := make([]string, 0, len(cnx.clients))
+	for addr := range cnx.clients {
+		addrs = append(addrs, addr)
+	}
+	for _, addr := range addrs {
+		if err := cnx.Reconnect(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // GetAll returns a map of all clients per address
 func (cnx *AdminConnections) GetAll() map[string]IClient {
 	return cnx.clients
@@ -262,7 +285,7 @@ func (cnx *AdminConnections) handleError(addr string, err error) bool {
 }
 
 func (cnx *AdminConnections) connect(addr string) (IClient, error) {
-	c, err := NewClient(addr, cnx.password, cnx.connectionTimeout, cnx.commandsMapping)
+	c, err := NewUserClient(addr, cnx.user, cnx.password, cnx.connectionTimeout, cnx.commandsMapping, cnx.tlsConfig)
 	if err != nil {
 		return nil, err
 	}
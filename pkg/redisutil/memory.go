@@ -0,0 +1,50 @@
+package redisutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MemoryInfo represents the memory section of the INFO command
+type MemoryInfo struct {
+	UsedMemory int64
+	// MaxMemory is 0 if the memory of the node is unlimited.
+	MaxMemory int64
+}
+
+// GetMemoryInfo returns the memory infos of the node c is connected to.
+func GetMemoryInfo(c IClient) (*MemoryInfo, error) {
+	raw, err := c.Cmd("INFO", "memory").Str()
+	if err != nil {
+		return nil, fmt.Errorf("wrong format from INFO memory: %v", err)
+	}
+	return DecodeMemoryInfo(raw)
+}
+
+// DecodeMemoryInfo decodes the output of the INFO memory command.
+func DecodeMemoryInfo(raw string) (*MemoryInfo, error) {
+	info := &MemoryInfo{}
+	found := false
+	for _, line := range strings.Split(raw, "\n") {
+		values := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(values) != 2 {
+			continue
+		}
+		var err error
+		switch values[0] {
+		case "used_memory":
+			info.UsedMemory, err = strconv.ParseInt(values[1], 10, 64)
+			found = true
+		case "maxmemory":
+			info.MaxMemory, err = strconv.ParseInt(values[1], 10, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in INFO memory: %v", values[0], err)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("used_memory not found in INFO memory")
+	}
+	return info, nil
+}
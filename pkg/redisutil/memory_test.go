@@ -0,0 +1,48 @@
+package redisutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeMemoryInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    *MemoryInfo
+		wantErr bool
+	}{
+		{
+			name: "maxmemory",
+			raw: "# Memory\r\nused_memory:1048576\r\nused_memory_human:1.00M\r\n" +
+				"maxmemory:4294967296\r\nmaxmemory_human:4.00G\r\nmaxmemory_policy:noeviction\r\n",
+			want: &MemoryInfo{UsedMemory: 1048576, MaxMemory: 4294967296},
+		},
+		{
+			name: "unlimited",
+			raw:  "# Memory\r\nused_memory:1048576\r\nmaxmemory:0\r\n",
+			want: &MemoryInfo{UsedMemory: 1048576},
+		},
+		{
+			name:    "invalid used_memory",
+			raw:     "used_memory:abc\r\n",
+			wantErr: true,
+		},
+		{
+			name:    "no used_memory",
+			raw:     "# Memory\r\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeMemoryInfo(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeMemoryInfo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeMemoryInfo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
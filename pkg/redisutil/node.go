@@ -48,6 +48,7 @@ type Node struct {
 	ID              string
 	IP              string
 	Port            string
+	Hostname        string
 	Role            string
 	LinkState       string
 	MasterReferent  string
@@ -151,6 +152,24 @@ func (n *Node) IPPort() string {
 	return net.JoinHostPort(n.IP, n.Port)
 }
 
+// MatchPod returns true if n is the redis node of pod. A node announcing a hostname is identified by the name
+// of its pod, the first label of the hostname, which does not change when the pod is recreated with another IP.
+func (n *Node) MatchPod(pod *corev1.Pod) bool {
+	if n.Hostname != "" {
+		return strings.SplitN(n.Hostname, ".", 2)[0] == pod.Name
+	}
+	return n.IP == pod.Status.PodIP
+}
+
+// SameHost returns true if n and node are reached at the same hostname, or at the same IP if one of them
+// announces no hostname.
+func (n *Node) SameHost(node *Node) bool {
+	if n.Hostname != "" && node.Hostname != "" {
+		return n.Hostname == node.Hostname
+	}
+	return n.IP == node.IP
+}
+
 // GetNodesByFunc returns first node found by the FindNodeFunc
 func (n Nodes) GetNodesByFunc(f FindNodeFunc) (Nodes, error) {
 	nodes := Nodes{}
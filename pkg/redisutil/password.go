@@ -0,0 +1,41 @@
+package redisutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// GetMajorVersion returns the major version of the node c is connected to.
+func GetMajorVersion(c IClient) (int, error) {
+	raw, err := c.Cmd("INFO", "server").Str()
+	if err != nil {
+		return 0, fmt.Errorf("wrong format from INFO server: %v", err)
+	}
+	return DecodeMajorVersion(raw)
+}
+
+// DecodeMajorVersion decodes the major version of redis_version in the output of the INFO server command.
+func DecodeMajorVersion(raw string) (int, error) {
+	for _, line := range strings.Split(raw, "\n") {
+		values := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(values) != 2 || values[0] != "redis_version" {
+			continue
+		}
+		major, err := strconv.Atoi(strings.SplitN(values[1], ".", 2)[0])
+		if err != nil {
+			return 0, fmt.Errorf("invalid redis_version in INFO server: %v", err)
+		}
+		return major, nil
+	}
+	return 0, fmt.Errorf("redis_version not found in INFO server")
+}
+
+// samePasswords returns true if hashes are the hashes of passwords, in any order.
+func samePasswords(hashes []string, passwords []string) bool {
+	var expected []string
+	for _, password := range passwords {
+		expected = append(expected, passwordHash(password))
+	}
+	return sameStrings(hashes, expected)
+}
@@ -0,0 +1,44 @@
+package redisutil
+
+import "testing"
+
+func TestDecodeMajorVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "redis 6",
+			raw:  "# Server\r\nredis_version:6.2.6\r\nredis_git_sha1:00000000\r\nredis_mode:cluster\r\n",
+			want: 6,
+		},
+		{
+			name: "redis 5",
+			raw:  "# Server\r\nredis_version:5.0.4\r\n",
+			want: 5,
+		},
+		{
+			name:    "invalid redis_version",
+			raw:     "redis_version:abc\r\n",
+			wantErr: true,
+		},
+		{
+			name:    "no redis_version",
+			raw:     "# Server\r\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeMajorVersion(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeMajorVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DecodeMajorVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
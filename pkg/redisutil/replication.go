@@ -0,0 +1,59 @@
+package redisutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ReplicationInfo represents the replication section of the INFO command
+type ReplicationInfo struct {
+	Role             string
+	MasterLinkStatus string
+	MasterReplOffset int64
+	SlaveReplOffset  int64
+}
+
+// IsSlaveInSync returns true if the link of the slave with its master is up and its replication
+// offset is at most maxLag bytes behind masterOffset.
+func (r *ReplicationInfo) IsSlaveInSync(masterOffset, maxLag int64) bool {
+	return r.Role == "slave" && r.MasterLinkStatus == "up" && masterOffset-r.SlaveReplOffset <= maxLag
+}
+
+// GetReplicationInfo returns the replication infos of the node c is connected to.
+func GetReplicationInfo(c IClient) (*ReplicationInfo, error) {
+	raw, err := c.Cmd("INFO", "replication").Str()
+	if err != nil {
+		return nil, fmt.Errorf("wrong format from INFO replication: %v", err)
+	}
+	return DecodeReplicationInfo(raw)
+}
+
+// DecodeReplicationInfo decodes the output of the INFO replication command.
+func DecodeReplicationInfo(raw string) (*ReplicationInfo, error) {
+	info := &ReplicationInfo{}
+	for _, line := range strings.Split(raw, "\n") {
+		values := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(values) != 2 {
+			continue
+		}
+		var err error
+		switch values[0] {
+		case "role":
+			info.Role = values[1]
+		case "master_link_status":
+			info.MasterLinkStatus = values[1]
+		case "master_repl_offset":
+			info.MasterReplOffset, err = strconv.ParseInt(values[1], 10, 64)
+		case "slave_repl_offset":
+			info.SlaveReplOffset, err = strconv.ParseInt(values[1], 10, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s in INFO replication: %v", values[0], err)
+		}
+	}
+	if info.Role == "" {
+		return nil, fmt.Errorf("role not found in INFO replication")
+	}
+	return info, nil
+}
@@ -0,0 +1,72 @@
+package redisutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeReplicationInfo(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    *ReplicationInfo
+		wantErr bool
+	}{
+		{
+			name: "master",
+			raw: "# Replication\r\nrole:master\r\nconnected_slaves:1\r\n" +
+				"slave0:ip=10.0.0.2,port=6379,state=online,offset=1000,lag=0\r\n" +
+				"master_replid:8f2f5e2a\r\nmaster_repl_offset:1200\r\n",
+			want: &ReplicationInfo{Role: "master", MasterReplOffset: 1200},
+		},
+		{
+			name: "slave",
+			raw: "# Replication\r\nrole:slave\r\nmaster_host:10.0.0.1\r\nmaster_port:6379\r\n" +
+				"master_link_status:up\r\nslave_repl_offset:1000\r\nmaster_repl_offset:1000\r\n",
+			want: &ReplicationInfo{Role: "slave", MasterLinkStatus: "up", MasterReplOffset: 1000, SlaveReplOffset: 1000},
+		},
+		{
+			name:    "invalid offset",
+			raw:     "role:slave\r\nslave_repl_offset:abc\r\n",
+			wantErr: true,
+		},
+		{
+			name:    "no role",
+			raw:     "# Replication\r\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeReplicationInfo(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeReplicationInfo() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeReplicationInfo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplicationInfo_IsSlaveInSync(t *testing.T) {
+	tests := []struct {
+		name         string
+		info         ReplicationInfo
+		masterOffset int64
+		want         bool
+	}{
+		{"in sync", ReplicationInfo{Role: "slave", MasterLinkStatus: "up", SlaveReplOffset: 1000}, 1000, true},
+		{"lag allowed", ReplicationInfo{Role: "slave", MasterLinkStatus: "up", SlaveReplOffset: 900}, 1000, true},
+		{"lagging", ReplicationInfo{Role: "slave", MasterLinkStatus: "up", SlaveReplOffset: 800}, 1000, false},
+		{"link down", ReplicationInfo{Role: "slave", MasterLinkStatus: "down", SlaveReplOffset: 1000}, 1000, false},
+		{"master", ReplicationInfo{Role: "master", MasterReplOffset: 1000}, 1000, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.info.IsSlaveInSync(tt.masterOffset, 100); got != tt.want {
+				t.Errorf("IsSlaveInSync() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package redisutil
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// SlotOwners returns the master serving each slot of the cluster, indexed by slot.
+func SlotOwners(nodes Nodes) ([]*Node, error) {
+	owners := make([]*Node, DefaultHashMaxSlots+1)
+	for _, node := range nodes.FilterByFunc(IsMasterWithSlot) {
+		for _, slot := range node.Slots {
+			owners[slot] = node
+		}
+	}
+	for slot, owner := range owners {
+		if owner == nil {
+			return nil, fmt.Errorf("slot %d is not served by any master", slot)
+		}
+	}
+	return owners, nil
+}
+
+// ReshardKeys moves up to batch keys from the standalone redis server c into the cluster masters
+// serving their slots, the keys are removed from c once migrated. It returns the number of keys left on c.
+func ReshardKeys(c IClient, owners []*Node, batch, timeout int, password string) (int64, error) {
+	keys, err := scanKeys(c, batch)
+	if err != nil {
+		return 0, err
+	}
+
+	byOwner := make(map[*Node][]string)
+	for _, key := range keys {
+		owner := owners[SlotForKey(key)]
+		byOwner[owner] = append(byOwner[owner], key)
+	}
+	timeoutStr := strconv.Itoa(timeout)
+	for owner, keys := range byOwner {
+		args := []string{owner.IP, owner.Port, "", "0", timeoutStr, "REPLACE"}
+		if password != "" {
+			args = append(args, "AUTH", password)
+		}
+		args = append(args, "KEYS")
+		args = append(args, keys...)
+		// MIGRATE replies NOKEY if all the keys have expired meanwhile.
+		resp := c.Cmd("MIGRATE", args)
+		if resp.Err != nil {
+			return 0, fmt.Errorf("unable to migrate keys to %s: %v", owner.IPPort(), resp.Err)
+		}
+	}
+
+	return c.Cmd("DBSIZE").Int64()
+}
+
+// scanKeys returns up to about batch keys, scanning from the beginning of the keyspace.
+func scanKeys(c IClient, batch int) ([]string, error) {
+	keys := []string{}
+	cursor := "0"
+	for {
+		resp := c.Cmd("SCAN", cursor, "COUNT", batch)
+		if resp.Err != nil {
+			return nil, resp.Err
+		}
+		elems, err := resp.Array()
+		if err != nil || len(elems) != 2 {
+			return nil, fmt.Errorf("wrong returned format for SCAN: %v", resp)
+		}
+		if cursor, err = elems[0].Str(); err != nil {
+			return nil, err
+		}
+		found, err := elems[1].List()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, found...)
+		if cursor == "0" || len(keys) >= batch {
+			return keys, nil
+		}
+	}
+}
@@ -204,3 +204,30 @@ func BuildSlotSlice(min, max Slot) []Slot {
 	}
 	return slots
 }
+
+// SlotForKey returns the slot of a key, only the hash tag is hashed if the key contains one.
+// ex: {user1000}.following and {user1000}.followers are in the same slot.
+func SlotForKey(key string) Slot {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return Slot(crc16(key)) % (DefaultHashMaxSlots + 1)
+}
+
+// crc16 implements the CRC16-CCITT (XMODEM) checksum used by redis cluster.
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc ^= uint16(s[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
@@ -124,3 +124,27 @@ func TestRemoveSlot(t *testing.T) {
 		})
 	}
 }
+
+func TestSlotForKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want Slot
+	}{
+		{key: "", want: 0},
+		{key: "123456789", want: 0x31C3 % 16384},
+		{key: "foo", want: 12182},
+		{key: "bar", want: 5061},
+		{key: "hello", want: 866},
+		{key: "{user1000}.following", want: SlotForKey("user1000")},
+		{key: "{user1000}.followers", want: SlotForKey("user1000")},
+		{key: "foo{{bar}}zap", want: SlotForKey("{bar")},
+		{key: "foo{bar}{zap}", want: SlotForKey("bar")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := SlotForKey(tt.key); got != tt.want {
+				t.Errorf("SlotForKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
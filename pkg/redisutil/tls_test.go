@@ -0,0 +1,130 @@
+package redisutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+func newTestCert(t *testing.T, serial int64, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "redis"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// serveTLS accepts TLS connections with leaf until the listener is closed.
+func serveTLS(t *testing.T, leaf *testCert) net.Listener {
+	pair, err := tls.X509KeyPair(leaf.certPEM, leaf.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{pair}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	return l
+}
+
+func TestPeerCertificate(t *testing.T) {
+	ca := newTestCert(t, 1, nil)
+	leaf := newTestCert(t, 2, ca)
+	otherCA := newTestCert(t, 3, nil)
+	l := serveTLS(t, leaf)
+	defer l.Close()
+	addr := l.Addr().String()
+
+	tlsConfig, err := NewTLSConfig(ca.certPEM, leaf.certPEM, leaf.keyPEM)
+	if err != nil {
+		t.Fatalf("NewTLSConfig() error = %v", err)
+	}
+	// the node is reached by IP, which is not a name of its certificate.
+	cert, err := PeerCertificate(addr, time.Second, tlsConfig)
+	if err != nil {
+		t.Fatalf("PeerCertificate() error = %v", err)
+	}
+	parsed, err := ParseCertificate(leaf.certPEM)
+	if err != nil {
+		t.Fatalf("ParseCertificate() error = %v", err)
+	}
+	if !cert.Equal(parsed) {
+		t.Errorf("PeerCertificate() serial = %v, want %v", cert.SerialNumber, parsed.SerialNumber)
+	}
+
+	untrusted, err := NewTLSConfig(otherCA.certPEM, leaf.certPEM, leaf.keyPEM)
+	if err != nil {
+		t.Fatalf("NewTLSConfig() error = %v", err)
+	}
+	if _, err := PeerCertificate(addr, time.Second, untrusted); err == nil {
+		t.Errorf("PeerCertificate() with another CA error = nil, want a verification error")
+	}
+}
+
+func TestNewTLSConfig_Invalid(t *testing.T) {
+	ca := newTestCert(t, 1, nil)
+	leaf := newTestCert(t, 2, ca)
+	if _, err := NewTLSConfig(nil, leaf.certPEM, leaf.keyPEM); err == nil {
+		t.Errorf("NewTLSConfig() without CA error = nil, want an error")
+	}
+	if _, err := NewTLSConfig(ca.certPEM, leaf.certPEM, ca.keyPEM); err == nil {
+		t.Errorf("NewTLSConfig() with a mismatched key error = nil, want an error")
+	}
+}
@@ -0,0 +1,72 @@
+package certificates
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/resources/statefulsets"
+)
+
+// CertificateGVK is the cert-manager Certificate, it is handled as unstructured so the operator
+// does not depend on cert-manager unless a cluster uses an issuer.
+var CertificateGVK = schema.GroupVersionKind{
+	Group:   "cert-manager.io",
+	Version: "v1alpha2",
+	Kind:    "Certificate",
+}
+
+// NewCertificateForCR creates the cert-manager Certificate writing the TLS Secret of the cluster.
+// The certificate is both a server and a client certificate, the redis nodes present it to each other
+// on the cluster bus and for the replication.
+func NewCertificateForCR(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) *unstructured.Unstructured {
+	issuer := cluster.Spec.TLS.IssuerRef
+	kind := issuer.Kind
+	if kind == "" {
+		kind = redisv1alpha1.TLSIssuerKind
+	}
+
+	cert := &unstructured.Unstructured{}
+	cert.SetGroupVersionKind(CertificateGVK)
+	cert.SetName(cluster.TLSSecretName())
+	cert.SetNamespace(cluster.Namespace)
+	cert.SetLabels(labels)
+	cert.SetOwnerReferences(redisv1alpha1.DefaultOwnerReferences(cluster))
+	cert.Object["spec"] = map[string]interface{}{
+		"secretName": cluster.TLSSecretName(),
+		"commonName": fmt.Sprintf("%s.%s.svc", cluster.Spec.ServiceName, cluster.Namespace),
+		"dnsNames":   toInterfaces(dnsNames(cluster)),
+		"usages":     []interface{}{"digital signature", "key encipherment", "server auth", "client auth"},
+		"issuerRef": map[string]interface{}{
+			"name":  issuer.Name,
+			"kind":  kind,
+			"group": CertificateGVK.Group,
+		},
+	}
+	return cert
+}
+
+// dnsNames returns the names of the service of the cluster and of the pods behind the headless services.
+func dnsNames(cluster *redisv1alpha1.DistributedRedisCluster) []string {
+	svc := cluster.Spec.ServiceName
+	ns := cluster.Namespace
+	names := []string{
+		svc,
+		fmt.Sprintf("%s.%s", svc, ns),
+		fmt.Sprintf("%s.%s.svc", svc, ns),
+	}
+	for i := 0; i < int(cluster.Spec.MasterSize); i++ {
+		names = append(names, fmt.Sprintf("*.%s.%s.svc", statefulsets.ClusterHeadlessSvcName(svc, i), ns))
+	}
+	return names
+}
+
+func toInterfaces(values []string) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
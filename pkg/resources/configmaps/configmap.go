@@ -7,6 +7,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisconfig"
 )
 
 const RestoreSucceeded = "succeeded"
@@ -20,9 +21,13 @@ failover() {
     echo "Do CLUSTER FAILOVER"
     masterID=$(cat ${CLUSTER_CONFIG} | grep "myself" | awk '{print $1}')
     echo "Master: ${masterID}"
-    slave=$(cat ${CLUSTER_CONFIG} | grep ${masterID} | grep "slave" | awk 'NR==1{print $2}' | sed 's/:6379@16379//')
-    echo "Slave: ${slave}"
-    redis-cli -h ${slave} -a "${REDIS_PASSWORD}" CLUSTER FAILOVER
+    # the address of a node is ip:port@cport, the announced external address with external access,
+    # followed by ,hostname when the nodes announce their hostname
+    slave=$(cat ${CLUSTER_CONFIG} | grep ${masterID} | grep "slave" | awk 'NR==1{print $2}')
+    slave=${slave%%,*}
+    hostPort=${slave%%@*}
+    echo "Slave: ${hostPort}"
+    redis-cli ${REDIS_CLI_ARGS} -h ${hostPort%:*} -p ${hostPort##*:} -a "${REDIS_PASSWORD}" CLUSTER FAILOVER
 	echo "Wait for MASTER <-> SLAVE syncFinished"
 	sleep 20
 }
@@ -33,18 +38,59 @@ fi`
 
 	// Fixed Nodes.conf does not update IP address of a node when IP changes after restart,
 	// see more https://github.com/antirez/redis/issues/4645.
+	// With external access the node announces the address the operator set in the annotations of its pod.
 	fixIPContent := `#!/bin/sh
 CLUSTER_CONFIG="/data/nodes.conf"
+POD_ANNOTATIONS="/podinfo/annotations"
+ANNOUNCE_ADDRESS=""
+if [ -f ${POD_ANNOTATIONS} ]; then
+    ANNOUNCE_ADDRESS=$(sed -n 's/^redis\.kun\/announce-address="\(.*\)"$/\1/p' ${POD_ANNOTATIONS})
+fi
 if [ -f ${CLUSTER_CONFIG} ]; then
     if [ -z "${POD_IP}" ]; then
     echo "Unable to determine Pod IP address!"
     exit 1
     fi
-    echo "Updating my IP to ${POD_IP} in ${CLUSTER_CONFIG}"
-    sed -i.bak -e "/myself/ s/ .*:6379@16379/ ${POD_IP}:6379@16379/" ${CLUSTER_CONFIG}
+    ADDRESS="${POD_IP}:6379@16379"
+    if [ -n "${ANNOUNCE_ADDRESS}" ]; then
+        ADDRESS="${ANNOUNCE_ADDRESS}"
+    fi
+    echo "Updating my address to ${ADDRESS} in ${CLUSTER_CONFIG}"
+    sed -i.bak -e "/myself/ s/ [^ ]*:[0-9]*@[0-9]*/ ${ADDRESS}/" ${CLUSTER_CONFIG}
+fi
+# CONFIG REWRITE needs a writable config file.
+cp /conf/redis.conf /data/redis.conf
+if [ -n "${ANNOUNCE_ADDRESS}" ]; then
+    ANNOUNCE_PORTS="${ANNOUNCE_ADDRESS#*:}"
+    exec "$@" --cluster-announce-ip "${ANNOUNCE_ADDRESS%%:*}" \
+        --cluster-announce-port "${ANNOUNCE_PORTS%@*}" --cluster-announce-bus-port "${ANNOUNCE_PORTS#*@}"
 fi
 exec "$@"`
 
+	// Replays the AOF pulled from a continuous backup up to RESTORE_TIMESTAMP and saves it as the
+	// dump file, the AOF is removed once it is replayed so a restarted pod does not replay it again.
+	replayAOFContent := `#!/bin/sh
+set -e
+RESTORE_DIR="/data/aof-restore"
+SOCKET="/tmp/replay-aof.sock"
+if [ ! -d ${RESTORE_DIR}/appendonlydir ]; then
+    echo "No AOF to replay"
+    exit 0
+fi
+echo "Truncating AOF to ${RESTORE_TIMESTAMP}"
+echo y | redis-check-aof --truncate-to-timestamp ${RESTORE_TIMESTAMP} ${RESTORE_DIR}/appendonlydir/appendonly.aof.manifest
+redis-server --port 0 --unixsocket ${SOCKET} --dir ${RESTORE_DIR} --dbfilename dump.rdb \
+    --appendonly yes --appenddirname appendonlydir --appendfilename appendonly.aof --daemonize yes
+until [ "$(redis-cli -s ${SOCKET} PING 2>/dev/null)" = "PONG" ]; do
+    echo "Waiting for the AOF to be loaded"
+    sleep 1
+done
+redis-cli -s ${SOCKET} SAVE
+redis-cli -s ${SOCKET} SHUTDOWN NOSAVE || true
+mv ${RESTORE_DIR}/dump.rdb /data/dump.rdb
+rm -rf ${RESTORE_DIR}
+echo "AOF replayed"`
+
 	return &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            RedisConfigMapName(cluster.Name),
@@ -53,8 +99,12 @@ exec "$@"`
 			OwnerReferences: redisv1alpha1.DefaultOwnerReferences(cluster),
 		},
 		Data: map[string]string{
-			"shutdown.sh": shutdownContent,
-			"fix-ip.sh":   fixIPContent,
+			"shutdown.sh":   shutdownContent,
+			"fix-ip.sh":     fixIPContent,
+			"replay-aof.sh": replayAOFContent,
+			// all the parameters, with the ones required by the continuous backup, are rendered so that a
+			// restarted node starts with them, the hot parameters are also set at runtime by the operator.
+			redisconfig.ConfigFile: redisconfig.Render(cluster.RedisConfig()),
 		},
 	}
 }
@@ -0,0 +1,97 @@
+package configmaps
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisconfig"
+)
+
+func TestNewConfigMapForCR_ContinuousBackup(t *testing.T) {
+	cluster := &redisv1alpha1.DistributedRedisCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"},
+		Spec: redisv1alpha1.DistributedRedisClusterSpec{
+			Image:            "redis:7.0",
+			Config:           map[string]string{"appendonly": "no", "maxmemory": "1gb"},
+			ContinuousBackup: &redisv1alpha1.ContinuousBackupSpec{Image: "redis-tools"},
+		},
+	}
+	conf := NewConfigMapForCR(cluster, nil).Data[redisconfig.ConfigFile]
+	for _, line := range []string{"appendonly yes", "aof-timestamp-enabled yes", "maxmemory 1gb"} {
+		if !strings.Contains(conf, line+"\n") {
+			t.Errorf("redis.conf = %q, want %q", conf, line)
+		}
+	}
+}
+
+// TestShutdownFailover runs shutdown.sh on a master with redis-cli and sleep stubbed, and checks the address of the
+// slave the failover is sent to.
+func TestShutdownFailover(t *testing.T) {
+	cluster := &redisv1alpha1.DistributedRedisCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"},
+	}
+	shutdown := NewConfigMapForCR(cluster, nil).Data["shutdown.sh"]
+	tests := []struct {
+		name  string
+		slave string
+		want  string
+	}{
+		{
+			name:  "pod address",
+			slave: "10.0.0.2:6379@16379",
+			want:  "-h 10.0.0.2 -p 6379 ",
+		},
+		{
+			name:  "external address",
+			slave: "35.1.2.3:30001@30002",
+			want:  "-h 35.1.2.3 -p 30001 ",
+		},
+		{
+			name:  "hostname",
+			slave: "10.0.0.2:6379@16379,drc-cluster-0-1.redis-cluster-cluster.default.svc",
+			want:  "-h 10.0.0.2 -p 6379 ",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "shutdown")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			nodesConf := filepath.Join(dir, "nodes.conf")
+			conf := "m1 10.0.0.1:6379@16379 myself,master - 0 0 1 connected 0-16383\n" +
+				"s1 " + tt.slave + " slave m1 0 0 1 connected\n"
+			files := map[string]string{
+				nodesConf:                         conf,
+				filepath.Join(dir, "redis-cli"):   "#!/bin/sh\necho \"$@\" > " + filepath.Join(dir, "args") + "\n",
+				filepath.Join(dir, "sleep"):       "#!/bin/sh\n",
+				filepath.Join(dir, "shutdown.sh"): strings.Replace(shutdown, "/data/nodes.conf", nodesConf, 1),
+			}
+			for name, content := range files {
+				if err := ioutil.WriteFile(name, []byte(content), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cmd := exec.Command("/bin/sh", filepath.Join(dir, "shutdown.sh"))
+			cmd.Env = []string{"PATH=" + dir + ":" + os.Getenv("PATH"), "REDIS_PASSWORD=pw"}
+			if out, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("shutdown.sh error = %v: %s", err, out)
+			}
+			args, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(args), tt.want) || !strings.HasSuffix(string(args), "CLUSTER FAILOVER\n") {
+				t.Errorf("redis-cli %s, want %q", args, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+)
+
+const (
+	// PasswordKey is the key of the password in the password Secrets.
+	PasswordKey = "password"
+	// PreviousPasswordKey is the key of the password the nodes still accept during a password rotation.
+	PreviousPasswordKey = "previousPassword"
+)
+
+// NewOperatorSecretForCR creates the Secret holding a random password of the ACL user of the operator.
+func NewOperatorSecretForCR(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string) (*corev1.Secret, error) {
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return nil, err
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:          labels,
+			Name:            cluster.OperatorSecretName(),
+			Namespace:       cluster.Namespace,
+			OwnerReferences: redisv1alpha1.DefaultOwnerReferences(cluster),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			PasswordKey: []byte(hex.EncodeToString(password)),
+		},
+	}, nil
+}
+
+// NewCurrentPasswordSecretForCR creates the Secret holding the password the redis nodes accept, and the previous
+// password they still accept during a rotation if not empty.
+func NewCurrentPasswordSecretForCR(cluster *redisv1alpha1.DistributedRedisCluster, labels map[string]string,
+	password, previous string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:          labels,
+			Name:            cluster.CurrentPasswordSecretName(),
+			Namespace:       cluster.Namespace,
+			OwnerReferences: redisv1alpha1.DefaultOwnerReferences(cluster),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			PasswordKey: []byte(password),
+		},
+	}
+	if previous != "" {
+		secret.Data[PreviousPasswordKey] = []byte(previous)
+	}
+	return secret
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
@@ -22,6 +23,8 @@ func NewHeadLessSvcForCR(cluster *redisv1alpha1.DistributedRedisCluster, name st
 			Ports:     []corev1.ServicePort{clientPort, gossipPort},
 			Selector:  labels,
 			ClusterIP: corev1.ClusterIPNone,
+			// the clients resolve the hostnames the nodes announce, a node restarting must keep its name.
+			PublishNotReadyAddresses: cluster.Spec.AnnounceHostnames,
 		},
 	}
 
@@ -46,3 +49,38 @@ func NewSvcForCR(cluster *redisv1alpha1.DistributedRedisCluster, name string, la
 
 	return svc
 }
+
+// NewExternalSvcForCR creates the Service exposing the redis node of the pod podName outside the Kubernetes
+// cluster. The nodes also talk to each other through it on the cluster bus, the pod is published before it
+// is ready so the node can join the cluster.
+func NewExternalSvcForCR(cluster *redisv1alpha1.DistributedRedisCluster, podName string, labels map[string]string) *corev1.Service {
+	svcLabels := map[string]string{redisv1alpha1.LabelExternalAccess: "true"}
+	for key, value := range labels {
+		svcLabels[key] = value
+	}
+	clientPort := corev1.ServicePort{Name: "client", Port: 6379}
+	gossipPort := corev1.ServicePort{Name: "gossip", Port: 16379}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:          svcLabels,
+			Annotations:     cluster.Spec.ExternalAccess.Annotations,
+			Name:            ExternalSvcName(podName),
+			Namespace:       cluster.Namespace,
+			OwnerReferences: redisv1alpha1.DefaultOwnerReferences(cluster),
+		},
+		Spec: corev1.ServiceSpec{
+			Type:                     cluster.Spec.ExternalAccess.Type,
+			Ports:                    []corev1.ServicePort{clientPort, gossipPort},
+			Selector:                 map[string]string{appsv1.StatefulSetPodNameLabel: podName},
+			ExternalTrafficPolicy:    corev1.ServiceExternalTrafficPolicyTypeLocal,
+			PublishNotReadyAddresses: true,
+		},
+	}
+
+	return svc
+}
+
+// ExternalSvcName returns the name of the Service exposing the redis node of the pod podName.
+func ExternalSvcName(podName string) string {
+	return podName + "-external"
+}
@@ -0,0 +1,43 @@
+package statefulsets
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	podInfoVolumeName = "podinfo"
+	// podInfoMountPath is where the annotations of the pod are mounted, fix-ip.sh reads the address the node
+	// announces with external access from there.
+	podInfoMountPath = "/podinfo"
+)
+
+// setExternalAccess mounts the annotations of the pod in the redis container of ss. The kubelet updates them
+// in a running pod, a restarted node announces the address the operator set on its pod.
+func setExternalAccess(ss *appsv1.StatefulSet) {
+	podSpec := &ss.Spec.Template.Spec
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: podInfoVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			DownwardAPI: &corev1.DownwardAPIVolumeSource{
+				Items: []corev1.DownwardAPIVolumeFile{
+					{
+						Path:     "annotations",
+						FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.annotations"},
+					},
+				},
+			},
+		},
+	})
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		if container.Name != redisServerName {
+			continue
+		}
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      podInfoVolumeName,
+			ReadOnly:  true,
+			MountPath: podInfoMountPath,
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package statefulsets
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const podNameEnv = "POD_NAME"
+
+// PodHostname returns the DNS name of the pod podName behind the headless service svcName, the name its redis
+// node announces with AnnounceHostnames.
+func PodHostname(podName, svcName, namespace string) string {
+	return fmt.Sprintf("%s.%s.%s.svc", podName, svcName, namespace)
+}
+
+// setAnnounceHostname makes the redis node of the pods of ss announce the DNS name of its pod behind the
+// headless service of ss, and redirect the clients with it rather than with its IP.
+func setAnnounceHostname(ss *appsv1.StatefulSet) {
+	hostname := PodHostname(fmt.Sprintf("$(%s)", podNameEnv), ss.Spec.ServiceName, ss.Namespace)
+	podSpec := &ss.Spec.Template.Spec
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		if container.Name != redisServerName {
+			continue
+		}
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name: podNameEnv,
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+			},
+		})
+		container.Command = append(container.Command,
+			fmt.Sprintf("--cluster-announce-hostname %s", hostname),
+			"--cluster-preferred-endpoint-type hostname",
+		)
+	}
+}
@@ -0,0 +1,123 @@
+package statefulsets
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+)
+
+// RestoreLoaderPodName returns the name of the pod loading the i-th snapshot of a backup,
+// the restore init container reads the index of the snapshot from the second to last field of the name.
+func RestoreLoaderPodName(restoreName string, i int) string {
+	return fmt.Sprintf("drcr-%s-%d-loader", restoreName, i)
+}
+
+// NewRestoreLoaderPod creates a standalone redis server pod loading one snapshot of the backup,
+// the keys are then moved into the masters of cluster serving their slots.
+func NewRestoreLoaderPod(cluster *redisv1alpha1.DistributedRedisCluster, backup *redisv1alpha1.RedisClusterBackup,
+	name string, labels map[string]string, ownerRefs []metav1.OwnerReference) (*corev1.Pod, error) {
+	password := redisPassword(cluster)
+	initContainer, err := redisInitContainer(cluster, backup, password, nil)
+	if err != nil {
+		return nil, err
+	}
+	// the loader always pulls its snapshot, whatever the restore state of the cluster is.
+	for i, env := range initContainer.Env {
+		if env.Name == "REDIS_RESTORE_SUCCEEDED" {
+			initContainer.Env[i] = corev1.EnvVar{Name: env.Name, Value: "0"}
+		}
+	}
+
+	cmd := []string{
+		"redis-server",
+		"--dir", redisv1alpha1.BackupDumpDir,
+		"--dbfilename", "dump.rdb",
+		"--appendonly", "no",
+		"--protected-mode", "no",
+	}
+	if password != nil {
+		cmd = append(cmd, "--requirepass", fmt.Sprintf("$(%s)", redisv1alpha1.PasswordENV))
+	}
+	// the loader MIGRATEs its keys to the masters, it needs their TLS setup.
+	if cluster.Spec.TLS != nil {
+		cmd = append(cmd, tlsArgs()...)
+	}
+	// PING replies LOADING until the snapshot is loaded.
+	probeArg := fmt.Sprintf(`redis-cli ${%s} -h 127.0.0.1 ${%s:+-a "$%s"} ping | grep -q PONG`,
+		redisCLIArgsEnv, redisv1alpha1.PasswordENV, redisv1alpha1.PasswordENV)
+	container := corev1.Container{
+		Name:    redisServerName,
+		Image:   cluster.Spec.Image,
+		Command: cmd,
+		Ports: []corev1.ContainerPort{
+			{
+				Name:          "client",
+				ContainerPort: 6379,
+				Protocol:      corev1.ProtocolTCP,
+			},
+		},
+		ReadinessProbe: &corev1.Probe{
+			PeriodSeconds:  5,
+			TimeoutSeconds: 5,
+			Handler: corev1.Handler{
+				Exec: &corev1.ExecAction{
+					Command: []string{
+						"sh",
+						"-c",
+						probeArg,
+					},
+				},
+			},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      redisStorageVolumeName,
+				MountPath: redisv1alpha1.BackupDumpDir,
+			},
+		},
+	}
+	if password != nil {
+		container.Env = append(container.Env, *password)
+	}
+	if cluster.Spec.Resources != nil {
+		container.Resources = *cluster.Spec.Resources
+	}
+	volumes := []corev1.Volume{
+		*emptyVolume(),
+		{
+			Name: osmConfigVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: backup.OSMSecretName(),
+				},
+			},
+		},
+	}
+	if cluster.Spec.TLS != nil {
+		container.Env = append(container.Env, RedisCLIArgs())
+		container.VolumeMounts = append(container.VolumeMounts, TLSVolumeMount())
+		volumes = append(volumes, TLSVolume(cluster))
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       cluster.Namespace,
+			Labels:          labels,
+			OwnerReferences: ownerRefs,
+		},
+		Spec: corev1.PodSpec{
+			Tolerations:     cluster.Spec.ToleRations,
+			SecurityContext: cluster.Spec.SecurityContext,
+			NodeSelector:    cluster.Spec.NodeSelector,
+			InitContainers:  []corev1.Container{initContainer},
+			Containers:      []corev1.Container{container},
+			RestartPolicy:   corev1.RestartPolicyNever,
+			Volumes:         volumes,
+		},
+	}
+	return pod, nil
+}
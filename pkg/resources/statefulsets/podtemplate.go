@@ -0,0 +1,85 @@
+package statefulsets
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+)
+
+// PodTemplateHash returns a hash of the pod template of the spec of the cluster, the pods are restarted
+// when it changes. It is empty if the cluster has no pod template.
+func PodTemplateHash(cluster *redisv1alpha1.DistributedRedisCluster) string {
+	if cluster.Spec.PodTemplate == nil {
+		return ""
+	}
+	data, err := json.Marshal(cluster.Spec.PodTemplate)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))[:16]
+}
+
+// applyPodTemplate merges the pod template of the spec of the cluster into the template of ss with a
+// strategic merge patch.
+func applyPodTemplate(ss *appsv1.StatefulSet, cluster *redisv1alpha1.DistributedRedisCluster) error {
+	if cluster.Spec.PodTemplate == nil {
+		return nil
+	}
+	original, err := json.Marshal(ss.Spec.Template)
+	if err != nil {
+		return err
+	}
+	patch, err := podTemplatePatch(cluster.Spec.PodTemplate)
+	if err != nil {
+		return err
+	}
+	merged, err := strategicpatch.StrategicMergePatch(original, patch, corev1.PodTemplateSpec{})
+	if err != nil {
+		return fmt.Errorf("invalid podTemplate: %v", err)
+	}
+	template := corev1.PodTemplateSpec{}
+	if err := json.Unmarshal(merged, &template); err != nil {
+		return err
+	}
+	ss.Spec.Template = template
+	return nil
+}
+
+// podTemplatePatch returns the strategic merge patch of the template. The required fields the template
+// does not set, such as its containers, are marshalled as null, which would delete them from the generated
+// template, the null values are dropped.
+func podTemplatePatch(template *corev1.PodTemplateSpec) ([]byte, error) {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return nil, err
+	}
+	patch := map[string]interface{}{}
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, err
+	}
+	return json.Marshal(dropNulls(patch))
+}
+
+func dropNulls(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if item == nil {
+				delete(v, key)
+				continue
+			}
+			v[key] = dropNulls(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = dropNulls(item)
+		}
+	}
+	return value
+}
@@ -13,6 +13,7 @@ import (
 	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
 	"github.com/ucloud/redis-cluster-operator/pkg/config"
 	"github.com/ucloud/redis-cluster-operator/pkg/osm"
+	"github.com/ucloud/redis-cluster-operator/pkg/redisconfig"
 	"github.com/ucloud/redis-cluster-operator/pkg/resources/configmaps"
 	"github.com/ucloud/redis-cluster-operator/pkg/utils"
 )
@@ -21,19 +22,28 @@ var log = logf.Log.WithName("resource_statefulset")
 
 const (
 	redisStorageVolumeName = "redis-data"
-	redisServerName        = "redis"
+	redisServerName        = redisv1alpha1.RedisContainerName
+	exporterName           = "exporter"
 	hostnameTopologyKey    = "kubernetes.io/hostname"
 
 	graceTime = 30
 
 	configMapVolumeName = "conf"
+	osmConfigVolumeName = "osmconfig"
+
+	// AOFShipperName is the name of the sidecar shipping the AOF of the continuous backup.
+	AOFShipperName         = "aof-shipper"
+	aofOSMConfigVolumeName = "osmconfig-aof"
+	// replayAOFName is the name of the init container replaying the AOF of a point in time restore.
+	replayAOFName = "replay-aof"
 )
 
-// NewStatefulSetForCR creates a new StatefulSet for the given Cluster.
-func NewStatefulSetForCR(cluster *redisv1alpha1.DistributedRedisCluster, ssName, svcName string,
+// NewStatefulSetForCR creates a new StatefulSet for the given shard of the Cluster.
+func NewStatefulSetForCR(cluster *redisv1alpha1.DistributedRedisCluster, shard int, ssName, svcName string,
 	labels map[string]string) (*appsv1.StatefulSet, error) {
 	password := redisPassword(cluster)
 	volumes := redisVolumes(cluster)
+	nodeSelector, tolerations, affinity := ShardScheduling(cluster, shard, labels)
 	namespace := cluster.Namespace
 	spec := cluster.Spec
 	size := spec.ClusterReplicas + 1
@@ -47,8 +57,9 @@ func NewStatefulSetForCR(cluster *redisv1alpha1.DistributedRedisCluster, ssName,
 		Spec: appsv1.StatefulSetSpec{
 			ServiceName: svcName,
 			Replicas:    &size,
+			// the pods are restarted by the operator, see the upgrade of the DistributedRedisCluster controller.
 			UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
-				Type: appsv1.RollingUpdateStatefulSetStrategyType,
+				Type: appsv1.OnDeleteStatefulSetStrategyType,
 			},
 			Selector: &metav1.LabelSelector{
 				MatchLabels: labels,
@@ -56,15 +67,15 @@ func NewStatefulSetForCR(cluster *redisv1alpha1.DistributedRedisCluster, ssName,
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels:      labels,
-					Annotations: cluster.Spec.Annotations,
+					Annotations: podAnnotations(cluster),
 				},
 				Spec: corev1.PodSpec{
-					Affinity:        getAffinity(spec.Affinity, labels),
-					Tolerations:     spec.ToleRations,
+					Affinity:        affinity,
+					Tolerations:     tolerations,
 					SecurityContext: spec.SecurityContext,
-					NodeSelector:    cluster.Spec.NodeSelector,
+					NodeSelector:    nodeSelector,
 					Containers: []corev1.Container{
-						redisServerContainer(cluster, password),
+						redisServerContainer(cluster, shard, password),
 					},
 					Volumes: volumes,
 				},
@@ -84,16 +95,95 @@ func NewStatefulSetForCR(cluster *redisv1alpha1.DistributedRedisCluster, ssName,
 	if spec.Monitor != nil {
 		ss.Spec.Template.Spec.Containers = append(ss.Spec.Template.Spec.Containers, redisExporterContainer(cluster, password))
 	}
+	if spec.ContinuousBackup != nil {
+		setAOFShipperContainer(ss, cluster, password)
+	}
+	if spec.TLS != nil {
+		setTLS(ss, cluster)
+	}
+	if spec.ExternalAccess != nil {
+		setExternalAccess(ss)
+	}
+	if spec.AnnounceHostnames {
+		setAnnounceHostname(ss)
+	}
 	if cluster.IsRestoreFromBackup() && cluster.Status.Restore.Backup != nil {
-		initContainer, err := redisInitContainer(cluster, password)
-		if err != nil {
+		if err := setRestoreInitContainers(ss, cluster, cluster.Status.Restore.Backup, cluster.Spec.Init.BackupSource.RestoreTimestamp); err != nil {
 			return nil, err
 		}
-		ss.Spec.Template.Spec.InitContainers = append(ss.Spec.Template.Spec.InitContainers, initContainer)
+	}
+	if err := applyPodTemplate(ss, cluster); err != nil {
+		return nil, err
 	}
 	return ss, nil
 }
 
+// SetRestoreInitContainer adds the init container pulling the backup into the data volume of
+// the redis nodes of ss, an existing restore init container is replaced.
+func SetRestoreInitContainer(ss *appsv1.StatefulSet, cluster *redisv1alpha1.DistributedRedisCluster,
+	backup *redisv1alpha1.RedisClusterBackup) error {
+	return setRestoreInitContainers(ss, cluster, backup, nil)
+}
+
+// setRestoreInitContainers adds the restore init containers, the AOF of the continuous backup is
+// replayed up to timestamp if it is not nil.
+func setRestoreInitContainers(ss *appsv1.StatefulSet, cluster *redisv1alpha1.DistributedRedisCluster,
+	backup *redisv1alpha1.RedisClusterBackup, timestamp *metav1.Time) error {
+	initContainer, err := redisInitContainer(cluster, backup, redisPassword(cluster), timestamp)
+	if err != nil {
+		return err
+	}
+	restoreContainers := []corev1.Container{initContainer}
+	if timestamp != nil {
+		restoreContainers = append(restoreContainers, replayAOFContainer(cluster, timestamp))
+	}
+	osmVolume := corev1.Volume{
+		Name: osmConfigVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: backup.OSMSecretName(),
+			},
+		},
+	}
+
+	podSpec := &ss.Spec.Template.Spec
+	initContainers := restoreContainers
+	for _, c := range podSpec.InitContainers {
+		if c.Name != initContainer.Name && c.Name != replayAOFName {
+			initContainers = append(initContainers, c)
+		}
+	}
+	podSpec.InitContainers = initContainers
+	volumes := []corev1.Volume{}
+	for _, v := range podSpec.Volumes {
+		if v.Name != osmVolume.Name {
+			volumes = append(volumes, v)
+		}
+	}
+	podSpec.Volumes = append(volumes, osmVolume)
+	return nil
+}
+
+// ShardScheduling returns the node selector, the tolerations and the affinity of the pods of the shard,
+// the override of the shard replaces the ones of the spec.
+func ShardScheduling(cluster *redisv1alpha1.DistributedRedisCluster, shard int,
+	labels map[string]string) (map[string]string, []corev1.Toleration, *corev1.Affinity) {
+	spec := cluster.Spec
+	nodeSelector, tolerations, affinity := spec.NodeSelector, spec.ToleRations, spec.Affinity
+	if override := cluster.ShardOverride(shard); override != nil {
+		if override.NodeSelector != nil {
+			nodeSelector = override.NodeSelector
+		}
+		if override.Tolerations != nil {
+			tolerations = override.Tolerations
+		}
+		if override.Affinity != nil {
+			affinity = override.Affinity
+		}
+	}
+	return nodeSelector, tolerations, getAffinity(affinity, labels)
+}
+
 func getAffinity(affinity *corev1.Affinity, labels map[string]string) *corev1.Affinity {
 	if affinity != nil {
 		return affinity
@@ -137,10 +227,26 @@ func persistentClaim(cluster *redisv1alpha1.DistributedRedisCluster, labels map[
 	}
 }
 
+// RedisContainer returns the redis server container of the pods of ss, the other containers of the pod template
+// may come before it. It returns an empty container if there is none.
+func RedisContainer(ss *appsv1.StatefulSet) corev1.Container {
+	for _, c := range ss.Spec.Template.Spec.Containers {
+		if c.Name == redisServerName {
+			return c
+		}
+	}
+	return corev1.Container{}
+}
+
 func ClusterStatefulSetName(clusterName string, i int) string {
 	return fmt.Sprintf("drc-%s-%d", clusterName, i)
 }
 
+// ClusterPvcName returns the name of the data PersistentVolumeClaim of the pod ordinal of the StatefulSet ssName.
+func ClusterPvcName(ssName string, ordinal int) string {
+	return fmt.Sprintf("%s-%s-%d", redisStorageVolumeName, ssName, ordinal)
+}
+
 func ClusterHeadlessSvcName(name string, i int) string {
 	return fmt.Sprintf("%s-%d", name, i)
 }
@@ -149,6 +255,7 @@ func getRedisCommand(cluster *redisv1alpha1.DistributedRedisCluster, password *c
 	cmd := []string{
 		"/conf/fix-ip.sh",
 		"redis-server",
+		"/data/" + redisconfig.ConfigFile,
 		"--cluster-enabled yes",
 		"--cluster-config-file /data/nodes.conf",
 	}
@@ -195,12 +302,15 @@ func mergeRenameCmds(userCmds []string, systemRenameCmdMap map[string]string) []
 	return cmds
 }
 
-func redisServerContainer(cluster *redisv1alpha1.DistributedRedisCluster, password *corev1.EnvVar) corev1.Container {
+func redisServerContainer(cluster *redisv1alpha1.DistributedRedisCluster, shard int, password *corev1.EnvVar) corev1.Container {
 	probeArg := "redis-cli -h $(hostname)"
+	if cluster.Spec.TLS != nil {
+		probeArg = fmt.Sprintf("redis-cli ${%s} -h $(hostname)", redisCLIArgsEnv)
+	}
 
 	container := corev1.Container{
 		Name:  redisServerName,
-		Image: cluster.Spec.Image,
+		Image: cluster.ShardImage(shard),
 		Ports: []corev1.ContainerPort{
 			{
 				Name:          "client",
@@ -251,7 +361,7 @@ func redisServerContainer(cluster *redisv1alpha1.DistributedRedisCluster, passwo
 				},
 			},
 		},
-		Resources: *cluster.Spec.Resources,
+		Resources: *cluster.ShardResources(shard),
 		// TODO store redis data when pod stop
 		Lifecycle: &corev1.Lifecycle{
 			PreStop: &corev1.Handler{
@@ -271,7 +381,7 @@ func redisServerContainer(cluster *redisv1alpha1.DistributedRedisCluster, passwo
 
 func redisExporterContainer(cluster *redisv1alpha1.DistributedRedisCluster, password *corev1.EnvVar) corev1.Container {
 	container := corev1.Container{
-		Name: "exporter",
+		Name: exporterName,
 		Args: append([]string{
 			fmt.Sprintf("--web.listen-address=:%v", cluster.Spec.Monitor.Prometheus.Port),
 			fmt.Sprintf("--web.telemetry-path=%v", redisv1alpha1.PrometheusExporterTelemetryPath),
@@ -295,8 +405,87 @@ func redisExporterContainer(cluster *redisv1alpha1.DistributedRedisCluster, pass
 	return container
 }
 
-func redisInitContainer(cluster *redisv1alpha1.DistributedRedisCluster, password *corev1.EnvVar) (corev1.Container, error) {
-	backup := cluster.Status.Restore.Backup
+// setAOFShipperContainer adds the sidecar shipping the AOF of the masters of ss to the object storage.
+func setAOFShipperContainer(ss *appsv1.StatefulSet, cluster *redisv1alpha1.DistributedRedisCluster, password *corev1.EnvVar) {
+	spec := cluster.Spec.ContinuousBackup
+	container := corev1.Container{
+		Name:            AOFShipperName,
+		Image:           spec.Image,
+		ImagePullPolicy: corev1.PullAlways,
+		Args:            AOFShipperArgs(cluster),
+		Env: []corev1.EnvVar{
+			{
+				Name: "POD_NAME",
+				ValueFrom: &corev1.EnvVarSource{
+					FieldRef: &corev1.ObjectFieldSelector{
+						FieldPath: "metadata.name",
+					},
+				},
+			},
+		},
+		Resources: spec.Resources,
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      redisStorageVolumeName,
+				MountPath: redisv1alpha1.BackupDumpDir,
+			},
+			{
+				Name:      aofOSMConfigVolumeName,
+				ReadOnly:  true,
+				MountPath: osm.SecretMountPath,
+			},
+		},
+	}
+	if password != nil {
+		container.Env = append(container.Env, *password)
+	}
+	podSpec := &ss.Spec.Template.Spec
+	podSpec.Containers = append(podSpec.Containers, container)
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: aofOSMConfigVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: cluster.ContinuousBackupSecretName(),
+			},
+		},
+	})
+}
+
+// AOFShipperArgs returns the arguments of the AOF shipping sidecar of the cluster.
+func AOFShipperArgs(cluster *redisv1alpha1.DistributedRedisCluster) []string {
+	spec := cluster.Spec.ContinuousBackup
+	// the bucket is validated with the continuous backup, see ContinuousBackupSpec.Validate.
+	bucket, _ := spec.Container()
+	return []string{
+		"ship-aof",
+		fmt.Sprintf(`--data-dir=%s`, redisv1alpha1.BackupDumpDir),
+		fmt.Sprintf(`--bucket=%s`, bucket),
+		fmt.Sprintf(`--folder=%s`, cluster.ContinuousBackupLocation()),
+		fmt.Sprintf(`--interval=%d`, spec.Interval()),
+		"--",
+	}
+}
+
+// replayAOFContainer returns the init container replaying the AOF pulled by the restore init container
+// up to timestamp, it runs the image of the cluster as the AOF format depends on the Redis version.
+func replayAOFContainer(cluster *redisv1alpha1.DistributedRedisCluster, timestamp *metav1.Time) corev1.Container {
+	return corev1.Container{
+		Name:         replayAOFName,
+		Image:        cluster.Spec.Image,
+		Command:      []string{"/bin/sh", "/conf/replay-aof.sh"},
+		VolumeMounts: volumeMounts(),
+		Env: []corev1.EnvVar{
+			{
+				Name:  "RESTORE_TIMESTAMP",
+				Value: fmt.Sprintf("%d", timestamp.Unix()),
+			},
+		},
+		Resources: *cluster.Spec.Resources,
+	}
+}
+
+func redisInitContainer(cluster *redisv1alpha1.DistributedRedisCluster, backup *redisv1alpha1.RedisClusterBackup,
+	password *corev1.EnvVar, timestamp *metav1.Time) (corev1.Container, error) {
 	backupSpec := backup.Spec.Backend
 	bucket, err := backupSpec.Container()
 	if err != nil {
@@ -307,18 +496,30 @@ func redisInitContainer(cluster *redisv1alpha1.DistributedRedisCluster, password
 		return corev1.Container{}, err
 	}
 	log.V(3).Info("restore", "namespaces", cluster.Namespace, "name", cluster.Name, "folderName", folderName)
-	container := corev1.Container{
-		Name:            redisv1alpha1.JobTypeRestore,
-		Image:           backup.Spec.Image,
-		ImagePullPolicy: corev1.PullAlways,
-		Args: []string{
-			redisv1alpha1.JobTypeRestore,
+	args := []string{
+		redisv1alpha1.JobTypeRestore,
+		fmt.Sprintf(`--data-dir=%s`, redisv1alpha1.BackupDumpDir),
+		fmt.Sprintf(`--bucket=%s`, bucket),
+		fmt.Sprintf(`--folder=%s`, folderName),
+		fmt.Sprintf(`--snapshot=%s`, backup.Name),
+		"--",
+	}
+	if timestamp != nil {
+		// pull the AOF of the continuous backup, it is replayed by the replay-aof init container.
+		args = []string{
+			"restore-aof",
 			fmt.Sprintf(`--data-dir=%s`, redisv1alpha1.BackupDumpDir),
 			fmt.Sprintf(`--bucket=%s`, bucket),
 			fmt.Sprintf(`--folder=%s`, folderName),
-			fmt.Sprintf(`--snapshot=%s`, backup.Name),
+			fmt.Sprintf(`--timestamp=%d`, timestamp.Unix()),
 			"--",
-		},
+		}
+	}
+	container := corev1.Container{
+		Name:            redisv1alpha1.JobTypeRestore,
+		Image:           backup.Spec.Image,
+		ImagePullPolicy: corev1.PullAlways,
+		Args:            args,
 		Env: []corev1.EnvVar{
 			{
 				Name: "POD_NAME",
@@ -346,7 +547,7 @@ func redisInitContainer(cluster *redisv1alpha1.DistributedRedisCluster, password
 				MountPath: redisv1alpha1.BackupDumpDir,
 			},
 			{
-				Name:      "osmconfig",
+				Name:      osmConfigVolumeName,
 				ReadOnly:  true,
 				MountPath: osm.SecretMountPath,
 			},
@@ -355,6 +556,9 @@ func redisInitContainer(cluster *redisv1alpha1.DistributedRedisCluster, password
 	if password != nil {
 		container.Env = append(container.Env, *password)
 	}
+	if key := backup.EncryptionKeyEnv(); key != nil {
+		container.Env = append(container.Env, *key)
+	}
 	if backup.Spec.PodSpec != nil {
 		container.Resources = backup.Spec.PodSpec.Resources
 		container.LivenessProbe = backup.Spec.PodSpec.LivenessProbe
@@ -364,6 +568,37 @@ func redisInitContainer(cluster *redisv1alpha1.DistributedRedisCluster, password
 	return container, nil
 }
 
+// RestoreContainerError returns an error if a restore init container of the pod has failed,
+// the error contains the termination message of the container.
+func RestoreContainerError(pod *corev1.Pod) error {
+	for _, status := range pod.Status.InitContainerStatuses {
+		if status.Name != redisv1alpha1.JobTypeRestore && status.Name != replayAOFName {
+			continue
+		}
+		terminated := status.State.Terminated
+		if terminated == nil {
+			terminated = status.LastTerminationState.Terminated
+		}
+		if terminated != nil && terminated.ExitCode != 0 {
+			return fmt.Errorf("%s container of pod %s failed with exit code %d: %s %s",
+				status.Name, pod.Name, terminated.ExitCode, terminated.Reason, terminated.Message)
+		}
+	}
+	return nil
+}
+
+// podAnnotations returns the annotations of the spec, the hash of the restart-required parameters of the
+// config and the hash of the pod template of the spec, the pods are restarted when they change.
+func podAnnotations(cluster *redisv1alpha1.DistributedRedisCluster) map[string]string {
+	annotations := map[string]string{
+		redisv1alpha1.AnnotationConfigHash: redisconfig.RestartHash(cluster.RedisConfig()),
+	}
+	if hash := PodTemplateHash(cluster); hash != "" {
+		annotations[redisv1alpha1.AnnotationPodTemplateHash] = hash
+	}
+	return utils.MergeLabels(cluster.Spec.Annotations, annotations)
+}
+
 func volumeMounts() []corev1.VolumeMount {
 	return []corev1.VolumeMount{
 		{
@@ -377,12 +612,12 @@ func volumeMounts() []corev1.VolumeMount {
 	}
 }
 
-// Returns the REDIS_PASSWORD environment variable.
+// Returns the REDIS_PASSWORD environment variable, from the Secret of the password the nodes accept.
 func redisPassword(cluster *redisv1alpha1.DistributedRedisCluster) *corev1.EnvVar {
 	if cluster.Spec.PasswordSecret == nil {
 		return nil
 	}
-	secretName := cluster.Spec.PasswordSecret.Name
+	secretName := cluster.CurrentPasswordSecretName()
 
 	return &corev1.EnvVar{
 		Name: redisv1alpha1.PasswordENV,
@@ -417,17 +652,6 @@ func redisVolumes(cluster *redisv1alpha1.DistributedRedisCluster) []corev1.Volum
 	if dataVolume != nil {
 		volumes = append(volumes, *dataVolume)
 	}
-	if cluster.IsRestoreFromBackup() && cluster.Status.Restore.Backup != nil {
-		volumes = append(volumes, corev1.Volume{
-			Name: "osmconfig",
-			VolumeSource: corev1.VolumeSource{
-				Secret: &corev1.SecretVolumeSource{
-					SecretName: cluster.Status.Restore.Backup.OSMSecretName(),
-				},
-			},
-		})
-	}
-
 	return volumes
 }
 
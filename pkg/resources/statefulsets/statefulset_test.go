@@ -2,7 +2,16 @@ package statefulsets
 
 import (
 	"reflect"
+	"strings"
 	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	store "kmodules.xyz/objectstore-api/api/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
 )
 
 func Test_mergeRenameCmds(t *testing.T) {
@@ -89,3 +98,378 @@ func Test_mergeRenameCmds(t *testing.T) {
 		})
 	}
 }
+
+func TestSetRestoreInitContainer(t *testing.T) {
+	cluster := &redisv1alpha1.DistributedRedisCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"},
+	}
+	newBackup := func(name string) *redisv1alpha1.RedisClusterBackup {
+		now := metav1.Now()
+		return &redisv1alpha1.RedisClusterBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: redisv1alpha1.RedisClusterBackupSpec{
+				Image:            "redis-tools",
+				RedisClusterName: "cluster",
+				Backend: store.Backend{
+					S3: &store.S3Spec{Bucket: "bucket"},
+				},
+			},
+			Status: redisv1alpha1.RedisClusterBackupStatus{StartTime: &now},
+		}
+	}
+	ss := &appsv1.StatefulSet{
+		Spec: appsv1.StatefulSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Volumes: []corev1.Volume{{Name: configMapVolumeName}},
+				},
+			},
+		},
+	}
+
+	encrypted := newBackup("second")
+	encrypted.Spec.Encryption = &redisv1alpha1.BackupEncryption{
+		KeySecret: corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "backup-key"},
+			Key:                  "key",
+		},
+	}
+
+	for _, backup := range []*redisv1alpha1.RedisClusterBackup{newBackup("first"), encrypted} {
+		if err := SetRestoreInitContainer(ss, cluster, backup); err != nil {
+			t.Fatalf("SetRestoreInitContainer() error = %v", err)
+		}
+		podSpec := ss.Spec.Template.Spec
+		if len(podSpec.InitContainers) != 1 || podSpec.InitContainers[0].Name != redisv1alpha1.JobTypeRestore {
+			t.Fatalf("SetRestoreInitContainer() init containers = %v, want one restore container", podSpec.InitContainers)
+		}
+		wantArg := "--snapshot=" + backup.Name
+		if !containsString(podSpec.InitContainers[0].Args, wantArg) {
+			t.Errorf("SetRestoreInitContainer() args = %v, want %s", podSpec.InitContainers[0].Args, wantArg)
+		}
+		if len(podSpec.Volumes) != 2 || podSpec.Volumes[1].Secret.SecretName != backup.OSMSecretName() {
+			t.Errorf("SetRestoreInitContainer() volumes = %v, want osm secret %s", podSpec.Volumes, backup.OSMSecretName())
+		}
+		var key *corev1.EnvVar
+		for i, env := range podSpec.InitContainers[0].Env {
+			if env.Name == redisv1alpha1.BackupKeyENV {
+				key = &podSpec.InitContainers[0].Env[i]
+			}
+		}
+		if (key != nil) != (backup.Spec.Encryption != nil) {
+			t.Errorf("SetRestoreInitContainer() env = %v, want encryption key %v", podSpec.InitContainers[0].Env, backup.Spec.Encryption)
+		}
+		if key != nil && key.ValueFrom.SecretKeyRef.Name != "backup-key" {
+			t.Errorf("SetRestoreInitContainer() %s = %v, want it from secret backup-key", key.Name, key.ValueFrom)
+		}
+	}
+}
+
+func TestNewRestoreLoaderPod(t *testing.T) {
+	cluster := &redisv1alpha1.DistributedRedisCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"},
+		Spec: redisv1alpha1.DistributedRedisClusterSpec{
+			Image:          "redis",
+			PasswordSecret: &corev1.LocalObjectReference{Name: "secret"},
+		},
+	}
+	now := metav1.Now()
+	backup := &redisv1alpha1.RedisClusterBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "default"},
+		Spec: redisv1alpha1.RedisClusterBackupSpec{
+			Image:            "redis-tools",
+			RedisClusterName: "cluster",
+			Backend: store.Backend{
+				S3: &store.S3Spec{Bucket: "bucket"},
+			},
+		},
+		Status: redisv1alpha1.RedisClusterBackupStatus{StartTime: &now},
+	}
+
+	name := RestoreLoaderPodName("restore-with-dashes", 3)
+	if fields := strings.Split(name, "-"); fields[len(fields)-2] != "3" {
+		t.Errorf("RestoreLoaderPodName() = %s, want the snapshot index in the second to last field", name)
+	}
+	pod, err := NewRestoreLoaderPod(cluster, backup, name, nil, nil)
+	if err != nil {
+		t.Fatalf("NewRestoreLoaderPod() error = %v", err)
+	}
+	if len(pod.Spec.InitContainers) != 1 {
+		t.Fatalf("NewRestoreLoaderPod() init containers = %v, want one restore container", pod.Spec.InitContainers)
+	}
+	for _, env := range pod.Spec.InitContainers[0].Env {
+		if env.Name == "REDIS_RESTORE_SUCCEEDED" && (env.Value != "0" || env.ValueFrom != nil) {
+			t.Errorf("NewRestoreLoaderPod() %s = %v, want 0", env.Name, env)
+		}
+	}
+	cmd := pod.Spec.Containers[0].Command
+	if containsString(cmd, "--cluster-enabled yes") || !containsString(cmd, "$(REDIS_PASSWORD)") {
+		t.Errorf("NewRestoreLoaderPod() command = %v, want a standalone server with a password", cmd)
+	}
+}
+
+func TestNewStatefulSetForCR_ContinuousBackup(t *testing.T) {
+	now := metav1.Now()
+	timestamp := metav1.Unix(1700000000, 0)
+	backend := store.Backend{
+		S3: &store.S3Spec{Bucket: "bucket", Prefix: "prefix"},
+	}
+	cluster := &redisv1alpha1.DistributedRedisCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"},
+		Spec: redisv1alpha1.DistributedRedisClusterSpec{
+			Image:     "redis:7.0",
+			Resources: &corev1.ResourceRequirements{},
+			Init: &redisv1alpha1.InitSpec{
+				BackupSource: &redisv1alpha1.BackupSourceSpec{
+					Bucket:           &redisv1alpha1.BucketSourceSpec{Backend: backend, Folder: "prefix/redis/default/old/aof"},
+					RestoreTimestamp: &timestamp,
+				},
+			},
+			ContinuousBackup: &redisv1alpha1.ContinuousBackupSpec{
+				Image:   "redis-tools",
+				Backend: backend,
+			},
+		},
+		Status: redisv1alpha1.DistributedRedisClusterStatus{
+			Restore: redisv1alpha1.Restore{
+				Backup: &redisv1alpha1.RedisClusterBackup{
+					ObjectMeta: metav1.ObjectMeta{Name: "old", Namespace: "default"},
+					Spec:       redisv1alpha1.RedisClusterBackupSpec{Image: "redis-tools", Backend: backend},
+					Status: redisv1alpha1.RedisClusterBackupStatus{
+						StartTime: &now,
+						Location:  "prefix/redis/default/old/aof",
+					},
+				},
+			},
+		},
+	}
+
+	ss, err := NewStatefulSetForCR(cluster, 0, "drc-cluster-0", "cluster-0", nil)
+	if err != nil {
+		t.Fatalf("NewStatefulSetForCR() error = %v", err)
+	}
+	podSpec := ss.Spec.Template.Spec
+	var shipper *corev1.Container
+	for i, c := range podSpec.Containers {
+		if c.Name == AOFShipperName {
+			shipper = &podSpec.Containers[i]
+		}
+	}
+	if shipper == nil {
+		t.Fatalf("NewStatefulSetForCR() containers = %v, want the %s sidecar", podSpec.Containers, AOFShipperName)
+	}
+	for _, arg := range []string{"ship-aof", "--folder=prefix/redis/default/cluster/aof", "--interval=10"} {
+		if !containsString(shipper.Args, arg) {
+			t.Errorf("NewStatefulSetForCR() shipper args = %v, want %s", shipper.Args, arg)
+		}
+	}
+	if !reflect.DeepEqual(shipper.Args, AOFShipperArgs(cluster)) {
+		t.Errorf("NewStatefulSetForCR() shipper args = %v, want %v", shipper.Args, AOFShipperArgs(cluster))
+	}
+	found := false
+	for _, v := range podSpec.Volumes {
+		if v.Name == aofOSMConfigVolumeName && v.Secret.SecretName == cluster.ContinuousBackupSecretName() {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("NewStatefulSetForCR() volumes = %v, want osm secret %s", podSpec.Volumes, cluster.ContinuousBackupSecretName())
+	}
+
+	if len(podSpec.InitContainers) != 2 || podSpec.InitContainers[0].Name != redisv1alpha1.JobTypeRestore ||
+		podSpec.InitContainers[1].Name != replayAOFName {
+		t.Fatalf("NewStatefulSetForCR() init containers = %v, want restore and %s", podSpec.InitContainers, replayAOFName)
+	}
+	restore := podSpec.InitContainers[0]
+	if restore.Args[0] != "restore-aof" || !containsString(restore.Args, "--timestamp=1700000000") ||
+		!containsString(restore.Args, "--folder=prefix/redis/default/old/aof") {
+		t.Errorf("NewStatefulSetForCR() restore args = %v, want restore-aof up to 1700000000", restore.Args)
+	}
+	replay := podSpec.InitContainers[1]
+	if replay.Image != cluster.Spec.Image || replay.Env[0].Value != "1700000000" {
+		t.Errorf("NewStatefulSetForCR() %s = %v, want image %s and timestamp 1700000000", replayAOFName, replay, cluster.Spec.Image)
+	}
+
+	// a RedisClusterRestore of the cluster restores a snapshot, the AOF is not replayed.
+	if err := SetRestoreInitContainer(ss, cluster, cluster.Status.Restore.Backup); err != nil {
+		t.Fatalf("SetRestoreInitContainer() error = %v", err)
+	}
+	initContainers := ss.Spec.Template.Spec.InitContainers
+	if len(initContainers) != 1 || initContainers[0].Args[0] != redisv1alpha1.JobTypeRestore {
+		t.Errorf("SetRestoreInitContainer() init containers = %v, want a single snapshot restore", initContainers)
+	}
+}
+
+func TestNewStatefulSetForCR_ShardOverride(t *testing.T) {
+	small := corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")}}
+	large := corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("8Gi")}}
+	cluster := &redisv1alpha1.DistributedRedisCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"},
+		Spec: redisv1alpha1.DistributedRedisClusterSpec{
+			Image:        "redis",
+			Resources:    &small,
+			NodeSelector: map[string]string{"pool": "default"},
+			ToleRations:  []corev1.Toleration{{Key: "redis", Operator: corev1.TolerationOpExists}},
+			ShardOverrides: []redisv1alpha1.ShardOverride{
+				{Shard: 1, Resources: &large, NodeSelector: map[string]string{"pool": "highmem"}},
+			},
+		},
+	}
+	labels := map[string]string{"statefulSet": "drc-cluster-1"}
+	for shard, want := range map[int]struct {
+		resources corev1.ResourceRequirements
+		pool      string
+	}{0: {small, "default"}, 1: {large, "highmem"}} {
+		ss, err := NewStatefulSetForCR(cluster, shard, ClusterStatefulSetName(cluster.Name, shard), "svc", labels)
+		if err != nil {
+			t.Fatalf("NewStatefulSetForCR() error = %v", err)
+		}
+		podSpec := ss.Spec.Template.Spec
+		if !reflect.DeepEqual(podSpec.Containers[0].Resources, want.resources) {
+			t.Errorf("NewStatefulSetForCR() shard %d resources = %v, want %v", shard, podSpec.Containers[0].Resources, want.resources)
+		}
+		if podSpec.NodeSelector["pool"] != want.pool {
+			t.Errorf("NewStatefulSetForCR() shard %d node selector = %v, want pool %s", shard, podSpec.NodeSelector, want.pool)
+		}
+		// the tolerations and the affinity are not overridden.
+		if !reflect.DeepEqual(podSpec.Tolerations, cluster.Spec.ToleRations) || podSpec.Affinity == nil {
+			t.Errorf("NewStatefulSetForCR() shard %d tolerations = %v, affinity = %v", shard, podSpec.Tolerations, podSpec.Affinity)
+		}
+	}
+}
+
+func TestNewStatefulSetForCR_PodTemplate(t *testing.T) {
+	resources := corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")}}
+	cluster := &redisv1alpha1.DistributedRedisCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"},
+		Spec: redisv1alpha1.DistributedRedisClusterSpec{
+			Image:     "redis",
+			Resources: &resources,
+			PodTemplate: &corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"team": "cache"}},
+				Spec: corev1.PodSpec{
+					PriorityClassName: "high",
+					Containers: []corev1.Container{
+						{Name: redisServerName, Env: []corev1.EnvVar{{Name: "TZ", Value: "UTC"}}},
+						{Name: "sidecar", Image: "sidecar"},
+					},
+					Volumes: []corev1.Volume{{Name: "extra"}},
+				},
+			},
+		},
+	}
+	labels := map[string]string{"statefulSet": "drc-cluster-0"}
+	ss, err := NewStatefulSetForCR(cluster, 0, "drc-cluster-0", "svc", labels)
+	if err != nil {
+		t.Fatalf("NewStatefulSetForCR() error = %v", err)
+	}
+	template := ss.Spec.Template
+	if template.Labels["statefulSet"] != "drc-cluster-0" || template.Labels["team"] != "cache" {
+		t.Errorf("NewStatefulSetForCR() labels = %v, want the generated and the template labels", template.Labels)
+	}
+	if template.Annotations[redisv1alpha1.AnnotationPodTemplateHash] != PodTemplateHash(cluster) {
+		t.Errorf("NewStatefulSetForCR() annotations = %v, want the pod template hash", template.Annotations)
+	}
+	podSpec := template.Spec
+	if podSpec.PriorityClassName != "high" {
+		t.Errorf("NewStatefulSetForCR() priorityClassName = %s, want high", podSpec.PriorityClassName)
+	}
+	if len(podSpec.Containers) != 2 || podSpec.Containers[1].Name != "sidecar" {
+		t.Fatalf("NewStatefulSetForCR() containers = %v, want redis and sidecar", podSpec.Containers)
+	}
+	redis := podSpec.Containers[0]
+	if redis.Image != "redis" || len(redis.Command) == 0 || !reflect.DeepEqual(redis.Resources, resources) {
+		t.Errorf("NewStatefulSetForCR() redis container = %v, want the generated fields", redis)
+	}
+	if len(redis.Env) < 2 || redis.Env[0].Name != "TZ" && redis.Env[len(redis.Env)-1].Name != "TZ" {
+		t.Errorf("NewStatefulSetForCR() redis env = %v, want POD_IP and TZ", redis.Env)
+	}
+	var volumes []string
+	for _, volume := range podSpec.Volumes {
+		volumes = append(volumes, volume.Name)
+	}
+	if !containsString(volumes, configMapVolumeName) || !containsString(volumes, "extra") {
+		t.Errorf("NewStatefulSetForCR() volumes = %v, want %s and extra", volumes, configMapVolumeName)
+	}
+}
+
+func TestNewStatefulSetForCR_TLS(t *testing.T) {
+	cluster := &redisv1alpha1.DistributedRedisCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"},
+		Spec: redisv1alpha1.DistributedRedisClusterSpec{
+			Image:     "redis:6.2",
+			Resources: &corev1.ResourceRequirements{},
+			Monitor: &redisv1alpha1.AgentSpec{
+				Image:      "exporter",
+				Prometheus: &redisv1alpha1.PrometheusSpec{Port: 9100},
+			},
+			TLS: &redisv1alpha1.TLSSpec{IssuerRef: &redisv1alpha1.TLSIssuerReference{Name: "ca"}},
+			ContinuousBackup: &redisv1alpha1.ContinuousBackupSpec{
+				Image:   "redis-tools",
+				Backend: store.Backend{S3: &store.S3Spec{Bucket: "bucket"}},
+			},
+		},
+	}
+	ss, err := NewStatefulSetForCR(cluster, 0, "drc-cluster-0", "svc", map[string]string{"statefulSet": "drc-cluster-0"})
+	if err != nil {
+		t.Fatalf("NewStatefulSetForCR() error = %v", err)
+	}
+	if name := TLSSecretName(ss); name != "drc-cluster-tls" {
+		t.Errorf("TLSSecretName() = %s, want drc-cluster-tls", name)
+	}
+	redis := ss.Spec.Template.Spec.Containers[0]
+	command := strings.Join(redis.Command, " ")
+	for _, arg := range []string{"--port 0", "--tls-port 6379", "--tls-cluster yes", "--tls-replication yes"} {
+		if !strings.Contains(command, arg) {
+			t.Errorf("NewStatefulSetForCR() redis command = %s, want %s", command, arg)
+		}
+	}
+	if !strings.Contains(redis.ReadinessProbe.Exec.Command[2], redisCLIArgsEnv) {
+		t.Errorf("NewStatefulSetForCR() readiness probe = %v, want the TLS arguments", redis.ReadinessProbe.Exec.Command)
+	}
+	for _, container := range ss.Spec.Template.Spec.Containers {
+		if container.Name == AOFShipperName && !containsEnv(container.Env, RedisCLIArgs()) {
+			t.Errorf("NewStatefulSetForCR() %s env = %v, want %v", AOFShipperName, container.Env, RedisCLIArgs())
+		}
+		mounted := false
+		for _, mount := range container.VolumeMounts {
+			mounted = mounted || mount.Name == tlsVolumeName && mount.MountPath == TLSMountPath
+		}
+		if !mounted {
+			t.Errorf("NewStatefulSetForCR() container %s does not mount the TLS secret", container.Name)
+		}
+	}
+}
+
+func containsEnv(list []corev1.EnvVar, env corev1.EnvVar) bool {
+	for _, v := range list {
+		if reflect.DeepEqual(v, env) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRedisContainer(t *testing.T) {
+	ss := &appsv1.StatefulSet{}
+	ss.Spec.Template.Spec.Containers = []corev1.Container{
+		{Name: "sidecar", Image: "sidecar:1.0"},
+		{Name: redisv1alpha1.RedisContainerName, Image: "redis:7.0"},
+	}
+	if got := RedisContainer(ss).Image; got != "redis:7.0" {
+		t.Errorf("RedisContainer() image = %q, want %q", got, "redis:7.0")
+	}
+	ss.Spec.Template.Spec.Containers = ss.Spec.Template.Spec.Containers[:1]
+	if got := RedisContainer(ss).Name; got != "" {
+		t.Errorf("RedisContainer() = %q, want no container", got)
+	}
+}
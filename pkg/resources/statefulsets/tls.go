@@ -0,0 +1,105 @@
+package statefulsets
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	redisv1alpha1 "github.com/ucloud/redis-cluster-operator/pkg/apis/redis/v1alpha1"
+)
+
+const (
+	tlsVolumeName = "tls"
+	// TLSMountPath is where the TLS Secret of the cluster is mounted in the redis pods.
+	TLSMountPath = "/tls"
+	// TLSCertFile is the certificate served by the redis nodes, the operator reloads it with CONFIG SET
+	// once the kubelet has updated the mounted Secret.
+	TLSCertFile   = TLSMountPath + "/" + corev1.TLSCertKey
+	tlsKeyFile    = TLSMountPath + "/" + corev1.TLSPrivateKeyKey
+	tlsCACertFile = TLSMountPath + "/" + redisv1alpha1.TLSCAKey
+
+	// redisCLIArgsEnv holds the TLS arguments of redis-cli, it is unset without TLS.
+	redisCLIArgsEnv = "REDIS_CLI_ARGS"
+)
+
+// tlsArgs returns the arguments of redis-server serving the clients, the replication and the cluster bus
+// over TLS only, on the ports used without TLS.
+func tlsArgs() []string {
+	return []string{
+		"--port 0",
+		"--tls-port 6379",
+		"--tls-cluster yes",
+		"--tls-replication yes",
+		fmt.Sprintf("--tls-cert-file %s", TLSCertFile),
+		fmt.Sprintf("--tls-key-file %s", tlsKeyFile),
+		fmt.Sprintf("--tls-ca-cert-file %s", tlsCACertFile),
+	}
+}
+
+// RedisCLIArgs returns the environment variable holding the TLS arguments of redis-cli.
+func RedisCLIArgs() corev1.EnvVar {
+	return corev1.EnvVar{
+		Name:  redisCLIArgsEnv,
+		Value: fmt.Sprintf("--tls --cacert %s --cert %s --key %s", tlsCACertFile, TLSCertFile, tlsKeyFile),
+	}
+}
+
+// TLSVolume returns the volume of the TLS Secret of the cluster.
+func TLSVolume(cluster *redisv1alpha1.DistributedRedisCluster) corev1.Volume {
+	return corev1.Volume{
+		Name: tlsVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: cluster.TLSSecretName(),
+			},
+		},
+	}
+}
+
+// TLSVolumeMount mounts the TLS Secret at TLSMountPath.
+func TLSVolumeMount() corev1.VolumeMount {
+	return corev1.VolumeMount{
+		Name:      tlsVolumeName,
+		ReadOnly:  true,
+		MountPath: TLSMountPath,
+	}
+}
+
+// setTLS mounts the TLS Secret of the cluster in the containers of ss talking to redis and switches them to TLS.
+// The Secret is not mounted with a subPath so the kubelet updates the certificate of a running pod.
+func setTLS(ss *appsv1.StatefulSet, cluster *redisv1alpha1.DistributedRedisCluster) {
+	podSpec := &ss.Spec.Template.Spec
+	podSpec.Volumes = append(podSpec.Volumes, TLSVolume(cluster))
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		switch container.Name {
+		case redisServerName:
+			container.Command = append(container.Command, tlsArgs()...)
+			container.Env = append(container.Env, RedisCLIArgs())
+		case AOFShipperName:
+			container.Env = append(container.Env, RedisCLIArgs())
+		case exporterName:
+			container.Env = append(container.Env,
+				corev1.EnvVar{Name: "REDIS_ADDR", Value: "rediss://localhost:6379"},
+				corev1.EnvVar{Name: "REDIS_EXPORTER_TLS_CA_CERT_FILE", Value: tlsCACertFile},
+				corev1.EnvVar{Name: "REDIS_EXPORTER_TLS_CLIENT_CERT_FILE", Value: TLSCertFile},
+				corev1.EnvVar{Name: "REDIS_EXPORTER_TLS_CLIENT_KEY_FILE", Value: tlsKeyFile},
+			)
+		default:
+			// the containers added by the pod template are left as is.
+			continue
+		}
+		container.VolumeMounts = append(container.VolumeMounts, TLSVolumeMount())
+	}
+}
+
+// TLSSecretName returns the name of the TLS Secret mounted in the pods of ss, empty without TLS.
+func TLSSecretName(ss *appsv1.StatefulSet) string {
+	for _, v := range ss.Spec.Template.Spec.Volumes {
+		if v.Name == tlsVolumeName && v.Secret != nil {
+			return v.Secret.SecretName
+		}
+	}
+	return ""
+}